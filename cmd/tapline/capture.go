@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hirosassa/tapline/pkg/term"
+)
+
+// capturedOutput is what a wrapped program wrote, byte for byte
+type capturedOutput struct {
+	Stdout string
+	Stderr string
+}
+
+// text returns stdout as it is logged: without escape sequences and surrounding space
+func (o capturedOutput) text() string {
+	return strings.TrimSpace(term.Strip(o.Stdout))
+}
+
+// stderr returns stderr as it is logged, separately from the response
+func (o capturedOutput) stderr() string {
+	return strings.TrimSpace(term.Strip(o.Stderr))
+}
+
+// runCaptured runs program with env and stdin, capturing its output, echoing it
+// to echo and passing it to stream when set. interrupted is the signal that
+// stopped the program, if any.
+func runCaptured(program string, args, env []string, stdin io.Reader, echo io.Writer, stream *responseStream) (output capturedOutput, exitCode int, interrupted os.Signal) {
+	ctx := context.Background()
+	cmd := exec.CommandContext(ctx, program, args...)
+	cmd.Env = env
+
+	// A replayed stdin is copied outside of exec, which would make Wait wait for
+	// input that may never come
+	var stdinPipe io.WriteCloser
+	var err error
+	if file, ok := stdin.(*os.File); ok {
+		cmd.Stdin = file
+	} else if stdinPipe, err = cmd.StdinPipe(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating stdin pipe: %v\n", err)
+		return capturedOutput{}, 1, nil
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating stdout pipe: %v\n", err)
+		return capturedOutput{}, 1, nil
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating stderr pipe: %v\n", err)
+		return capturedOutput{}, 1, nil
+	}
+
+	process, err := startWrapped(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting %s: %v\n", filepath.Base(program), err)
+		return capturedOutput{}, 1, nil
+	}
+
+	if stdinPipe != nil {
+		go func() {
+			io.Copy(stdinPipe, stdin) //nolint:errcheck // The program may exit without reading all input
+			stdinPipe.Close()         //nolint:errcheck // Signals the end of input
+		}()
+	}
+
+	output = captureOutputTo(stdout, stderr, echo, stream)
+
+	exitCode, interrupted = process.wait()
+	return output, exitCode, interrupted
+}
+
+// outputChunk is a piece of output as it was read from a program
+type outputChunk struct {
+	data   []byte
+	stderr bool
+}
+
+// captureOutputTo reads the stdout and stderr of a command until both are closed.
+// Each piece is copied unchanged, in the order it arrives, to echo or os.Stderr.
+// With a stream, stdout is also logged in partial records as it goes, with
+// escape sequences removed.
+func captureOutputTo(stdout, stderr io.Reader, echo io.Writer, stream *responseStream) capturedOutput {
+	chunks := make(chan outputChunk, 100)
+
+	var readers sync.WaitGroup
+	read := func(r io.Reader, isStderr bool) {
+		defer readers.Done()
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				chunks <- outputChunk{data: bytes.Clone(buf[:n]), stderr: isStderr}
+			}
+			if err != nil {
+				if !errors.Is(err, io.EOF) && !errors.Is(err, os.ErrClosed) {
+					fmt.Fprintf(os.Stderr, "Error reading output: %v\n", err)
+				}
+				return
+			}
+		}
+	}
+	readers.Add(2)
+	go read(stdout, false)
+	go read(stderr, true)
+	go func() {
+		readers.Wait()
+		close(chunks)
+	}()
+
+	var tick <-chan time.Time
+	if stream != nil {
+		ticker := time.NewTicker(partialResponseInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	// Echoing and partial logging share this goroutine. Log records never reach
+	// the echoed output, so output still on its line, such as a spinner or a
+	// prompt, is flushed as it stands.
+	var out, errOut strings.Builder
+	var stripper term.Stripper
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				return capturedOutput{Stdout: out.String(), Stderr: errOut.String()}
+			}
+			if chunk.stderr {
+				errOut.Write(chunk.data)
+				os.Stderr.Write(chunk.data) //nolint:errcheck // The output is still captured
+				continue
+			}
+			out.Write(chunk.data)
+			echo.Write(chunk.data) //nolint:errcheck // The output is still captured
+			if stream != nil {
+				stream.add(stripper.Strip(chunk.data))
+			}
+		case <-tick:
+			stream.flush()
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCaptureOutputTo_LongLine(t *testing.T) {
+	// Longer than the 64 KiB a bufio.Scanner line may have
+	line := strings.Repeat("x", 200*1024)
+	stdout := "first\n" + line + "\nlast"
+
+	var echo bytes.Buffer
+	output := captureOutputTo(strings.NewReader(stdout), strings.NewReader(""), &echo, nil)
+
+	if echo.String() != stdout {
+		t.Errorf("Expected the output echoed unchanged, got %d bytes", echo.Len())
+	}
+	if output.Stdout != stdout {
+		t.Errorf("Expected the output captured unchanged, got %d bytes", len(output.Stdout))
+	}
+}
+
+func TestCaptureOutputTo_Passthrough(t *testing.T) {
+	stdout := "\x1b[1mbold\x1b[0m\r\nno newline"
+
+	var echo bytes.Buffer
+	output := captureOutputTo(strings.NewReader(stdout), strings.NewReader("\x1b[33mwarning\x1b[0m\n"), &echo, nil)
+
+	if echo.String() != stdout {
+		t.Errorf("Expected %q echoed, got %q", stdout, echo.String())
+	}
+	if got := output.text(); got != "bold\nno newline" {
+		t.Errorf("Expected escape sequences removed from the logged text, got %q", got)
+	}
+	if output.Stderr != "\x1b[33mwarning\x1b[0m\n" {
+		t.Errorf("Expected stderr captured separately, got %q", output.Stderr)
+	}
+	if got := output.stderr(); got != "warning" {
+		t.Errorf("Expected escape sequences removed from the logged stderr, got %q", got)
+	}
+}
+
+func TestCapturedOutput_NoStderr(t *testing.T) {
+	if got := (capturedOutput{Stdout: "out", Stderr: "\n"}).stderr(); got != "" {
+		t.Errorf("Expected no stderr, got %q", got)
+	}
+}
+
+func TestWrapGemini_LogsStrippedOutputAndStderr(t *testing.T) {
+	if os.Getenv("TEST_WRAP_GEMINI_CAPTURE") == "1" {
+		wrapGemini([]string{"Explain quantum computing"})
+		return
+	}
+
+	tmpDir := t.TempDir()
+	script := "#!/bin/sh\nprintf '\\033[32mQuantum\\033[0m computing\\n'\necho 'Loaded cached credentials.' >&2\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "gemini"), []byte(script), 0o755); err != nil { //nolint:gosec // The mock must be executable
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=TestWrapGemini_LogsStrippedOutputAndStderr")
+	cmd.Env = append(os.Environ(), "TEST_WRAP_GEMINI_CAPTURE=1", "HOME="+tmpDir, "PATH="+tmpDir+":"+os.Getenv("PATH"))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+
+	if !strings.Contains(string(output), "\x1b[32mQuantum\x1b[0m computing\n") {
+		t.Errorf("Expected the colored output passed through, got %q", output)
+	}
+	if !strings.Contains(stderr.String(), "Loaded cached credentials.\n") {
+		t.Errorf("Expected stderr passed through, got %q", stderr.String())
+	}
+
+	var assistant map[string]interface{}
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		var record map[string]interface{}
+		if json.Unmarshal([]byte(line), &record) == nil && record["role"] == "assistant" {
+			assistant = record
+		}
+	}
+	if assistant == nil {
+		t.Fatalf("Expected an assistant record in %q", stderr.String())
+	}
+	if assistant["content"] != "Quantum computing" || assistant["stderr"] != "Loaded cached credentials." {
+		t.Errorf("Unexpected assistant record: %v", assistant)
+	}
+}
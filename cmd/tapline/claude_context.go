@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/hirosassa/tapline/pkg/claude"
+	"github.com/hirosassa/tapline/pkg/logger"
+	"github.com/hirosassa/tapline/pkg/session"
+)
+
+// pendingCompaction is a context compaction waiting for the first message after it,
+// whose prompt size is the compacted context
+type pendingCompaction struct {
+	// Trigger is "manual" (/compact) or "auto"
+	Trigger            string `json:"trigger,omitempty"`
+	TokensBefore       int    `json:"tokens_before,omitempty"`
+	CustomInstructions bool   `json:"custom_instructions,omitempty"`
+	At                 string `json:"at"`
+	// Boundary is set once the compact_boundary transcript entry has been seen
+	Boundary bool `json:"boundary,omitempty"`
+}
+
+// usageAttrs returns the token usage fields of an assistant message
+func usageAttrs(usage *claude.Usage) []slog.Attr {
+	if usage == nil {
+		return nil
+	}
+	return []slog.Attr{
+		slog.Int("input_tokens", usage.InputTokens),
+		slog.Int("output_tokens", usage.OutputTokens),
+		slog.Int("cache_creation_input_tokens", usage.CacheCreationInputTokens),
+		slog.Int("cache_read_input_tokens", usage.CacheReadInputTokens),
+		slog.Int("context_tokens", usage.ContextTokens()),
+	}
+}
+
+// trackContext follows the context size through the main conversation and logs
+// a context_compaction once the size after a compaction is known
+func (t *transcriptIngester) trackContext(state *transcriptState, entry *claude.TranscriptEntry) {
+	switch {
+	case entry.Type == claude.EntrySystem && entry.Subtype == claude.SubtypeCompactBoundary:
+		if state.Compaction != nil && state.Compaction.Boundary {
+			// Compacted again before any message; the earlier one has no size after
+			logCompaction(t.log, t.sessionID, state.Compaction, nil)
+			state.Compaction = nil
+		}
+		if state.Compaction == nil {
+			state.Compaction = &pendingCompaction{TokensBefore: state.ContextTokens, At: entry.Timestamp}
+		}
+		state.Compaction.Boundary = true
+		if meta := entry.CompactMetadata; meta != nil {
+			if meta.Trigger != "" {
+				state.Compaction.Trigger = meta.Trigger
+			}
+			if meta.PreTokens > 0 {
+				state.Compaction.TokensBefore = meta.PreTokens
+			}
+		}
+
+	case entry.Type == claude.EntryAssistant && entry.Message.Usage.ContextTokens() > 0:
+		state.ContextTokens = entry.Message.Usage.ContextTokens()
+		if state.Compaction != nil {
+			after := state.ContextTokens
+			logCompaction(t.log, t.sessionID, state.Compaction, &after)
+			state.Compaction = nil
+		}
+	}
+}
+
+// handleClaudePreCompact records a compaction that is about to happen. The transcript
+// is ingested first so that messages from before the compaction are not taken as its result.
+func handleClaudePreCompact(log *logger.Logger, sessionMgr *session.Manager, input *claude.HookInput) {
+	ingestTranscript(log, sessionMgr, input)
+
+	updateCompaction(sessionMgr, input.SessionID, func(state *transcriptState) {
+		if state.Compaction != nil {
+			logCompaction(log, input.SessionID, state.Compaction, nil)
+		}
+		state.Compaction = &pendingCompaction{
+			Trigger:            input.Trigger,
+			TokensBefore:       state.ContextTokens,
+			CustomInstructions: input.CustomInstructions != "",
+			At:                 time.Now().UTC().Format(time.RFC3339Nano),
+		}
+	})
+}
+
+// flushCompaction logs a compaction that no message followed, without its size after
+func flushCompaction(log *logger.Logger, sessionMgr *session.Manager, sessionID string) {
+	updateCompaction(sessionMgr, sessionID, func(state *transcriptState) {
+		if state.Compaction != nil {
+			logCompaction(log, sessionID, state.Compaction, nil)
+			state.Compaction = nil
+		}
+	})
+}
+
+// updateCompaction applies fn to the session's transcript state under its lock
+func updateCompaction(sessionMgr *session.Manager, sessionID string, fn func(*transcriptState)) {
+	if sessionID == "" {
+		return
+	}
+
+	stateName := transcriptStateName(sessionID)
+	err := sessionMgr.WithLock(stateName, func() error {
+		var state transcriptState
+		if err := sessionMgr.LoadState(stateName, &state); err != nil {
+			return err
+		}
+		fn(&state)
+		return sessionMgr.SaveState(stateName, state)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tapline: failed to record context compaction: %v\n", err)
+	}
+}
+
+// logCompaction writes a context_compaction event; tokensAfter is nil when unknown
+func logCompaction(log *logger.Logger, sessionID string, c *pendingCompaction, tokensAfter *int) {
+	attrs := make([]slog.Attr, 0, 5)
+	if c.Trigger != "" {
+		attrs = append(attrs, slog.String("trigger", c.Trigger))
+	}
+	if c.TokensBefore > 0 {
+		attrs = append(attrs, slog.Int("tokens_before", c.TokensBefore))
+	}
+	if tokensAfter != nil {
+		attrs = append(attrs, slog.Int("tokens_after", *tokensAfter))
+	}
+	if c.CustomInstructions {
+		attrs = append(attrs, slog.Bool("custom_instructions", true))
+	}
+	if c.At != "" {
+		attrs = append(attrs, slog.String("compacted_at", c.At))
+	}
+
+	log.Log(logger.Entry{
+		SessionID: sessionID,
+		Role:      "system",
+		Event:     "context_compaction",
+		Attrs:     attrs,
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/hirosassa/tapline/pkg/claude"
+	"github.com/hirosassa/tapline/pkg/diff"
+	"github.com/hirosassa/tapline/pkg/git"
+	"github.com/hirosassa/tapline/pkg/logger"
+	"github.com/hirosassa/tapline/pkg/redact"
+)
+
+// maxSnapshotBytes bounds the file content kept between PreToolUse and PostToolUse
+const maxSnapshotBytes = 1 << 20
+
+// fileSnapshot is a file's content before an edit tool ran
+type fileSnapshot struct {
+	Exists  bool   `json:"exists"`
+	Content string `json:"content,omitempty"`
+}
+
+// snapshotEditTarget reads the file an edit tool is about to modify, with secrets
+// redacted as they would be in the log. It returns nil when the file cannot be
+// read or is too large to keep.
+func snapshotEditTarget(redactor *redact.Redactor, input *claude.HookInput) *fileSnapshot {
+	edit, err := input.EditInput()
+	if err != nil || edit.FilePath == "" {
+		return nil
+	}
+
+	info, err := os.Stat(edit.FilePath)
+	if os.IsNotExist(err) {
+		return &fileSnapshot{Exists: false}
+	}
+	if err != nil || info.Size() > maxSnapshotBytes {
+		return nil
+	}
+
+	content, err := os.ReadFile(edit.FilePath)
+	if err != nil {
+		return nil
+	}
+	redacted, _ := redactor.Redact(string(content))
+	return &fileSnapshot{Exists: true, Content: redacted}
+}
+
+// logClaudeFileEdit logs the change an Edit, MultiEdit or Write call made as a file_edit event.
+// The diff is exact when the original content is known from the PreToolUse snapshot or the
+// tool response; otherwise it is built from the tool input and marked partial.
+func logClaudeFileEdit(log *logger.Logger, scope claudeScope, input *claude.HookInput, snapshot *fileSnapshot) {
+	edit, err := input.EditInput()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tapline: %v\n", err)
+		return
+	}
+	if edit.FilePath == "" {
+		return
+	}
+
+	path := git.RelativePath(edit.FilePath)
+	if snapshot == nil {
+		if original, ok := input.OriginalFile(); ok {
+			snapshot = &fileSnapshot{Exists: true, Content: original}
+		}
+	}
+
+	var fileDiff diff.FileDiff
+	partial := false
+	current, readErr := os.ReadFile(edit.FilePath)
+	if snapshot != nil && readErr == nil {
+		oldName := "a/" + path
+		if !snapshot.Exists {
+			oldName = diff.DevNull
+		}
+		// Both sides are redacted, as the snapshot is, so redactions do not show up as changes
+		original, _ := log.Redactor.Redact(snapshot.Content)
+		edited, _ := log.Redactor.Redact(string(current))
+		fileDiff = diff.Unified(path, oldName, "b/"+path, original, edited)
+	} else {
+		fileDiff = inputDiff(input.ToolName, path, edit)
+		partial = true
+	}
+
+	if fileDiff.Text == "" {
+		return
+	}
+
+	attrs := toolAttrs(input)
+	if partial {
+		attrs = append(attrs, slog.Bool("diff_partial", true))
+	}
+
+	log.LogFileEdit(logger.FileEdit{
+		SessionID:       scope.SessionID,
+		ParentSessionID: scope.ParentSessionID,
+		ParentEventID:   scope.ParentEventID,
+		Path:            path,
+		Diff:            fileDiff.Text,
+		Added:           fileDiff.Added,
+		Removed:         fileDiff.Removed,
+		Attrs:           attrs,
+	})
+}
+
+// inputDiff reconstructs a change from the tool input alone. Line numbers are
+// relative to the edited snippet, not the file.
+func inputDiff(toolName, path string, edit *claude.EditInput) diff.FileDiff {
+	switch toolName {
+	case claude.ToolWrite:
+		return diff.Unified(path, diff.DevNull, "b/"+path, "", edit.Content)
+	case claude.ToolMultiEdit:
+		result := diff.FileDiff{Path: path}
+		for i := range edit.Edits {
+			part := snippetDiff(path, &edit.Edits[i])
+			if part.Text == "" {
+				continue
+			}
+			if result.Text != "" {
+				// Keep one file header; later parts only contribute hunks
+				part.Text = part.Text[strings.Index(part.Text, "@@"):]
+			}
+			result.Text += part.Text
+			result.Added += part.Added
+			result.Removed += part.Removed
+		}
+		return result
+	default:
+		return snippetDiff(path, edit)
+	}
+}
+
+func snippetDiff(path string, edit *claude.EditInput) diff.FileDiff {
+	return diff.Unified(path, "a/"+path, "b/"+path, withNewline(edit.OldString), withNewline(edit.NewString))
+}
+
+func withNewline(s string) string {
+	if s == "" || strings.HasSuffix(s, "\n") {
+		return s
+	}
+	return s + "\n"
+}
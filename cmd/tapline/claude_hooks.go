@@ -1,15 +1,19 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/hirosassa/tapline/pkg/claude"
 	"github.com/hirosassa/tapline/pkg/logger"
 	"github.com/hirosassa/tapline/pkg/session"
 )
 
+const claudeService = "claude-code"
+
 func initSession() (*logger.Logger, *session.Manager) {
 	sessionMgr, err := session.NewManager()
 	if err != nil {
@@ -17,7 +21,7 @@ func initSession() (*logger.Logger, *session.Manager) {
 		os.Exit(1)
 	}
 
-	log := logger.NewLogger("claude-code", sessionMgr)
+	log := logger.NewLogger(claudeService, sessionMgr)
 
 	return log, sessionMgr
 }
@@ -93,6 +97,136 @@ func handleAssistantResponse(args []string) {
 	log.LogAssistantResponse(sessionID, response)
 }
 
+// handleClaudeHook processes a Claude Code hook payload from stdin. It always
+// exits 0 so that logging problems never block Claude Code.
+func handleClaudeHook() {
+	input, err := claude.ReadHookInput(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tapline: %v\n", err)
+		os.Exit(0)
+	}
+
+	sessionMgr, err := session.NewManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tapline: failed to initialize session manager: %v\n", err)
+		os.Exit(0)
+	}
+
+	log := logger.NewLogger(claudeService, sessionMgr)
+
+	if input.HookEventName != claude.HookNotification {
+		notePermissionAnswers(sessionMgr, input)
+	}
+
+	switch input.HookEventName {
+	case claude.HookSessionStart:
+		handleClaudeSessionStart(log, sessionMgr, input)
+	case claude.HookUserPromptSubmit:
+		resolveOpenPermissions(log, sessionMgr, input, permissionDenied)
+		log.LogUserPrompt(input.SessionID, input.Prompt)
+	case claude.HookPreToolUse:
+		handleClaudePreToolUse(log, sessionMgr, input)
+	case claude.HookPostToolUse:
+		handleClaudePostToolUse(log, sessionMgr, input)
+	case claude.HookNotification:
+		handleClaudeNotification(log, sessionMgr, input)
+	case claude.HookStop:
+		resolveOpenPermissions(log, sessionMgr, input, permissionDenied)
+		ingestTranscript(log, sessionMgr, input)
+	case claude.HookSubagentStop:
+		ingestTranscript(log, sessionMgr, input)
+	case claude.HookPreCompact:
+		handleClaudePreCompact(log, sessionMgr, input)
+	case claude.HookSessionEnd:
+		handleClaudeSessionEnd(log, sessionMgr, input)
+	default:
+		// Hook events tapline does not log
+	}
+
+	os.Exit(0)
+}
+
+func handleClaudeSessionStart(log *logger.Logger, sessionMgr *session.Manager, input *claude.HookInput) {
+	if err := sessionMgr.SetSessionFor(claudeService, log.Lineage, input.SessionID); err != nil {
+		fmt.Fprintf(os.Stderr, "tapline: failed to set session ID: %v\n", err)
+	}
+
+	if err := exportClaudeSession(log.Lineage, input.SessionID); err != nil {
+		fmt.Fprintf(os.Stderr, "tapline: failed to export session to Claude Code: %v\n", err)
+	}
+
+	metadata := map[string]string{
+		"hostname": getHostname(),
+		"cwd":      input.Cwd,
+	}
+	if input.Source != "" {
+		metadata["source"] = input.Source
+	}
+
+	log.LogSessionStart(input.SessionID, metadata)
+}
+
+func handleClaudeSessionEnd(log *logger.Logger, sessionMgr *session.Manager, input *claude.HookInput) {
+	// Pick up anything written to the transcript after the last Stop
+	ingestTranscript(log, sessionMgr, input)
+	flushCompaction(log, sessionMgr, input.SessionID)
+
+	resolveOpenPermissions(log, sessionMgr, input, permissionAbandoned)
+	log.LogSessionEnd(input.SessionID)
+
+	if err := clearClaudeState(sessionMgr, input.SessionID); err != nil {
+		fmt.Fprintf(os.Stderr, "tapline: failed to remove session state: %v\n", err)
+	}
+
+	if current, err := sessionMgr.SessionFor(claudeService, log.Lineage); err == nil && current == input.SessionID {
+		if err := sessionMgr.ClearSessionFor(claudeService, log.Lineage); err != nil {
+			fmt.Fprintf(os.Stderr, "tapline: failed to clear session: %v\n", err)
+		}
+	}
+}
+
+// clearClaudeState removes the state kept for an ended session: transcript
+// offsets, including those of its subagents, pending tools and permission
+// prompts, subagent links and the sessions nested in it
+func clearClaudeState(sessionMgr *session.Manager, sessionID string) error {
+	return errors.Join(
+		sessionMgr.DeleteStates(transcriptStateName(sessionID)),
+		sessionMgr.DeleteState(toolStateName(sessionID)),
+		sessionMgr.DeleteState(permissionStateName(sessionID)),
+		sessionMgr.DeleteState(subagentStateName(sessionID)),
+		sessionMgr.ClearNestedSessions(sessionID),
+	)
+}
+
+// exportClaudeSession makes commands Claude Code runs inherit the session, so tapline
+// invocations nested in them (e.g. a wrapped codex) link back to it. Claude Code
+// sources the file named by CLAUDE_ENV_FILE, which it sets for SessionStart hooks.
+func exportClaudeSession(lineage session.Lineage, sessionID string) error {
+	envFile := os.Getenv("CLAUDE_ENV_FILE")
+	if envFile == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(envFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) //nolint:gosec // Path is provided by Claude Code
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck // Close errors surface through the write below
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "export %s=%s\n", session.EnvSessionID, shellQuote(sessionID))
+	if parent := lineage.ParentOf(sessionID); parent != "" {
+		fmt.Fprintf(&b, "export %s=%s\n", session.EnvParentSessionID, shellQuote(parent))
+	}
+	_, err = f.WriteString(b.String())
+	return err
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
 func getHostname() string {
 	hostname, err := os.Hostname()
 	if err != nil {
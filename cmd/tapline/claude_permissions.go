@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hirosassa/tapline/pkg/claude"
+	"github.com/hirosassa/tapline/pkg/logger"
+	"github.com/hirosassa/tapline/pkg/session"
+)
+
+// Permission decisions, inferred from the hook that follows a permission prompt
+const (
+	permissionApproved = "approved"
+	permissionDenied   = "denied"
+	// permissionAbandoned means the session ended with the prompt unanswered
+	permissionAbandoned = "abandoned"
+	// permissionUnknown means the decision cannot be told without tool hooks
+	permissionUnknown = "unknown"
+)
+
+// permissionState holds the permission prompts of one Claude Code session that
+// are still waiting for the user
+type permissionState struct {
+	Waiting []permissionWait `json:"waiting,omitempty"`
+}
+
+// permissionWait is a permission prompt awaiting a decision
+type permissionWait struct {
+	EventID   string `json:"event_id"`
+	ToolName  string `json:"tool_name,omitempty"`
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	// ToolKey pairs the prompt with the tool's PostToolUse; empty without tool hooks
+	ToolKey string      `json:"tool_key,omitempty"`
+	Scope   claudeScope `json:"scope"`
+	Since   time.Time   `json:"since"`
+	// Until is when the first hook after the prompt ran, which EndedBy names.
+	// Claude Code is blocked while the prompt is open, so that hook marks the answer.
+	Until   time.Time `json:"until,omitzero"`
+	EndedBy string    `json:"ended_by,omitempty"`
+}
+
+func permissionStateName(sessionID string) string {
+	return "claude-permissions-" + sessionID
+}
+
+// updatePermissions applies fn to the session's waiting prompts under the state lock
+func updatePermissions(sessionMgr *session.Manager, sessionID string, fn func(*permissionState)) error {
+	stateName := permissionStateName(sessionID)
+	return sessionMgr.WithLock(stateName, func() error {
+		var state permissionState
+		if err := sessionMgr.LoadState(stateName, &state); err != nil {
+			return err
+		}
+		fn(&state)
+		return sessionMgr.SaveState(stateName, state)
+	})
+}
+
+// endPermissionWaits marks the end of the wait for every open prompt that has not
+// seen a hook since it was shown, stamping it with the current hook
+func endPermissionWaits(state *permissionState, hookName string, now time.Time) bool {
+	changed := false
+	for i := range state.Waiting {
+		if state.Waiting[i].Until.IsZero() {
+			state.Waiting[i].Until = now
+			state.Waiting[i].EndedBy = hookName
+			changed = true
+		}
+	}
+	return changed
+}
+
+// notePermissionAnswers ends the wait of open prompts at the current hook. Any hook
+// but a notification means Claude Code went on, so the prompt had been answered
+// even when the decision itself is only known later.
+func notePermissionAnswers(sessionMgr *session.Manager, input *claude.HookInput) {
+	// Most hooks run with no prompt open; skip the lock for them
+	var current permissionState
+	if err := sessionMgr.LoadState(permissionStateName(input.SessionID), &current); err != nil || !endPermissionWaits(&current, input.HookEventName, time.Now()) {
+		return
+	}
+
+	now := time.Now()
+	err := updatePermissions(sessionMgr, input.SessionID, func(state *permissionState) {
+		endPermissionWaits(state, input.HookEventName, now)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tapline: failed to record permission answer: %v\n", err)
+	}
+}
+
+// promptedTool finds the tool call a permission prompt is about: the earliest call
+// of the tool that has not finished and is not already waiting for permission.
+// PreToolUse fires before Claude Code checks permissions, so the call is pending.
+func promptedTool(sessionMgr *session.Manager, sessionID, toolName string, waiting []permissionWait) (string, pendingTool, bool) {
+	var tools toolHookState
+	if err := sessionMgr.LoadState(toolStateName(sessionID), &tools); err != nil {
+		return "", pendingTool{}, false
+	}
+
+	prompted := make(map[string]bool, len(waiting))
+	for _, wait := range waiting {
+		prompted[wait.ToolKey] = true
+	}
+
+	var key string
+	var found pendingTool
+	ok := false
+	for k, pending := range tools.Pending {
+		if prompted[k] || (toolName != "" && pending.Name != toolName) {
+			continue
+		}
+		if !ok || pending.StartedAt.Before(found.StartedAt) {
+			key, found, ok = k, pending, true
+		}
+	}
+	return key, found, ok
+}
+
+// handleClaudeNotification logs a permission_request when Claude Code asks the user
+// to approve a tool call. Other notifications are not logged.
+func handleClaudeNotification(log *logger.Logger, sessionMgr *session.Manager, input *claude.HookInput) {
+	toolName, ok := input.PermissionTool()
+	if !ok {
+		return
+	}
+
+	wait := permissionWait{
+		EventID:  uuid.New().String(),
+		ToolName: toolName,
+		Scope:    hookScope(sessionMgr, input.SessionID),
+		Since:    time.Now(),
+	}
+	err := updatePermissions(sessionMgr, input.SessionID, func(state *permissionState) {
+		if key, pending, found := promptedTool(sessionMgr, input.SessionID, toolName, state.Waiting); found {
+			wait.ToolKey = key
+			wait.ToolName = pending.Name
+			wait.ToolUseID = pending.ToolUseID
+		}
+		// Claude Code shows one prompt at a time, so earlier ones were answered
+		endPermissionWaits(state, input.HookEventName, wait.Since)
+		state.Waiting = append(state.Waiting, wait)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tapline: failed to record permission request: %v\n", err)
+	}
+
+	log.Log(wait.Scope.entry(logger.Entry{
+		EventID: wait.EventID,
+		Role:    "system",
+		Event:   "permission_request",
+		Content: input.Message,
+		Attrs:   wait.attrs(),
+	}))
+}
+
+// resolvePermissions logs a permission_decision for every waiting prompt that
+// match selects. Claude Code reports no answer, so the wait ends at the first hook
+// after the prompt, named in wait_ended_by, even when the decision is only
+// inferred from a later one.
+func resolvePermissions(log *logger.Logger, sessionMgr *session.Manager, input *claude.HookInput, decision string, match func(permissionWait) bool) {
+	sessionID := input.SessionID
+	// Most hooks run with no prompt open; skip the lock for them
+	var current permissionState
+	if err := sessionMgr.LoadState(permissionStateName(sessionID), &current); err != nil || len(current.Waiting) == 0 {
+		return
+	}
+
+	var resolved []permissionWait
+	err := updatePermissions(sessionMgr, sessionID, func(state *permissionState) {
+		kept := state.Waiting[:0]
+		for _, wait := range state.Waiting {
+			if match(wait) {
+				resolved = append(resolved, wait)
+			} else {
+				kept = append(kept, wait)
+			}
+		}
+		state.Waiting = kept
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tapline: failed to record permission decision: %v\n", err)
+		return
+	}
+
+	now := time.Now()
+	for _, wait := range resolved {
+		until, endedBy := wait.Until, wait.EndedBy
+		if until.IsZero() {
+			until, endedBy = now, input.HookEventName
+		}
+		attrs := append(wait.attrs(),
+			slog.String("decision", decision),
+			slog.String("request_event_id", wait.EventID),
+			slog.Int64("wait_ms", until.Sub(wait.Since).Milliseconds()),
+			slog.String("wait_ended_by", endedBy),
+		)
+		log.Log(wait.Scope.entry(logger.Entry{
+			Role:  "system",
+			Event: "permission_decision",
+			Attrs: attrs,
+		}))
+	}
+}
+
+// resolveToolPermission marks the prompt for a tool call that went on to run as approved
+func resolveToolPermission(log *logger.Logger, sessionMgr *session.Manager, input *claude.HookInput) {
+	key := toolKey(input)
+	resolvePermissions(log, sessionMgr, input, permissionApproved, func(wait permissionWait) bool {
+		return wait.ToolKey == key
+	})
+}
+
+// resolveOpenPermissions settles every prompt still waiting when the turn or session ends.
+// An approved call would have reached PostToolUse first, so a prompt still open at
+// the end of a turn was denied; without tool hooks there is no way to tell.
+func resolveOpenPermissions(log *logger.Logger, sessionMgr *session.Manager, input *claude.HookInput, decision string) {
+	if decision == permissionDenied && !toolHooksActive(sessionMgr, input.SessionID) {
+		decision = permissionUnknown
+	}
+	resolvePermissions(log, sessionMgr, input, decision, func(permissionWait) bool {
+		return true
+	})
+}
+
+func (w permissionWait) attrs() []slog.Attr {
+	attrs := []slog.Attr{slog.String("source", "hook")}
+	if w.ToolName != "" {
+		attrs = append(attrs, slog.String("tool_name", w.ToolName))
+	}
+	if w.ToolUseID != "" {
+		attrs = append(attrs, slog.String("tool_use_id", w.ToolUseID))
+	}
+	return attrs
+}
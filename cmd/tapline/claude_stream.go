@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hirosassa/tapline/pkg/claude"
+	"github.com/hirosassa/tapline/pkg/logger"
+	"github.com/hirosassa/tapline/pkg/session"
+)
+
+// claudeValueFlags are the claude options that take a value
+var claudeValueFlags = []string{
+	"--output-format", "--input-format", "--model", "--fallback-model",
+	"--allowedTools", "--allowed-tools", "--disallowedTools", "--disallowed-tools",
+	"--system-prompt", "--append-system-prompt", "--permission-mode", "--permission-prompt-tool",
+	"--max-turns", "--mcp-config", "--settings", "--setting-sources", "--add-dir",
+	"--session-id", "-r", "--resume", "--agents",
+}
+
+// wrapClaude runs claude with args, passing its output through unchanged, and logs
+// the messages of its stream-json output. The session is Claude's own session ID,
+// the one its hooks would log under. Claude owns stdout, so records go to the
+// configured log file or to stderr.
+func wrapClaude(args []string) {
+	claudePath, err := exec.LookPath("claude")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: 'claude' command not found in PATH\n")
+		os.Exit(1)
+	}
+
+	sessionMgr, err := session.NewManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: tapline session manager unavailable, logging disabled\n")
+		exitCode, _ := runTeed(claudePath, args, os.Environ(), nil)
+		os.Exit(exitCode)
+	}
+
+	if !slices.Contains(args, "stream-json") && !slices.Contains(args, "--output-format=stream-json") {
+		fmt.Fprintf(os.Stderr, "Warning: tapline logs claude messages only with --output-format stream-json\n")
+	}
+
+	recorder := &claudeStreamRecorder{
+		log:    logger.NewWrapperLogger(claudeService, sessionMgr),
+		prompt: claudePrompt(args),
+		tools:  make(map[string]streamToolUse),
+	}
+	exitCode, interrupted := runTeed(claudePath, args, os.Environ(), recorder.record)
+	if interrupted != nil {
+		recorder.ensureSession("")
+		logInterrupted(recorder.log, recorder.sessionID, interrupted, exitCode)
+	}
+	recorder.finish()
+	os.Exit(exitCode)
+}
+
+// claudePrompt returns the prompt argument of claude -p, or "" when it is read from stdin
+func claudePrompt(args []string) string {
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			positional = append(positional, args[i+1:]...)
+			i = len(args)
+		case slices.Contains(claudeValueFlags, arg):
+			i++
+		case strings.HasPrefix(arg, "-"):
+			// Boolean flags and --flag=value
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	if len(positional) == 0 {
+		return ""
+	}
+	return positional[len(positional)-1]
+}
+
+// streamToolUse is a tool call waiting for its result
+type streamToolUse struct {
+	Name      string
+	Command   string
+	StartedAt time.Time
+}
+
+// claudeStreamRecorder logs the stream-json messages of one claude -p run
+type claudeStreamRecorder struct {
+	log       *logger.Logger
+	prompt    string
+	sessionID string
+	cwd       string
+	tools     map[string]streamToolUse
+}
+
+func (r *claudeStreamRecorder) record(line []byte) {
+	msg, ok := claude.ParseStreamMessage(line)
+	if !ok {
+		return
+	}
+
+	switch msg.Type {
+	case claude.StreamSystem:
+		if msg.Subtype == claude.SubtypeInit {
+			r.start(msg)
+		}
+	case claude.StreamAssistant:
+		r.ensureSession(msg.SessionID)
+		r.logAssistant(msg)
+	case claude.StreamUser:
+		r.ensureSession(msg.SessionID)
+		r.logToolResults(msg)
+	case claude.StreamResult:
+		r.ensureSession(msg.SessionID)
+		r.logResult(msg)
+	}
+}
+
+// start opens the session at the init message and logs the prompt
+func (r *claudeStreamRecorder) start(init *claude.StreamMessage) {
+	if r.sessionID != "" {
+		return
+	}
+	r.sessionID = init.SessionID
+	if r.sessionID == "" {
+		r.sessionID = uuid.New().String()
+	}
+	r.cwd = init.Cwd
+
+	metadata := map[string]string{"mode": "print"}
+	for key, value := range map[string]string{
+		"model":               init.Model,
+		"cwd":                 init.Cwd,
+		"permission_mode":     init.PermissionMode,
+		"claude_code_version": init.ClaudeCodeVersion,
+	} {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+	r.log.LogSessionStart(r.sessionID, metadata)
+
+	if r.prompt != "" {
+		r.log.LogUserPrompt(r.sessionID, r.prompt)
+	}
+}
+
+// ensureSession starts the session if the stream had no init message
+func (r *claudeStreamRecorder) ensureSession(sessionID string) {
+	if r.sessionID == "" {
+		r.start(&claude.StreamMessage{SessionID: sessionID})
+	}
+}
+
+// finish closes the session; a run that failed before any message still logs its prompt
+func (r *claudeStreamRecorder) finish() {
+	if r.sessionID == "" && r.prompt == "" {
+		return
+	}
+	r.ensureSession("")
+	r.log.LogSessionEnd(r.sessionID)
+}
+
+func (r *claudeStreamRecorder) attrs(msg *claude.StreamMessage) []slog.Attr {
+	attrs := []slog.Attr{slog.String("source", "stream")}
+	if msg.Message != nil && msg.Message.ID != "" {
+		attrs = append(attrs, slog.String("message_id", msg.Message.ID))
+	}
+	if msg.ParentToolUseID != "" {
+		// Messages of a subagent launched by a Task call
+		attrs = append(attrs, slog.Bool("sidechain", true), slog.String("parent_tool_use_id", msg.ParentToolUseID))
+	}
+	return attrs
+}
+
+func (r *claudeStreamRecorder) logAssistant(msg *claude.StreamMessage) {
+	if msg.Message == nil {
+		return
+	}
+
+	attrs := r.attrs(msg)
+	if msg.Message.Model != "" {
+		attrs = append(attrs, slog.String("model", msg.Message.Model))
+	}
+
+	var texts []string
+	for _, block := range msg.Message.Content {
+		switch block.Type {
+		case claude.BlockText:
+			if strings.TrimSpace(block.Text) != "" {
+				texts = append(texts, block.Text)
+			}
+		case claude.BlockToolUse:
+			r.tools[block.ID] = streamToolUse{Name: block.Name, Command: claude.BashCommand(block.Input), StartedAt: time.Now()}
+			r.log.Log(logger.Entry{
+				SessionID: r.sessionID,
+				Role:      "assistant",
+				Event:     "tool_call",
+				Content:   string(block.Input),
+				Attrs: append(slices.Clip(attrs),
+					slog.String("tool_name", block.Name),
+					slog.String("tool_use_id", block.ID),
+				),
+			})
+		}
+	}
+
+	if len(texts) > 0 {
+		r.log.Log(logger.Entry{
+			SessionID: r.sessionID,
+			Role:      "assistant",
+			Content:   strings.Join(texts, "\n"),
+			Attrs:     append(attrs, usageAttrs(msg.Message.Usage)...),
+		})
+	}
+}
+
+// logToolResults logs the tool_result blocks of a user message, and Bash calls as command_exec events
+func (r *claudeStreamRecorder) logToolResults(msg *claude.StreamMessage) {
+	if msg.Message == nil {
+		return
+	}
+
+	for _, block := range msg.Message.Content {
+		if block.Type != claude.BlockToolResult {
+			continue
+		}
+
+		tool, known := r.tools[block.ToolUseID]
+		delete(r.tools, block.ToolUseID)
+
+		callAttrs := r.attrs(msg)
+		if known {
+			callAttrs = append(callAttrs, slog.String("tool_name", tool.Name))
+		}
+		callAttrs = append(callAttrs, slog.String("tool_use_id", block.ToolUseID))
+
+		var duration *time.Duration
+		attrs := append(slices.Clip(callAttrs), slog.Bool("success", !block.IsError))
+		if known {
+			elapsed := time.Since(tool.StartedAt)
+			duration = &elapsed
+			attrs = append(attrs, slog.Int64("duration_ms", elapsed.Milliseconds()))
+		}
+
+		r.log.Log(logger.Entry{
+			SessionID: r.sessionID,
+			Role:      "tool",
+			Event:     "tool_result",
+			Content:   block.ResultText(),
+			Attrs:     attrs,
+		})
+
+		if known && tool.Name == claude.ToolBash && tool.Command != "" {
+			// stream-json reports failure but not the exit code
+			r.log.LogCommandExec(logger.CommandExec{
+				SessionID: r.sessionID,
+				Command:   tool.Command,
+				Cwd:       r.cwd,
+				Duration:  duration,
+				Attrs:     callAttrs,
+			})
+		}
+	}
+}
+
+// logResult logs the closing result message with the run's duration, turns and cost
+func (r *claudeStreamRecorder) logResult(msg *claude.StreamMessage) {
+	attrs := append(r.attrs(msg),
+		slog.String("subtype", msg.Subtype),
+		slog.Bool("is_error", msg.IsError),
+		slog.Int64("duration_ms", msg.DurationMS),
+		slog.Int64("duration_api_ms", msg.DurationAPIMS),
+		slog.Int("num_turns", msg.NumTurns),
+		slog.Float64("total_cost_usd", msg.TotalCostUSD),
+	)
+	if usage := msg.Usage; usage != nil {
+		// Totals over the run; unlike a message's usage they do not measure the context
+		attrs = append(attrs,
+			slog.Int("input_tokens", usage.InputTokens),
+			slog.Int("output_tokens", usage.OutputTokens),
+			slog.Int("cache_creation_input_tokens", usage.CacheCreationInputTokens),
+			slog.Int("cache_read_input_tokens", usage.CacheReadInputTokens),
+		)
+	}
+	if len(msg.PermissionDenials) > 0 {
+		attrs = append(attrs, slog.Int("permission_denials", len(msg.PermissionDenials)))
+	}
+
+	r.log.Log(logger.Entry{
+		SessionID: r.sessionID,
+		Role:      "system",
+		Event:     "result",
+		Attrs:     attrs,
+	})
+}
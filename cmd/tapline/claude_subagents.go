@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/hirosassa/tapline/pkg/logger"
+	"github.com/hirosassa/tapline/pkg/session"
+)
+
+// maxSubagentLinks bounds the subagent runs remembered per Claude Code session
+const maxSubagentLinks = 100
+
+// claudeScope is the session a Claude Code event is logged under: the main
+// session, or a subagent run linked to the Task call that launched it
+type claudeScope struct {
+	SessionID       string `json:"session_id"`
+	ParentSessionID string `json:"parent_session_id,omitempty"`
+	ParentEventID   string `json:"parent_event_id,omitempty"`
+}
+
+// entry fills in the session fields of e
+func (s claudeScope) entry(e logger.Entry) logger.Entry {
+	e.SessionID = s.SessionID
+	e.ParentSessionID = s.ParentSessionID
+	e.ParentEventID = s.ParentEventID
+	return e
+}
+
+// subagentLink ties a subagent run to the Task tool call that launched it
+type subagentLink struct {
+	ToolUseID    string `json:"tool_use_id"`
+	EventID      string `json:"event_id,omitempty"`
+	SessionID    string `json:"session_id"`
+	SubagentType string `json:"subagent_type,omitempty"`
+	Open         bool   `json:"open"`
+}
+
+type subagentState struct {
+	Links []subagentLink `json:"links,omitempty"`
+}
+
+func subagentStateName(sessionID string) string {
+	return "claude-subagents-" + sessionID
+}
+
+// subagentSessionID derives a stable session ID for a subagent run, so hooks and
+// transcript ingestion agree on it without coordination
+func subagentSessionID(parentSessionID, key string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("tapline:claude-subagent:"+parentSessionID+"/"+key)).String()
+}
+
+// scope returns the scope of a link inside parentSessionID
+func (l subagentLink) scope(parentSessionID string) claudeScope {
+	return claudeScope{SessionID: l.SessionID, ParentSessionID: parentSessionID, ParentEventID: l.EventID}
+}
+
+// updateSubagents applies fn to the session's subagent links under the state lock
+func updateSubagents(sessionMgr *session.Manager, sessionID string, fn func(*subagentState)) error {
+	stateName := subagentStateName(sessionID)
+	return sessionMgr.WithLock(stateName, func() error {
+		var state subagentState
+		if err := sessionMgr.LoadState(stateName, &state); err != nil {
+			return err
+		}
+		fn(&state)
+		if len(state.Links) > maxSubagentLinks {
+			state.Links = state.Links[len(state.Links)-maxSubagentLinks:]
+		}
+		return sessionMgr.SaveState(stateName, state)
+	})
+}
+
+// openSubagent records a Task call that launches a subagent and logs the subagent
+// session_start. A Task already seen (from a hook or an earlier ingestion) is left as is.
+func openSubagent(log *logger.Logger, sessionMgr *session.Manager, parentSessionID, toolUseID, eventID, subagentType string) {
+	if toolUseID == "" {
+		return
+	}
+
+	var link subagentLink
+	created := false
+	err := updateSubagents(sessionMgr, parentSessionID, func(state *subagentState) {
+		for _, existing := range state.Links {
+			if existing.ToolUseID == toolUseID {
+				return
+			}
+		}
+		link = subagentLink{
+			ToolUseID:    toolUseID,
+			EventID:      eventID,
+			SessionID:    subagentSessionID(parentSessionID, toolUseID),
+			SubagentType: subagentType,
+			Open:         true,
+		}
+		state.Links = append(state.Links, link)
+		created = true
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tapline: failed to record subagent: %v\n", err)
+		return
+	}
+
+	if created {
+		metadata := map[string]string{"tool_use_id": toolUseID}
+		if subagentType != "" {
+			metadata["subagent_type"] = subagentType
+		}
+		log.Log(link.scope(parentSessionID).entry(logger.Entry{Role: "system", Event: "session_start", Metadata: metadata}))
+	}
+}
+
+// closeSubagent marks the subagent launched by a Task call as finished and logs its session_end
+func closeSubagent(log *logger.Logger, sessionMgr *session.Manager, parentSessionID, toolUseID string) {
+	if toolUseID == "" {
+		return
+	}
+
+	var link subagentLink
+	closed := false
+	err := updateSubagents(sessionMgr, parentSessionID, func(state *subagentState) {
+		for i := range state.Links {
+			if state.Links[i].ToolUseID == toolUseID && state.Links[i].Open {
+				state.Links[i].Open = false
+				link = state.Links[i]
+				closed = true
+			}
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tapline: failed to record subagent end: %v\n", err)
+		return
+	}
+
+	if closed {
+		log.Log(link.scope(parentSessionID).entry(logger.Entry{Role: "system", Event: "session_end"}))
+	}
+}
+
+// currentSubagent returns the most recently launched subagent that is still running.
+// Task calls block the main agent, so activity while one is open belongs to it.
+// Parallel Task calls cannot be told apart and are attributed to the latest one.
+func currentSubagent(sessionMgr *session.Manager, parentSessionID string) (subagentLink, bool) {
+	var state subagentState
+	if err := sessionMgr.LoadState(subagentStateName(parentSessionID), &state); err != nil {
+		return subagentLink{}, false
+	}
+	for i := len(state.Links) - 1; i >= 0; i-- {
+		if state.Links[i].Open {
+			return state.Links[i], true
+		}
+	}
+	return subagentLink{}, false
+}
+
+// hookScope returns the scope of a tool hook event: the running subagent if there is one
+func hookScope(sessionMgr *session.Manager, sessionID string) claudeScope {
+	if link, ok := currentSubagent(sessionMgr, sessionID); ok {
+		return link.scope(sessionID)
+	}
+	return claudeScope{SessionID: sessionID}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/hirosassa/tapline/pkg/claude"
+	"github.com/hirosassa/tapline/pkg/logger"
+	"github.com/hirosassa/tapline/pkg/session"
+)
+
+// toolHookState pairs PreToolUse and PostToolUse hooks of one Claude Code session.
+// Active is set once tool hooks fire, so transcript ingestion stops logging the
+// same tool calls a second time.
+type toolHookState struct {
+	Active  bool                   `json:"active"`
+	Pending map[string]pendingTool `json:"pending,omitempty"`
+}
+
+// pendingToolTTL bounds how long an unpaired PreToolUse is kept; tools that are
+// denied or interrupted never get a PostToolUse
+const pendingToolTTL = 24 * time.Hour
+
+type pendingTool struct {
+	Name      string    `json:"name"`
+	ToolUseID string    `json:"tool_use_id,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	// Snapshot is the file content before an edit tool ran
+	Snapshot *fileSnapshot `json:"snapshot,omitempty"`
+}
+
+func toolStateName(sessionID string) string {
+	return "claude-tools-" + sessionID
+}
+
+// toolKey identifies a tool invocation across its Pre and Post hooks.
+// Older Claude Code versions omit tool_use_id, so fall back to name and input.
+func toolKey(input *claude.HookInput) string {
+	if input.ToolUseID != "" {
+		return input.ToolUseID
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, input.ToolInput); err != nil {
+		compact.Write(input.ToolInput)
+	}
+	hash := sha256.Sum256(append([]byte(input.ToolName+"\x00"), compact.Bytes()...))
+	return input.ToolName + ":" + hex.EncodeToString(hash[:8])
+}
+
+// toolHooksActive reports whether tool events for the session come from hooks
+func toolHooksActive(sessionMgr *session.Manager, sessionID string) bool {
+	var state toolHookState
+	if err := sessionMgr.LoadState(toolStateName(sessionID), &state); err != nil {
+		return false
+	}
+	return state.Active
+}
+
+func handleClaudePreToolUse(log *logger.Logger, sessionMgr *session.Manager, input *claude.HookInput) {
+	stateName := toolStateName(input.SessionID)
+	err := sessionMgr.WithLock(stateName, func() error {
+		var state toolHookState
+		if err := sessionMgr.LoadState(stateName, &state); err != nil {
+			return err
+		}
+		if state.Pending == nil {
+			state.Pending = make(map[string]pendingTool)
+		}
+		now := time.Now()
+		for key, pending := range state.Pending {
+			if now.Sub(pending.StartedAt) > pendingToolTTL {
+				delete(state.Pending, key)
+			}
+		}
+		state.Active = true
+		pending := pendingTool{Name: input.ToolName, ToolUseID: input.ToolUseID, StartedAt: now}
+		// The snapshot is plain content, so it is only kept when the log itself would hold it
+		if claude.IsFileEditTool(input.ToolName) && log.StoresContent() {
+			pending.Snapshot = snapshotEditTarget(log.Redactor, input)
+		}
+		state.Pending[toolKey(input)] = pending
+		return sessionMgr.SaveState(stateName, state)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tapline: failed to record tool start: %v\n", err)
+	}
+
+	scope := hookScope(sessionMgr, input.SessionID)
+	eventID := log.Log(scope.entry(logger.Entry{
+		Role:    "assistant",
+		Event:   "tool_call",
+		Content: string(input.ToolInput),
+		Attrs:   toolAttrs(input),
+	}))
+
+	if input.ToolName == claude.ToolTask {
+		openSubagent(log, sessionMgr, input.SessionID, input.ToolUseID, eventID, claude.SubagentType(input.ToolInput))
+	}
+}
+
+func handleClaudePostToolUse(log *logger.Logger, sessionMgr *session.Manager, input *claude.HookInput) {
+	resolveToolPermission(log, sessionMgr, input)
+
+	var started pendingTool
+	paired := false
+
+	stateName := toolStateName(input.SessionID)
+	err := sessionMgr.WithLock(stateName, func() error {
+		var state toolHookState
+		if err := sessionMgr.LoadState(stateName, &state); err != nil {
+			return err
+		}
+		key := toolKey(input)
+		started, paired = state.Pending[key]
+		delete(state.Pending, key)
+		state.Active = true
+		return sessionMgr.SaveState(stateName, state)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tapline: failed to pair tool result: %v\n", err)
+	}
+
+	var duration *time.Duration
+	attrs := append(toolAttrs(input), slog.Bool("success", input.ToolSucceeded()))
+	if paired {
+		elapsed := time.Since(started.StartedAt)
+		duration = &elapsed
+		attrs = append(attrs, slog.Int64("duration_ms", elapsed.Milliseconds()))
+	}
+
+	// A finished Task's result belongs to the session that launched the subagent
+	if input.ToolName == claude.ToolTask {
+		closeSubagent(log, sessionMgr, input.SessionID, input.ToolUseID)
+	}
+	scope := hookScope(sessionMgr, input.SessionID)
+
+	log.Log(scope.entry(logger.Entry{
+		Role:    "tool",
+		Event:   "tool_result",
+		Content: input.ToolResponseText(),
+		Attrs:   attrs,
+	}))
+
+	switch {
+	case input.ToolName == claude.ToolBash:
+		logBashCommand(log, scope, input, duration)
+	case claude.IsFileEditTool(input.ToolName):
+		logClaudeFileEdit(log, scope, input, started.Snapshot)
+	}
+}
+
+// logBashCommand records a Bash tool call as a command_exec audit event
+func logBashCommand(log *logger.Logger, scope claudeScope, input *claude.HookInput, duration *time.Duration) {
+	command := input.BashCommand()
+	if command == "" {
+		return
+	}
+
+	var exitCode *int
+	if code, ok := input.ToolExitCode(); ok {
+		exitCode = &code
+	}
+
+	log.LogCommandExec(logger.CommandExec{
+		SessionID:       scope.SessionID,
+		ParentSessionID: scope.ParentSessionID,
+		ParentEventID:   scope.ParentEventID,
+		Command:         command,
+		Cwd:             input.Cwd,
+		ExitCode:        exitCode,
+		Duration:        duration,
+		Attrs:           toolAttrs(input),
+	})
+}
+
+func toolAttrs(input *claude.HookInput) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("source", "hook"),
+		slog.String("tool_name", input.ToolName),
+	}
+	if input.ToolUseID != "" {
+		attrs = append(attrs, slog.String("tool_use_id", input.ToolUseID))
+	}
+	return attrs
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/hirosassa/tapline/pkg/claude"
+	"github.com/hirosassa/tapline/pkg/logger"
+	"github.com/hirosassa/tapline/pkg/session"
+)
+
+// transcriptState records how far a Claude Code transcript has been ingested
+type transcriptState struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	// ContextTokens is the context size at the latest main conversation message
+	ContextTokens int `json:"context_tokens,omitempty"`
+	// Compaction is a compaction whose resulting context size is not known yet
+	Compaction *pendingCompaction `json:"compaction,omitempty"`
+}
+
+func transcriptStateName(sessionID string) string {
+	return "claude-transcript-" + sessionID
+}
+
+// ingestTranscript logs transcript entries written since the last ingestion.
+// The read offset is kept in session state so nothing is logged twice. On
+// SubagentStop the subagent's own transcript, when Claude Code provides one,
+// is ingested into the subagent's session as well.
+func ingestTranscript(log *logger.Logger, sessionMgr *session.Manager, input *claude.HookInput) {
+	if input.SessionID == "" {
+		return
+	}
+
+	ingester := &transcriptIngester{
+		log:        log,
+		sessionMgr: sessionMgr,
+		sessionID:  input.SessionID,
+		// Tool hooks log tool activity with timing; the transcript only fills in when they are not configured
+		withTools: !toolHooksActive(sessionMgr, input.SessionID),
+	}
+
+	if input.TranscriptPath != "" {
+		ingester.ingest(transcriptStateName(input.SessionID), input.TranscriptPath, nil)
+	}
+
+	if input.HookEventName == claude.HookSubagentStop && input.AgentTranscriptPath != "" {
+		scope := claudeScope{
+			SessionID:       subagentSessionID(input.SessionID, input.AgentID),
+			ParentSessionID: input.SessionID,
+		}
+		if link, ok := currentSubagent(sessionMgr, input.SessionID); ok {
+			scope = link.scope(input.SessionID)
+		}
+		ingester.ingest(transcriptStateName(input.SessionID)+"-"+input.AgentID, input.AgentTranscriptPath, &scope)
+	}
+}
+
+// transcriptIngester logs the entries of one Claude Code session's transcripts
+type transcriptIngester struct {
+	log        *logger.Logger
+	sessionMgr *session.Manager
+	sessionID  string
+	withTools  bool
+}
+
+// ingest reads new entries of the transcript at path. A nil scope means the
+// main transcript, where sidechain entries belong to the running subagent.
+func (t *transcriptIngester) ingest(stateName, path string, scope *claudeScope) {
+	err := t.sessionMgr.WithLock(stateName, func() error {
+		var state transcriptState
+		if err := t.sessionMgr.LoadState(stateName, &state); err != nil {
+			return err
+		}
+		if state.Path != path {
+			state.Path, state.Offset = path, 0
+		}
+
+		entries, offset, err := claude.ReadTranscript(state.Path, state.Offset)
+		if err != nil {
+			return err
+		}
+
+		for i := range entries {
+			entryScope := claudeScope{SessionID: t.sessionID}
+			switch {
+			case scope != nil:
+				entryScope = *scope
+			case entries[i].IsSidechain:
+				if link, ok := currentSubagent(t.sessionMgr, t.sessionID); ok {
+					entryScope = link.scope(t.sessionID)
+				}
+			}
+			mainChain := scope == nil && !entries[i].IsSidechain
+			t.logEntry(entryScope, &entries[i], mainChain)
+			if mainChain {
+				t.trackContext(&state, &entries[i])
+			}
+		}
+
+		state.Offset = offset
+		return t.sessionMgr.SaveState(stateName, state)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tapline: transcript ingestion failed: %v\n", err)
+	}
+}
+
+// logEntry logs the assistant text, and optionally the tool uses and tool results,
+// of one transcript entry. User prompts are not logged here; the UserPromptSubmit hook
+// already records them. Task calls in the main conversation open and close subagent runs.
+func (t *transcriptIngester) logEntry(scope claudeScope, entry *claude.TranscriptEntry, mainChain bool) {
+	if entry.IsMeta {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("source", "transcript"),
+		slog.String("message_id", entry.UUID),
+		slog.String("message_timestamp", entry.Timestamp),
+	}
+	if entry.IsSidechain {
+		attrs = append(attrs, slog.Bool("sidechain", true))
+	}
+
+	switch entry.Type {
+	case claude.EntryAssistant:
+		t.logAssistantBlocks(scope, entry, attrs, mainChain)
+	case claude.EntryUser:
+		t.logToolResultBlocks(scope, entry, attrs, mainChain)
+	default:
+		// Summaries, system and other bookkeeping entries are not conversation content
+	}
+}
+
+func (t *transcriptIngester) logAssistantBlocks(scope claudeScope, entry *claude.TranscriptEntry, attrs []slog.Attr, mainChain bool) {
+	if entry.Message.Model != "" {
+		attrs = append(attrs, slog.String("model", entry.Message.Model))
+	}
+	attrs = append(attrs, usageAttrs(entry.Message.Usage)...)
+
+	var texts []string
+	for _, block := range entry.Message.Content {
+		switch block.Type {
+		case claude.BlockText:
+			if strings.TrimSpace(block.Text) != "" {
+				texts = append(texts, block.Text)
+			}
+		case claude.BlockToolUse:
+			eventID := ""
+			if t.withTools {
+				eventID = t.logToolUse(scope, entry, block, attrs)
+			}
+			if mainChain && block.Name == claude.ToolTask {
+				openSubagent(t.log, t.sessionMgr, t.sessionID, block.ID, eventID, claude.SubagentType(block.Input))
+			}
+		}
+	}
+
+	if len(texts) > 0 {
+		t.log.Log(scope.entry(logger.Entry{
+			Role:    "assistant",
+			Content: strings.Join(texts, "\n"),
+			Attrs:   attrs,
+		}))
+	}
+}
+
+// logToolUse logs a tool_use block as a tool_call event and returns its event ID
+func (t *transcriptIngester) logToolUse(scope claudeScope, entry *claude.TranscriptEntry, block claude.Block, attrs []slog.Attr) string {
+	toolAttrs := append(slices.Clip(attrs),
+		slog.String("tool_name", block.Name),
+		slog.String("tool_use_id", block.ID),
+	)
+
+	eventID := t.log.Log(scope.entry(logger.Entry{
+		Role:    "assistant",
+		Event:   "tool_call",
+		Content: string(block.Input),
+		Attrs:   toolAttrs,
+	}))
+
+	if command := claude.BashCommand(block.Input); block.Name == claude.ToolBash && command != "" {
+		// Exit code and duration are only known from the PostToolUse hook
+		t.log.LogCommandExec(logger.CommandExec{
+			SessionID:       scope.SessionID,
+			ParentSessionID: scope.ParentSessionID,
+			ParentEventID:   scope.ParentEventID,
+			Command:         command,
+			Cwd:             entry.Cwd,
+			Attrs:           toolAttrs,
+		})
+	}
+
+	return eventID
+}
+
+func (t *transcriptIngester) logToolResultBlocks(scope claudeScope, entry *claude.TranscriptEntry, attrs []slog.Attr, mainChain bool) {
+	for _, block := range entry.Message.Content {
+		if block.Type != claude.BlockToolResult {
+			continue
+		}
+		if mainChain {
+			closeSubagent(t.log, t.sessionMgr, t.sessionID, block.ToolUseID)
+		}
+		if !t.withTools {
+			continue
+		}
+		t.log.Log(scope.entry(logger.Entry{
+			Role:    "tool",
+			Event:   "tool_result",
+			Content: block.ResultText(),
+			Attrs: append(slices.Clip(attrs),
+				slog.String("tool_use_id", block.ToolUseID),
+				slog.Bool("success", !block.IsError),
+			),
+		}))
+	}
+}
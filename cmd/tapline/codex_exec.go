@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hirosassa/tapline/pkg/codex"
+	"github.com/hirosassa/tapline/pkg/git"
+	"github.com/hirosassa/tapline/pkg/logger"
+	"github.com/hirosassa/tapline/pkg/session"
+)
+
+// codexExecValueFlags are the codex exec options that take a value
+var codexExecValueFlags = []string{
+	"-m", "--model", "-c", "--config", "-p", "--profile", "-s", "--sandbox", "-C", "--cd",
+	"-i", "--image", "--output-schema", "--color", "-o", "--output-last-message", "--add-dir",
+	"--local-provider",
+}
+
+// wrapCodexExec runs `codex exec` with args, passing its output through unchanged,
+// and logs the events of its --json output under a session of its own. Codex owns
+// stdout, so records go to the configured log file or to stderr.
+func wrapCodexExec(args []string) {
+	codexPath, err := exec.LookPath("codex")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: 'codex' command not found in PATH\n")
+		os.Exit(1)
+	}
+
+	sessionMgr, err := session.NewManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: tapline session manager unavailable, logging disabled\n")
+		exitCode, _ := runTeed(codexPath, append([]string{"exec"}, args...), os.Environ(), nil)
+		os.Exit(exitCode)
+	}
+
+	if !slices.Contains(args, "--json") && !slices.Contains(args, "--experimental-json") {
+		fmt.Fprintf(os.Stderr, "Warning: tapline logs codex exec events only with --json\n")
+	}
+
+	log := logger.NewWrapperLogger(codexService, sessionMgr)
+	sessionID := uuid.New().String()
+	log.LogSessionStart(sessionID, map[string]string{"mode": "exec"})
+
+	if prompt := codexExecPrompt(args); prompt != "" {
+		log.LogUserPrompt(sessionID, prompt)
+	}
+
+	recorder := &codexExecRecorder{
+		log:       log,
+		sessionID: sessionID,
+		cwd:       codexExecCwd(args),
+		started:   make(map[string]time.Time),
+	}
+	exitCode, interrupted := runTeed(codexPath, append([]string{"exec"}, args...), log.Lineage.Env(os.Environ(), sessionID), recorder.record)
+
+	if interrupted != nil {
+		logInterrupted(log, sessionID, interrupted, exitCode)
+	}
+	log.LogSessionEnd(sessionID)
+	if err := sessionMgr.ClearNestedSessions(sessionID); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to remove session state: %v\n", err)
+	}
+	os.Exit(exitCode)
+}
+
+// codexExecPrompt returns the prompt argument of codex exec, or "" when the prompt
+// is read from stdin
+func codexExecPrompt(args []string) string {
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			positional = append(positional, args[i+1:]...)
+			i = len(args)
+		case slices.Contains(codexExecValueFlags, arg):
+			i++
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			// Boolean flags and --flag=value
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	if len(positional) > 0 && positional[0] == "resume" {
+		positional = positional[1:]
+		if !slices.Contains(args, "--last") && len(positional) > 0 {
+			// The session to resume
+			positional = positional[1:]
+		}
+	}
+
+	if len(positional) == 0 || positional[len(positional)-1] == "-" {
+		return ""
+	}
+	return positional[len(positional)-1]
+}
+
+// codexExecCwd returns the directory codex exec runs in, given by -C/--cd
+func codexExecCwd(args []string) string {
+	cwd, _ := os.Getwd() //nolint:errcheck // An unknown working directory is logged as empty
+	for i, arg := range args {
+		switch {
+		case (arg == "-C" || arg == "--cd") && i+1 < len(args):
+			return resolveDir(cwd, args[i+1])
+		case strings.HasPrefix(arg, "--cd="):
+			return resolveDir(cwd, strings.TrimPrefix(arg, "--cd="))
+		}
+	}
+	return cwd
+}
+
+func resolveDir(cwd, dir string) string {
+	if filepath.IsAbs(dir) || cwd == "" {
+		return dir
+	}
+	return filepath.Join(cwd, dir)
+}
+
+// codexExecRecorder logs the JSON events of one codex exec run
+type codexExecRecorder struct {
+	log       *logger.Logger
+	sessionID string
+	threadID  string
+	cwd       string
+	// started holds the start time of running commands and tool calls by item ID
+	started map[string]time.Time
+}
+
+func (r *codexExecRecorder) record(line []byte) {
+	event, ok := codex.ParseExecEvent(line)
+	if !ok {
+		return
+	}
+
+	switch event.Type {
+	case codex.ExecThreadStarted:
+		r.threadID = event.ThreadID
+	case codex.ExecItemStarted:
+		if event.Item != nil {
+			r.itemStarted(event.Item)
+		}
+	case codex.ExecItemCompleted:
+		if event.Item != nil {
+			r.itemCompleted(event.Item)
+		}
+	case codex.ExecTurnCompleted:
+		r.logUsage(event.Usage)
+	case codex.ExecTurnFailed:
+		if event.Error != nil {
+			r.logError(nil, event.Error.Message)
+		}
+	case codex.ExecError:
+		r.logError(nil, event.Message)
+	}
+}
+
+func (r *codexExecRecorder) attrs(item *codex.ExecItem) []slog.Attr {
+	attrs := []slog.Attr{slog.String("source", "codex_exec")}
+	if r.threadID != "" {
+		attrs = append(attrs, slog.String("thread_id", r.threadID))
+	}
+	if item != nil && item.ID != "" {
+		attrs = append(attrs, slog.String("item_id", item.ID))
+	}
+	return attrs
+}
+
+// itemStarted logs the call of a command or tool as soon as it starts
+func (r *codexExecRecorder) itemStarted(item *codex.ExecItem) {
+	switch item.Kind() {
+	case codex.ExecCommandExecution, codex.ExecMcpToolCall:
+		r.started[item.ID] = time.Now()
+		r.logToolCall(item)
+	}
+}
+
+func (r *codexExecRecorder) itemCompleted(item *codex.ExecItem) {
+	switch item.Kind() {
+	case codex.ExecAgentMessage:
+		r.logText(item, "", item.Text)
+	case codex.ExecReasoning:
+		r.logText(item, "reasoning", item.Text)
+	case codex.ExecCommandExecution, codex.ExecMcpToolCall:
+		r.logToolResult(item)
+	case codex.ExecFileChange:
+		r.logFileChanges(item)
+	case codex.ExecWebSearch:
+		r.logToolCall(item)
+	case codex.ExecErrorItem:
+		r.logError(item, item.Message)
+	}
+}
+
+func (r *codexExecRecorder) logText(item *codex.ExecItem, event, text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	r.log.Log(logger.Entry{
+		SessionID: r.sessionID,
+		Role:      "assistant",
+		Event:     event,
+		Content:   text,
+		Attrs:     r.attrs(item),
+	})
+}
+
+func (r *codexExecRecorder) toolAttrs(item *codex.ExecItem) []slog.Attr {
+	attrs := r.attrs(item)
+	switch item.Kind() {
+	case codex.ExecMcpToolCall:
+		attrs = append(attrs, slog.String("tool_name", item.Tool), slog.String("mcp_server", item.Server))
+	default:
+		attrs = append(attrs, slog.String("tool_name", item.Kind()))
+	}
+	return attrs
+}
+
+func (r *codexExecRecorder) logToolCall(item *codex.ExecItem) {
+	content := item.Command
+	if item.Kind() == codex.ExecWebSearch {
+		content = item.Query
+	}
+	r.log.Log(logger.Entry{
+		SessionID: r.sessionID,
+		Role:      "assistant",
+		Event:     "tool_call",
+		Content:   content,
+		Attrs:     r.toolAttrs(item),
+	})
+}
+
+// logToolResult logs a finished command or tool call, and a command as a command_exec event
+func (r *codexExecRecorder) logToolResult(item *codex.ExecItem) {
+	startedAt, started := r.started[item.ID]
+	delete(r.started, item.ID)
+	if !started {
+		// Only the completion was reported
+		r.logToolCall(item)
+	}
+
+	var duration *time.Duration
+	callAttrs := r.toolAttrs(item)
+	attrs := append(slices.Clip(callAttrs), slog.Bool("success", item.Succeeded()))
+	if started {
+		elapsed := time.Since(startedAt)
+		duration = &elapsed
+		attrs = append(attrs, slog.Int64("duration_ms", elapsed.Milliseconds()))
+	}
+
+	r.log.Log(logger.Entry{
+		SessionID: r.sessionID,
+		Role:      "tool",
+		Event:     "tool_result",
+		Content:   item.AggregatedOutput,
+		Attrs:     attrs,
+	})
+
+	if item.Kind() == codex.ExecCommandExecution && item.Command != "" {
+		r.log.LogCommandExec(logger.CommandExec{
+			SessionID: r.sessionID,
+			Command:   item.Command,
+			Cwd:       r.cwd,
+			ExitCode:  item.ExitCode,
+			Duration:  duration,
+			Attrs:     callAttrs,
+		})
+	}
+}
+
+// logFileChanges logs one file_change event per file a patch touched. codex exec
+// reports which files changed but not the diff.
+func (r *codexExecRecorder) logFileChanges(item *codex.ExecItem) {
+	for _, change := range item.Changes {
+		path := change.Path
+		if !filepath.IsAbs(path) && r.cwd != "" {
+			path = filepath.Join(r.cwd, path)
+		}
+		r.log.Log(logger.Entry{
+			SessionID: r.sessionID,
+			Role:      "tool",
+			Event:     "file_change",
+			Attrs: append(r.attrs(item),
+				slog.String("file_path", git.RelativePath(path)),
+				slog.String("change_kind", change.Kind),
+				slog.Bool("success", item.Succeeded()),
+			),
+		})
+	}
+}
+
+func (r *codexExecRecorder) logUsage(usage *codex.ExecUsage) {
+	if usage == nil {
+		return
+	}
+	r.log.Log(logger.Entry{
+		SessionID: r.sessionID,
+		Role:      "system",
+		Event:     "token_count",
+		Attrs: append(r.attrs(nil),
+			slog.Int("input_tokens", usage.InputTokens),
+			slog.Int("cached_input_tokens", usage.CachedInputTokens),
+			slog.Int("output_tokens", usage.OutputTokens),
+		),
+	})
+}
+
+func (r *codexExecRecorder) logError(item *codex.ExecItem, message string) {
+	if message == "" {
+		return
+	}
+	r.log.Log(logger.Entry{
+		SessionID: r.sessionID,
+		Role:      "system",
+		Event:     "error",
+		Content:   message,
+		Attrs:     r.attrs(item),
+	})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const codexExecOutput = `{"type":"thread.started","thread_id":"0199a213-81c0-7800-8aa1-bbab2a035a53"}
+{"type":"turn.started"}
+{"type":"item.completed","item":{"id":"item_0","type":"reasoning","text":"**Checking the files**"}}
+{"type":"item.started","item":{"id":"item_1","type":"command_execution","command":"bash -lc ls","aggregated_output":"","status":"in_progress"}}
+{"type":"item.completed","item":{"id":"item_1","type":"command_execution","command":"bash -lc ls","aggregated_output":"main.go\n","exit_code":0,"status":"completed"}}
+{"type":"item.completed","item":{"id":"item_2","type":"file_change","changes":[{"path":"README.md","kind":"update"}],"status":"completed"}}
+{"type":"item.completed","item":{"id":"item_3","type":"agent_message","text":"Updated the README."}}
+{"type":"turn.completed","usage":{"input_tokens":2400,"cached_input_tokens":2000,"output_tokens":60}}
+{"type":"error","message":"stream disconnected"}
+`
+
+func TestWrapCodexExec(t *testing.T) {
+	if os.Getenv("TEST_WRAP_CODEX_EXEC") == "1" {
+		wrapCodexExec([]string{"--json", "-m", "gpt-5-codex", "Update the README"})
+		return
+	}
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "output.jsonl"), []byte(codexExecOutput), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mockCodex := `#!/bin/sh
+printf '%s\n' "$*" > "` + filepath.Join(tmpDir, "args.txt") + `"
+cat "` + filepath.Join(tmpDir, "output.jsonl") + `"
+exit 3
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "codex"), []byte(mockCodex), 0o755); err != nil { //nolint:gosec // The mock must be executable
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=TestWrapCodexExec")
+	cmd.Env = append(os.Environ(), "TEST_WRAP_CODEX_EXEC=1", "HOME="+tmpDir, "PATH="+tmpDir+":"+os.Getenv("PATH"))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Fatalf("Expected codex's exit status 3, got %v", err)
+	}
+
+	args, err := os.ReadFile(filepath.Join(tmpDir, "args.txt"))
+	if err != nil || string(args) != "exec --json -m gpt-5-codex Update the README\n" {
+		t.Errorf("Unexpected codex arguments %q (%v)", args, err)
+	}
+
+	// Codex owns stdout; tapline's records go to stderr
+	if stdout.String() != codexExecOutput {
+		t.Errorf("Expected codex output to pass through unchanged, got %q", stdout.String())
+	}
+
+	var kinds []string
+	records := map[string]map[string]interface{}{}
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		var record map[string]interface{}
+		if json.Unmarshal([]byte(line), &record) != nil || record["msg"] != "conversation" {
+			continue
+		}
+		kind, _ := record["event"].(string)
+		if kind == "" {
+			kind, _ = record["role"].(string)
+		}
+		kinds = append(kinds, kind)
+		records[kind] = record
+	}
+
+	expected := []string{"session_start", "user", "reasoning", "tool_call", "tool_result", "command_exec", "file_change", "assistant", "token_count", "error", "session_end"}
+	if strings.Join(kinds, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected records %v, got %v", expected, kinds)
+	}
+
+	if prompt := records["user"]; prompt["content"] != "Update the README" {
+		t.Errorf("Expected the prompt without flags, got %v", prompt["content"])
+	}
+	if command := records["command_exec"]; command["content"] != "bash -lc ls" || command["exit_code"] != float64(0) || command["thread_id"] != "0199a213-81c0-7800-8aa1-bbab2a035a53" {
+		t.Errorf("Unexpected command_exec record: %v", command)
+	}
+	if result := records["tool_result"]; result["success"] != true || result["content"] != "main.go\n" || result["duration_ms"] == nil {
+		t.Errorf("Unexpected tool_result record: %v", result)
+	}
+	if change := records["file_change"]; change["change_kind"] != "update" || !strings.HasSuffix(change["file_path"].(string), "README.md") {
+		t.Errorf("Unexpected file_change record: %v", change)
+	}
+	if usage := records["token_count"]; usage["input_tokens"] != float64(2400) || usage["output_tokens"] != float64(60) {
+		t.Errorf("Unexpected token_count record: %v", usage)
+	}
+	if records["error"]["content"] != "stream disconnected" {
+		t.Errorf("Unexpected error record: %v", records["error"])
+	}
+}
+
+func TestCodexExecPrompt(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--json", "Fix the tests"}, "Fix the tests"},
+		{[]string{"-m", "gpt-5", "--full-auto", "-C", "/work", "Fix the tests"}, "Fix the tests"},
+		{[]string{"--model=gpt-5", "--", "--help me"}, "--help me"},
+		{[]string{"--json", "-"}, ""},
+		{[]string{"--json"}, ""},
+		{[]string{"resume", "0199a213", "Continue"}, "Continue"},
+		{[]string{"resume", "--last", "Continue"}, "Continue"},
+		{[]string{"resume", "0199a213"}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := codexExecPrompt(tt.args); got != tt.want {
+			t.Errorf("codexExecPrompt(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"syscall"
+
+	"github.com/hirosassa/tapline/pkg/config"
+)
+
+// codexNotifyCommands returns the notify programs a notification is passed on to:
+// the command given after "--" (notify = ["tapline", "notify-codex", "--", "notifier"])
+// followed by those in the codex.notify setting
+func codexNotifyCommands(args []string) [][]string {
+	var commands [][]string
+	if i := slices.Index(args, "--"); i >= 0 && i+1 < len(args) {
+		commands = append(commands, args[i+1:])
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return append(commands, cfg.Codex.Notify...)
+}
+
+// forwardCodexNotification runs each notify program in turn, handing it the notification
+// the way Codex does: as the last argument, or on stdin if that is where it came from.
+// Their output goes to stderr, since tapline's stdout is the log. It returns the first
+// non-zero exit status so that a failing program still fails the notify call.
+func forwardCodexNotification(commands [][]string, data []byte, asArg bool) int {
+	status := 0
+	for _, argv := range commands {
+		cmd := exec.CommandContext(context.Background(), argv[0], argv[1:]...) //nolint:gosec // Commands come from the user's own notify configuration
+		switch {
+		case asArg:
+			cmd.Args = append(cmd.Args, string(data))
+		case data != nil:
+			cmd.Stdin = bytes.NewReader(data)
+		}
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+
+		err := cmd.Run()
+		if code := exitStatus(err); code != 0 {
+			fmt.Fprintf(os.Stderr, "tapline: notify command %s failed: %v\n", argv[0], err)
+			if status == 0 {
+				status = code
+			}
+		}
+	}
+	return status
+}
+
+// exitStatus maps the result of running a command to a process exit status,
+// using the shell's conventions: 128 plus the signal number for a program killed
+// by a signal and 127 for one that could not be started
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if code := exitErr.ExitCode(); code > 0 {
+			return code
+		}
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return 1
+	}
+	return 127
+}
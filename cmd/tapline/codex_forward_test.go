@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNotifyCodex_ForwardsToDownstream(t *testing.T) {
+	if os.Getenv("TEST_NOTIFY_CODEX_FORWARD") == "1" {
+		var args []string
+		for _, arg := range strings.Split(os.Getenv("TEST_NOTIFY_CODEX_ARGS"), "\x1f") {
+			if arg != "" {
+				args = append(args, arg)
+			}
+		}
+		notifyCodex(args)
+		return
+	}
+
+	tmpDir := t.TempDir()
+	argvOut := filepath.Join(tmpDir, "argv.txt")
+	stdinOut := filepath.Join(tmpDir, "stdin.txt")
+	configPath := filepath.Join(tmpDir, "config.json")
+	config := `{"codex":{"notify":[["sh","-c","cat > '` + stdinOut + `'; exit 5"]]}}`
+	if err := os.WriteFile(configPath, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := `{"type":"agent-turn-complete","turn-id":"7","last-assistant-message":"Done."}`
+	run := func(args []string, stdin string) (string, int) {
+		ctx := context.Background()
+		cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=TestNotifyCodex_ForwardsToDownstream")
+		cmd.Env = append(os.Environ(), "TEST_NOTIFY_CODEX_FORWARD=1", "TEST_NOTIFY_CODEX_ARGS="+strings.Join(args, "\x1f"),
+			"HOME="+tmpDir, "CODEX_HOME="+filepath.Join(tmpDir, ".codex"), "TAPLINE_CONFIG="+configPath)
+		cmd.Stdin = strings.NewReader(stdin)
+		var stdout bytes.Buffer
+		cmd.Stdout = &stdout
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			t.Fatalf("Failed to run notify-codex: %v", err)
+		}
+		return stdout.String(), cmd.ProcessState.ExitCode()
+	}
+
+	// From argv: the "--" command gets its own arguments plus the payload, the configured one gets it on stdin
+	script := `printf '%s\n' "$@" > '` + argvOut + `'`
+	stdout, code := run([]string{"--", "sh", "-c", script, "notifier", "--title", "Codex", payload}, "")
+	if !strings.Contains(stdout, `"content":"Done."`) {
+		t.Errorf("Expected the turn to be logged before forwarding, got %q", stdout)
+	}
+	if code != 5 {
+		t.Errorf("Expected the failing notifier's exit status 5, got %d", code)
+	}
+	got, err := os.ReadFile(argvOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "--title\nCodex\n" + payload + "\n"; string(got) != want {
+		t.Errorf("Expected downstream argv %q, got %q", want, got)
+	}
+	if data, err := os.ReadFile(stdinOut); err != nil || string(data) != "" {
+		t.Errorf("Expected no stdin for an argv notification, got %q (%v)", data, err)
+	}
+
+	// From stdin: the payload is passed on through stdin, and unparsable payloads are forwarded too
+	_, code = run(nil, "not json")
+	if code != 5 {
+		t.Errorf("Expected exit status 5, got %d", code)
+	}
+	if data, err := os.ReadFile(stdinOut); err != nil || string(data) != "not json" {
+		t.Errorf("Expected the payload on stdin, got %q (%v)", data, err)
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	if code := exitStatus(nil); code != 0 {
+		t.Errorf("Expected 0, got %d", code)
+	}
+	if code := exitStatus(exec.CommandContext(context.Background(), "sh", "-c", "exit 3").Run()); code != 3 {
+		t.Errorf("Expected 3, got %d", code)
+	}
+	if code := exitStatus(exec.CommandContext(context.Background(), filepath.Join(t.TempDir(), "missing")).Run()); code != 127 {
+		t.Errorf("Expected 127 for a missing program, got %d", code)
+	}
+	if code := exitStatus(exec.CommandContext(context.Background(), "sh", "-c", "kill -TERM $$").Run()); code != 143 {
+		t.Errorf("Expected 143 for a program killed by SIGTERM, got %d", code)
+	}
+}
@@ -4,15 +4,29 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/hirosassa/tapline/pkg/logger"
 	"github.com/hirosassa/tapline/pkg/session"
 )
 
+const codexService = "codex-cli"
+
+// CodexEvent is a notification from the Codex CLI notify hook
 type CodexEvent struct {
-	Type string          `json:"type"`
+	Type string `json:"type"`
+
+	// agent-turn-complete
+	ThreadID             string   `json:"thread-id,omitempty"`
+	TurnID               string   `json:"turn-id,omitempty"`
+	Cwd                  string   `json:"cwd,omitempty"`
+	InputMessages        []string `json:"input-messages,omitempty"`
+	LastAssistantMessage string   `json:"last-assistant-message,omitempty"`
+
+	// Data holds the payload of notifications in the older wrapped format
 	Data json.RawMessage `json:"data,omitempty"`
 }
 
@@ -20,31 +34,41 @@ type AgentTurnCompleteData struct {
 	Response string `json:"response"`
 }
 
-func notifyCodex() {
-	if isTerminal(os.Stdin) {
-		os.Exit(0)
+// notifyCodex logs a Codex CLI notification and passes it on to the notify programs
+// tapline stands in for (see forwardCodexNotification). Codex passes the notification
+// JSON as the last argument (notify = ["tapline", "notify-codex"]); it is read from
+// stdin when no argument holds JSON.
+func notifyCodex(args []string) {
+	data, fromArg, ok := readCodexNotification(args)
+	if fromArg {
+		args = args[:len(args)-1]
 	}
 
-	data, err := io.ReadAll(os.Stdin)
-	if err != nil {
-		os.Exit(0)
+	if ok {
+		logCodexNotification(data)
 	}
 
+	os.Exit(forwardCodexNotification(codexNotifyCommands(args), data, fromArg))
+}
+
+// logCodexNotification logs one notification; anything unexpected is ignored so
+// that Codex is never disturbed by tapline
+func logCodexNotification(data []byte) {
 	var event CodexEvent
 	if err := json.Unmarshal(data, &event); err != nil {
-		os.Exit(0)
+		return
 	}
 
 	sessionMgr, err := session.NewManager()
 	if err != nil {
-		os.Exit(0)
+		return
 	}
 
-	log := logger.NewLogger("codex-cli", sessionMgr)
+	log := logger.NewLogger(codexService, sessionMgr)
 
 	switch event.Type {
 	case "agent-turn-complete":
-		handleAgentTurnComplete(log, sessionMgr, event.Data)
+		handleAgentTurnComplete(log, sessionMgr, &event)
 	case "session_start":
 		handleSessionStart(log, sessionMgr)
 	case "session_end":
@@ -52,15 +76,45 @@ func notifyCodex() {
 	default:
 		// Unknown event type, ignore
 	}
+}
+
+// readCodexNotification returns the notification JSON from the last argument or stdin,
+// and whether it was the last argument
+func readCodexNotification(args []string) (data []byte, fromArg, ok bool) {
+	if n := len(args); n > 0 {
+		if arg := strings.TrimSpace(args[n-1]); strings.HasPrefix(arg, "{") {
+			return []byte(args[n-1]), true, true
+		}
+	}
+
+	if isTerminal(os.Stdin) {
+		return nil, false, false
+	}
 
-	os.Exit(0)
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, false, false
+	}
+	return data, false, true
 }
 
-func handleAgentTurnComplete(log *logger.Logger, sessionMgr *session.Manager, data json.RawMessage) {
-	// Try multiple possible data structures
+func handleAgentTurnComplete(log *logger.Logger, sessionMgr *session.Manager, event *CodexEvent) {
+	if event.TurnID != "" || len(event.InputMessages) > 0 || event.LastAssistantMessage != "" {
+		sessionID, ok := codexSession(log, sessionMgr, event.ThreadID)
+		if !ok {
+			return
+		}
+		// The rollout holds the whole turn; the notification only its first and last messages
+		if !ingestCodexRollout(log, sessionMgr, sessionID, event) {
+			logCodexTurn(log, sessionID, event)
+		}
+		return
+	}
+
+	// Older formats wrap the response in data
 	// Format 1: {response: "..."}
 	var eventData1 AgentTurnCompleteData
-	if err := json.Unmarshal(data, &eventData1); err == nil && eventData1.Response != "" {
+	if err := json.Unmarshal(event.Data, &eventData1); err == nil && eventData1.Response != "" {
 		logResponse(log, sessionMgr, eventData1.Response)
 		return
 	}
@@ -69,22 +123,84 @@ func handleAgentTurnComplete(log *logger.Logger, sessionMgr *session.Manager, da
 	var eventData2 struct {
 		Data AgentTurnCompleteData `json:"data"`
 	}
-	if err := json.Unmarshal(data, &eventData2); err == nil && eventData2.Data.Response != "" {
+	if err := json.Unmarshal(event.Data, &eventData2); err == nil && eventData2.Data.Response != "" {
 		logResponse(log, sessionMgr, eventData2.Data.Response)
 		return
 	}
 }
 
-func logResponse(log *logger.Logger, sessionMgr *session.Manager, response string) {
-	if !sessionMgr.HasActiveSession() {
-		newSessionID := uuid.New().String()
-		if err := sessionMgr.SetSessionID(newSessionID); err != nil {
-			return
-		}
+// logCodexTurn logs the user messages that started a turn and the assistant's final
+// message. Event IDs are derived from the turn ID, so a repeated notification for
+// the same turn produces the same events.
+func logCodexTurn(log *logger.Logger, sessionID string, event *CodexEvent) {
+	var attrs []slog.Attr
+	if event.TurnID != "" {
+		attrs = append(attrs, slog.String("turn_id", event.TurnID))
+	}
+	if event.ThreadID != "" {
+		attrs = append(attrs, slog.String("thread_id", event.ThreadID))
+	}
+	if event.Cwd != "" {
+		attrs = append(attrs, slog.String("cwd", event.Cwd))
+	}
+
+	for i, message := range event.InputMessages {
+		log.Log(logger.Entry{
+			SessionID: sessionID,
+			EventID:   codexTurnEventID(sessionID, event.TurnID, "input", i),
+			Role:      "user",
+			Content:   message,
+			Attrs:     attrs,
+		})
+	}
+
+	if event.LastAssistantMessage != "" {
+		log.Log(logger.Entry{
+			SessionID: sessionID,
+			EventID:   codexTurnEventID(sessionID, event.TurnID, "response", 0),
+			Role:      "assistant",
+			Content:   event.LastAssistantMessage,
+			Attrs:     attrs,
+		})
+	}
+}
+
+// codexTurnEventID derives the event ID of a turn's message; without a turn ID a new one is generated
+func codexTurnEventID(sessionID, turnID, kind string, index int) string {
+	if turnID == "" {
+		return ""
+	}
+	name := fmt.Sprintf("tapline:codex-turn:%s/%s/%s/%d", sessionID, turnID, kind, index)
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(name)).String()
+}
+
+// codexThreadSessionID derives the session of a Codex thread, so every notification
+// of the thread lands in it without any shared state
+func codexThreadSessionID(threadID string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("tapline:codex-thread:"+threadID)).String()
+}
+
+// codexSession returns the session a notification is logged under: the thread's
+// own session, or for notifications without a thread ID the current Codex
+// session, starting one if there is none
+func codexSession(log *logger.Logger, sessionMgr *session.Manager, threadID string) (string, bool) {
+	if threadID != "" {
+		return codexThreadSessionID(threadID), true
 	}
 
-	sessionID, err := sessionMgr.GetSessionID()
+	sessionID, err := sessionMgr.SessionFor(codexService, log.Lineage)
 	if err != nil {
+		sessionID = uuid.New().String()
+		if err := sessionMgr.SetSessionFor(codexService, log.Lineage, sessionID); err != nil {
+			return "", false
+		}
+	}
+	return sessionID, true
+}
+
+func logResponse(log *logger.Logger, sessionMgr *session.Manager, response string) {
+	sessionID, ok := codexSession(log, sessionMgr, "")
+	if !ok {
 		return
 	}
 
@@ -93,26 +209,25 @@ func logResponse(log *logger.Logger, sessionMgr *session.Manager, response strin
 
 func handleSessionStart(log *logger.Logger, sessionMgr *session.Manager) {
 	newSessionID := uuid.New().String()
-	if err := sessionMgr.SetSessionID(newSessionID); err != nil {
+	if err := sessionMgr.SetSessionFor(codexService, log.Lineage, newSessionID); err != nil {
 		return
 	}
 	log.LogSessionStart(newSessionID, nil)
 }
 
 func handleSessionEnd(log *logger.Logger, sessionMgr *session.Manager) {
-	if !sessionMgr.HasActiveSession() {
-		return
-	}
-
-	sessionID, err := sessionMgr.GetSessionID()
+	sessionID, err := sessionMgr.SessionFor(codexService, log.Lineage)
 	if err != nil {
 		return
 	}
 
 	log.LogSessionEnd(sessionID)
-	if err := sessionMgr.ClearSession(); err != nil {
+	if err := sessionMgr.ClearSessionFor(codexService, log.Lineage); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to clear session: %v\n", err)
 	}
+	if err := sessionMgr.ClearNestedSessions(sessionID); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to remove session state: %v\n", err)
+	}
 }
 
 func isTerminal(f *os.File) bool {
@@ -10,11 +10,14 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/hirosassa/tapline/pkg/logger"
+	"github.com/hirosassa/tapline/pkg/session"
 )
 
 func TestNotifyCodex_NoStdin(t *testing.T) {
 	if os.Getenv("TEST_NOTIFY_CODEX_NO_STDIN") == "1" {
-		notifyCodex()
+		notifyCodex(nil)
 		return
 	}
 
@@ -29,7 +32,7 @@ func TestNotifyCodex_NoStdin(t *testing.T) {
 
 func TestNotifyCodex_InvalidJSON(t *testing.T) {
 	if os.Getenv("TEST_NOTIFY_CODEX_INVALID_JSON") == "1" {
-		notifyCodex()
+		notifyCodex(nil)
 		return
 	}
 
@@ -45,7 +48,7 @@ func TestNotifyCodex_InvalidJSON(t *testing.T) {
 
 func TestNotifyCodex_UnknownEventType(t *testing.T) {
 	if os.Getenv("TEST_NOTIFY_CODEX_UNKNOWN_EVENT") == "1" {
-		notifyCodex()
+		notifyCodex(nil)
 		return
 	}
 
@@ -71,7 +74,7 @@ func TestNotifyCodex_AgentTurnComplete_Format1(t *testing.T) {
 	defer os.Setenv("HOME", oldHome)
 
 	if os.Getenv("TEST_NOTIFY_CODEX_FORMAT1") == "1" {
-		notifyCodex()
+		notifyCodex(nil)
 		return
 	}
 
@@ -111,7 +114,7 @@ func TestNotifyCodex_AgentTurnComplete_Format2(t *testing.T) {
 	defer os.Setenv("HOME", oldHome)
 
 	if os.Getenv("TEST_NOTIFY_CODEX_FORMAT2") == "1" {
-		notifyCodex()
+		notifyCodex(nil)
 		return
 	}
 
@@ -148,7 +151,7 @@ func TestNotifyCodex_SessionStart(t *testing.T) {
 	tmpDir := t.TempDir()
 
 	if os.Getenv("TEST_NOTIFY_CODEX_SESSION_START") == "1" {
-		notifyCodex()
+		notifyCodex(nil)
 		return
 	}
 
@@ -175,7 +178,7 @@ func TestNotifyCodex_SessionEnd(t *testing.T) {
 	tmpDir := t.TempDir()
 
 	if os.Getenv("TEST_NOTIFY_CODEX_SESSION_END") == "1" {
-		notifyCodex()
+		notifyCodex(nil)
 		return
 	}
 
@@ -227,7 +230,7 @@ func TestHandleAgentTurnComplete_EmptyResponse(t *testing.T) {
 		sessionDir := filepath.Join(tmpDir, ".tapline")
 		os.MkdirAll(sessionDir, 0o750)
 
-		notifyCodex()
+		notifyCodex(nil)
 		return
 	}
 
@@ -257,7 +260,7 @@ func TestHandleSessionEnd_NoActiveSession(t *testing.T) {
 	defer os.Setenv("HOME", oldHome)
 
 	if os.Getenv("TEST_SESSION_END_NO_ACTIVE") == "1" {
-		notifyCodex()
+		notifyCodex(nil)
 		return
 	}
 
@@ -302,7 +305,7 @@ func TestAgentTurnCompleteData_UnmarshalJSON(t *testing.T) {
 
 func TestNotifyCodex_ReadError(t *testing.T) {
 	if os.Getenv("TEST_NOTIFY_CODEX_READ_ERROR") == "1" {
-		notifyCodex()
+		notifyCodex(nil)
 		return
 	}
 
@@ -318,3 +321,296 @@ func TestNotifyCodex_ReadError(t *testing.T) {
 		t.Errorf("Expected clean exit even with read error, got: %v", err)
 	}
 }
+
+func TestNotifyCodex_NestedInParentSession(t *testing.T) {
+	if os.Getenv("TEST_NOTIFY_CODEX_NESTED") == "1" {
+		notifyCodex(nil)
+		return
+	}
+
+	tmpDir := t.TempDir()
+	sessionDir := filepath.Join(tmpDir, ".tapline")
+	os.MkdirAll(sessionDir, 0o750)
+	sessionFile := filepath.Join(sessionDir, "session_id")
+	os.WriteFile(sessionFile, []byte("claude-parent"), 0o600)
+
+	event := CodexEvent{Type: "agent-turn-complete"}
+	event.Data, _ = json.Marshal(AgentTurnCompleteData{Response: "Nested response"})
+	eventJSON, _ := json.Marshal(event)
+
+	run := func() map[string]interface{} {
+		ctx := context.Background()
+		cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=TestNotifyCodex_NestedInParentSession")
+		cmd.Env = append(os.Environ(), "TEST_NOTIFY_CODEX_NESTED=1", "HOME="+tmpDir, "TAPLINE_SESSION_ID=claude-parent")
+		cmd.Stdin = bytes.NewReader(eventJSON)
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("Expected success, got error: %v\nOutput: %s", err, output)
+		}
+		for _, line := range strings.Split(string(output), "\n") {
+			var record map[string]interface{}
+			if json.Unmarshal([]byte(line), &record) == nil && record["msg"] == "conversation" {
+				return record
+			}
+		}
+		t.Fatalf("No conversation record in output: %s", output)
+		return nil
+	}
+
+	first := run()
+	if first["session_id"] == "claude-parent" || first["parent_session_id"] != "claude-parent" {
+		t.Errorf("Expected a separate codex session linked to the parent, got %v", first)
+	}
+
+	if second := run(); second["session_id"] != first["session_id"] {
+		t.Errorf("Expected later turns to reuse the nested session, got %v and %v", first["session_id"], second["session_id"])
+	}
+
+	if data, _ := os.ReadFile(sessionFile); string(data) != "claude-parent" {
+		t.Errorf("Expected the parent session file to be kept, got %q", data)
+	}
+}
+
+func TestNotifyCodex_ArgvTurnComplete(t *testing.T) {
+	if os.Getenv("TEST_NOTIFY_CODEX_ARGV") == "1" {
+		notifyCodex([]string{os.Getenv("TEST_NOTIFY_CODEX_PAYLOAD")})
+		return
+	}
+
+	tmpDir := t.TempDir()
+	payload := `{"type":"agent-turn-complete","thread-id":"thread-1","turn-id":"12345","cwd":"/work",` +
+		`"input-messages":["Rename foo to bar","and update the tests"],"last-assistant-message":"Renamed foo to bar."}`
+
+	run := func() []map[string]interface{} {
+		ctx := context.Background()
+		cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=TestNotifyCodex_ArgvTurnComplete")
+		cmd.Env = append(os.Environ(), "TEST_NOTIFY_CODEX_ARGV=1", "TEST_NOTIFY_CODEX_PAYLOAD="+payload,
+			"HOME="+tmpDir, "CODEX_HOME="+filepath.Join(tmpDir, ".codex"))
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("Expected success, got error: %v\nOutput: %s", err, output)
+		}
+		var records []map[string]interface{}
+		for _, line := range strings.Split(string(output), "\n") {
+			var record map[string]interface{}
+			if json.Unmarshal([]byte(line), &record) == nil && record["msg"] == "conversation" {
+				records = append(records, record)
+			}
+		}
+		return records
+	}
+
+	records := run()
+	if len(records) != 3 {
+		t.Fatalf("Expected 2 prompts and 1 response, got %v", records)
+	}
+	if records[0]["role"] != "user" || records[0]["content"] != "Rename foo to bar" || records[1]["content"] != "and update the tests" {
+		t.Errorf("Unexpected prompt records: %v", records[:2])
+	}
+	response := records[2]
+	if response["role"] != "assistant" || response["content"] != "Renamed foo to bar." {
+		t.Errorf("Unexpected response record: %v", response)
+	}
+	if response["turn_id"] != "12345" || response["thread_id"] != "thread-1" || response["cwd"] != "/work" {
+		t.Errorf("Expected turn fields, got %v", response)
+	}
+	if response["session_id"] != codexThreadSessionID("thread-1") {
+		t.Errorf("Expected the thread's session, got %v", response["session_id"])
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, ".tapline", "session_id")); !os.IsNotExist(err) {
+		t.Errorf("Expected the shared session file to be left alone, got %v", err)
+	}
+
+	// A repeated notification for the same turn yields the same event IDs
+	again := run()
+	for i := range records {
+		if records[i]["event_id"] != again[i]["event_id"] {
+			t.Errorf("Expected stable event ID for record %d, got %v and %v", i, records[i]["event_id"], again[i]["event_id"])
+		}
+	}
+	if records[0]["event_id"] == records[1]["event_id"] {
+		t.Error("Expected distinct event IDs per message")
+	}
+}
+
+func TestCodexSession_PerThread(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	sessionMgr, err := session.NewManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := &logger.Logger{}
+
+	a, _ := codexSession(log, sessionMgr, "thread-a")
+	b, _ := codexSession(log, sessionMgr, "thread-b")
+	if a == b {
+		t.Errorf("Expected distinct sessions per thread, got %s for both", a)
+	}
+	if again, _ := codexSession(log, sessionMgr, "thread-a"); again != a {
+		t.Errorf("Expected a stable session per thread, got %s and %s", a, again)
+	}
+	if sessionMgr.HasActiveSession() {
+		t.Error("Expected no shared session for threaded notifications")
+	}
+}
+
+func TestNotifyCodex_IngestsRollout(t *testing.T) {
+	if os.Getenv("TEST_NOTIFY_CODEX_ROLLOUT") == "1" {
+		notifyCodex([]string{os.Getenv("TEST_NOTIFY_CODEX_PAYLOAD")})
+		return
+	}
+
+	tmpDir := t.TempDir()
+	codexHome := filepath.Join(tmpDir, ".codex")
+	rolloutDir := filepath.Join(codexHome, "sessions", "2025", "12", "06")
+	if err := os.MkdirAll(rolloutDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	rolloutPath := filepath.Join(rolloutDir, "rollout-2025-12-06T10-00-00-thread-2.jsonl")
+
+	firstTurn := `{"timestamp":"2025-12-06T10:00:00Z","type":"session_meta","payload":{"id":"thread-2","timestamp":"2025-12-06T10:00:00Z","cwd":"/work"}}
+{"timestamp":"2025-12-06T10:00:00Z","type":"turn_context","payload":{"cwd":"/work","model":"gpt-5-codex"}}
+{"timestamp":"2025-12-06T10:00:01Z","type":"event_msg","payload":{"type":"user_message","message":"Add a greeting file"}}
+{"timestamp":"2025-12-06T10:00:02Z","type":"response_item","payload":{"type":"reasoning","summary":[{"type":"summary_text","text":"Creating the file"}]}}
+{"timestamp":"2025-12-06T10:00:02Z","type":"response_item","payload":{"type":"custom_tool_call","name":"apply_patch","input":"*** Begin Patch\n*** Add File: hello.txt\n+hello\n*** End Patch","call_id":"call_1"}}
+{"timestamp":"2025-12-06T10:00:03Z","type":"response_item","payload":{"type":"custom_tool_call_output","call_id":"call_1","output":"Success. Updated the following files:\nA hello.txt\n"}}
+{"timestamp":"2025-12-06T10:00:04Z","type":"response_item","payload":{"type":"function_call","name":"shell","arguments":"{\"command\":[\"bash\",\"-lc\",\"cat hello.txt\"]}","call_id":"call_2"}}
+{"timestamp":"2025-12-06T10:00:05Z","type":"response_item","payload":{"type":"function_call_output","call_id":"call_2","output":"{\"output\":\"hello\\n\",\"metadata\":{\"exit_code\":0,\"duration_seconds\":0.5}}"}}
+{"timestamp":"2025-12-06T10:00:06Z","type":"event_msg","payload":{"type":"token_count","info":{"total_token_usage":{"input_tokens":3000,"output_tokens":80,"total_tokens":3080},"last_token_usage":{"input_tokens":1500,"cached_input_tokens":1000,"output_tokens":40,"total_tokens":1540},"model_context_window":272000}}}
+{"timestamp":"2025-12-06T10:00:07Z","type":"response_item","payload":{"type":"message","role":"assistant","content":[{"type":"output_text","text":"Added hello.txt."}]}}
+`
+	if err := os.WriteFile(rolloutPath, []byte(firstTurn), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(payload string) []map[string]interface{} {
+		ctx := context.Background()
+		cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=TestNotifyCodex_IngestsRollout")
+		cmd.Env = append(os.Environ(), "TEST_NOTIFY_CODEX_ROLLOUT=1", "TEST_NOTIFY_CODEX_PAYLOAD="+payload,
+			"HOME="+tmpDir, "CODEX_HOME="+codexHome)
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("Expected success, got error: %v\nOutput: %s", err, output)
+		}
+		var records []map[string]interface{}
+		for _, line := range strings.Split(string(output), "\n") {
+			var record map[string]interface{}
+			if json.Unmarshal([]byte(line), &record) == nil && record["msg"] == "conversation" {
+				records = append(records, record)
+			}
+		}
+		return records
+	}
+
+	records := run(`{"type":"agent-turn-complete","thread-id":"thread-2","turn-id":"1","input-messages":["Add a greeting file"],"last-assistant-message":"Added hello.txt."}`)
+
+	var kinds []string
+	for _, record := range records {
+		kind, _ := record["event"].(string)
+		if kind == "" {
+			kind, _ = record["role"].(string)
+		}
+		kinds = append(kinds, kind)
+		if record["source"] != "rollout" || record["thread_id"] != "thread-2" {
+			t.Errorf("Expected rollout record of thread-2, got %v", record)
+		}
+	}
+	expected := []string{"user", "reasoning", "tool_call", "tool_result", "file_edit", "tool_call", "tool_result", "command_exec", "token_count", "assistant"}
+	if strings.Join(kinds, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected records %v, got %v", expected, kinds)
+	}
+
+	if edit := records[4]; edit["file_path"] != "/work/hello.txt" || edit["lines_added"] != float64(1) {
+		t.Errorf("Unexpected file_edit record: %v", edit)
+	}
+	if command := records[7]; command["content"] != "cat hello.txt" || command["exit_code"] != float64(0) || command["duration_ms"] != float64(500) || command["cwd"] != "/work" {
+		t.Errorf("Unexpected command_exec record: %v", command)
+	}
+	if result := records[6]; result["tool_name"] != "shell" || result["success"] != true || result["content"] != "hello\n" {
+		t.Errorf("Unexpected tool_result record: %v", result)
+	}
+	if tokens := records[8]; tokens["input_tokens"] != float64(1500) || tokens["total_tokens"] != float64(3080) {
+		t.Errorf("Unexpected token_count record: %v", tokens)
+	}
+	if assistant := records[9]; assistant["content"] != "Added hello.txt." || assistant["model"] != "gpt-5-codex" {
+		t.Errorf("Unexpected assistant record: %v", assistant)
+	}
+
+	// Only lines added since the last notification are logged
+	secondTurn := `{"timestamp":"2025-12-06T10:01:00Z","type":"event_msg","payload":{"type":"user_message","message":"Thanks"}}` + "\n"
+	if err := os.WriteFile(rolloutPath, []byte(firstTurn+secondTurn), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	records = run(`{"type":"agent-turn-complete","thread-id":"thread-2","turn-id":"2","input-messages":["Thanks"]}`)
+	if len(records) != 1 || records[0]["content"] != "Thanks" {
+		t.Errorf("Expected only the new prompt, got %v", records)
+	}
+}
+
+func TestNotifyCodex_AlternatingThreads(t *testing.T) {
+	if os.Getenv("TEST_NOTIFY_CODEX_THREADS") == "1" {
+		notifyCodex([]string{os.Getenv("TEST_NOTIFY_CODEX_PAYLOAD")})
+		return
+	}
+
+	tmpDir := t.TempDir()
+	codexHome := filepath.Join(tmpDir, ".codex")
+	rolloutDir := filepath.Join(codexHome, "sessions", "2025", "12", "06")
+	if err := os.MkdirAll(rolloutDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	rollouts := map[string]string{}
+	appendPrompt := func(thread, prompt string) {
+		path := filepath.Join(rolloutDir, "rollout-2025-12-06T10-00-00-"+thread+".jsonl")
+		if rollouts[thread] == "" {
+			rollouts[thread] = `{"timestamp":"2025-12-06T10:00:00Z","type":"session_meta","payload":{"id":"` + thread + `","cwd":"/work"}}` + "\n"
+		}
+		rollouts[thread] += `{"timestamp":"2025-12-06T10:00:01Z","type":"event_msg","payload":{"type":"user_message","message":"` + prompt + `"}}` + "\n"
+		if err := os.WriteFile(path, []byte(rollouts[thread]), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run := func(thread string) []string {
+		payload := `{"type":"agent-turn-complete","thread-id":"` + thread + `","turn-id":"1"}`
+		ctx := context.Background()
+		cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=TestNotifyCodex_AlternatingThreads")
+		cmd.Env = append(os.Environ(), "TEST_NOTIFY_CODEX_THREADS=1", "TEST_NOTIFY_CODEX_PAYLOAD="+payload,
+			"HOME="+tmpDir, "CODEX_HOME="+codexHome)
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("Expected success, got error: %v\nOutput: %s", err, output)
+		}
+		var prompts []string
+		for _, line := range strings.Split(string(output), "\n") {
+			var record map[string]interface{}
+			if json.Unmarshal([]byte(line), &record) == nil && record["role"] == "user" {
+				prompts = append(prompts, record["content"].(string))
+			}
+		}
+		return prompts
+	}
+
+	// A, B, then A again: each notification logs only its thread's new lines
+	steps := []struct{ thread, prompt string }{
+		{"thread-a", "first in A"},
+		{"thread-b", "first in B"},
+		{"thread-a", "second in A"},
+		{"thread-b", "second in B"},
+	}
+	for _, step := range steps {
+		appendPrompt(step.thread, step.prompt)
+		if prompts := run(step.thread); len(prompts) != 1 || prompts[0] != step.prompt {
+			t.Errorf("Expected only %q from %s, got %v", step.prompt, step.thread, prompts)
+		}
+	}
+}
+
+func TestReadCodexNotification_PrefersArgv(t *testing.T) {
+	data, fromArg, ok := readCodexNotification([]string{"--flag", ` {"type":"agent-turn-complete"}`})
+	if !ok || !fromArg || !strings.Contains(string(data), "agent-turn-complete") {
+		t.Errorf("Expected JSON from the last argument, got %q (%v, %v)", data, fromArg, ok)
+	}
+}
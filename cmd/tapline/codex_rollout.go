@@ -0,0 +1,322 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hirosassa/tapline/pkg/codex"
+	"github.com/hirosassa/tapline/pkg/diff"
+	"github.com/hirosassa/tapline/pkg/git"
+	"github.com/hirosassa/tapline/pkg/logger"
+	"github.com/hirosassa/tapline/pkg/session"
+)
+
+// rolloutState records how far a Codex rollout has been ingested
+type rolloutState struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	// ThreadID is the Codex session ID from the rollout's session metadata
+	ThreadID string `json:"thread_id,omitempty"`
+	Cwd      string `json:"cwd,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// Calls are tool calls whose output has not been read yet, by call ID
+	Calls map[string]rolloutCall `json:"calls,omitempty"`
+}
+
+// rolloutCall is a tool call waiting for its output
+type rolloutCall struct {
+	Name      string `json:"name"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Command   string `json:"command,omitempty"`
+	Patch     string `json:"patch,omitempty"`
+}
+
+// rolloutStateName names the ingestion state of a Codex thread's rollout. It is
+// kept per thread, so switching between threads never loses a read offset.
+func rolloutStateName(threadID string) string {
+	return "codex-rollout-" + threadID
+}
+
+// ingestCodexRollout logs the rollout lines Codex wrote since the last notification.
+// It reports whether the rollout was read; without one the notification itself is
+// all there is to log.
+func ingestCodexRollout(log *logger.Logger, sessionMgr *session.Manager, sessionID string, event *CodexEvent) bool {
+	// The rollout cannot be identified without the thread
+	if event.ThreadID == "" {
+		return false
+	}
+
+	dir, err := codex.SessionsDir()
+	if err != nil {
+		return false
+	}
+
+	ingested := false
+	stateName := rolloutStateName(event.ThreadID)
+	err = sessionMgr.WithLock(stateName, func() error {
+		state := rolloutState{ThreadID: event.ThreadID}
+		if err := sessionMgr.LoadState(stateName, &state); err != nil {
+			return err
+		}
+
+		if state.Path == "" {
+			path, err := codex.FindRollout(dir, event.ThreadID)
+			if err != nil {
+				return err
+			}
+			state.Path = path
+		}
+
+		lines, offset, err := codex.ReadRollout(state.Path, state.Offset)
+		if err != nil {
+			return err
+		}
+
+		ingester := &rolloutIngester{log: log, sessionID: sessionID, state: &state}
+		for i := range lines {
+			ingester.logLine(&lines[i])
+		}
+
+		state.Offset = offset
+		ingested = true
+		return sessionMgr.SaveState(stateName, state)
+	})
+	if err != nil && !errors.Is(err, codex.ErrNoRollout) {
+		fmt.Fprintf(os.Stderr, "tapline: rollout ingestion failed: %v\n", err)
+	}
+	return ingested
+}
+
+// rolloutIngester logs the lines of one Codex rollout
+type rolloutIngester struct {
+	log       *logger.Logger
+	sessionID string
+	state     *rolloutState
+}
+
+func (r *rolloutIngester) logLine(line *codex.Line) {
+	item := &line.Item
+	switch line.Type {
+	case codex.LineSessionMeta:
+		if line.Meta.Cwd != "" {
+			r.state.Cwd = line.Meta.Cwd
+		}
+		if r.state.ThreadID == "" {
+			r.state.ThreadID = line.Meta.ID
+		}
+	case codex.LineTurnContext:
+		if item.Cwd != "" {
+			r.state.Cwd = item.Cwd
+		}
+		if item.Model != "" {
+			r.state.Model = item.Model
+		}
+	case codex.LineEventMsg:
+		switch item.Type {
+		case codex.EventUserMessage:
+			r.logMessage(line, "user", "", item.Message)
+		case codex.EventTokenCount:
+			r.logTokenCount(line)
+		}
+	case codex.LineResponseItem:
+		r.logResponseItem(line)
+	}
+}
+
+func (r *rolloutIngester) logResponseItem(line *codex.Line) {
+	item := &line.Item
+	switch {
+	case item.Type == codex.ItemMessage && item.Role == "assistant":
+		r.logMessage(line, "assistant", "", item.Text())
+	case item.Type == codex.ItemMessage && item.Role == "user" && line.Legacy:
+		// Older rollouts have no user_message events; injected context is tagged, prompts are not
+		if text := item.Text(); !strings.HasPrefix(strings.TrimSpace(text), "<") {
+			r.logMessage(line, "user", "", text)
+		}
+	case item.Type == codex.ItemReasoning:
+		r.logMessage(line, "assistant", "reasoning", item.SummaryText())
+	case item.IsToolCall():
+		r.logToolCall(line)
+	case item.IsToolOutput():
+		r.logToolOutput(line)
+	}
+}
+
+func (r *rolloutIngester) attrs(line *codex.Line) []slog.Attr {
+	attrs := []slog.Attr{slog.String("source", "rollout")}
+	if r.state.ThreadID != "" {
+		attrs = append(attrs, slog.String("thread_id", r.state.ThreadID))
+	}
+	if line.Timestamp != "" {
+		attrs = append(attrs, slog.String("message_timestamp", line.Timestamp))
+	}
+	return attrs
+}
+
+func (r *rolloutIngester) logMessage(line *codex.Line, role, event, content string) {
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+
+	attrs := r.attrs(line)
+	if role == "assistant" && r.state.Model != "" {
+		attrs = append(attrs, slog.String("model", r.state.Model))
+	}
+
+	r.log.Log(logger.Entry{
+		SessionID: r.sessionID,
+		Role:      role,
+		Event:     event,
+		Content:   content,
+		Attrs:     attrs,
+	})
+}
+
+func (r *rolloutIngester) logTokenCount(line *codex.Line) {
+	info := line.Item.Info
+	if info == nil {
+		return
+	}
+
+	last := info.LastTokenUsage
+	attrs := append(r.attrs(line),
+		slog.Int("input_tokens", last.InputTokens),
+		slog.Int("cached_input_tokens", last.CachedInputTokens),
+		slog.Int("output_tokens", last.OutputTokens),
+		slog.Int("reasoning_output_tokens", last.ReasoningOutputTokens),
+		slog.Int("total_tokens", info.TotalTokenUsage.TotalTokens),
+	)
+	if info.ModelContextWindow > 0 {
+		attrs = append(attrs, slog.Int("model_context_window", info.ModelContextWindow))
+	}
+
+	r.log.Log(logger.Entry{
+		SessionID: r.sessionID,
+		Role:      "system",
+		Event:     "token_count",
+		Attrs:     attrs,
+	})
+}
+
+// logToolCall logs a tool call and keeps it until its output shows up
+func (r *rolloutIngester) logToolCall(line *codex.Line) {
+	item := &line.Item
+	attrs := append(r.attrs(line), slog.String("tool_name", item.ToolName()))
+	if item.CallID != "" {
+		attrs = append(attrs, slog.String("tool_use_id", item.CallID))
+	}
+
+	r.log.Log(logger.Entry{
+		SessionID: r.sessionID,
+		Role:      "assistant",
+		Event:     "tool_call",
+		Content:   item.ToolInput(),
+		Attrs:     attrs,
+	})
+
+	if item.CallID == "" {
+		return
+	}
+	call := rolloutCall{Name: item.ToolName(), Timestamp: line.Timestamp}
+	if patch, ok := item.Patch(); ok {
+		call.Patch = patch
+	} else if command, ok := item.ShellCommand(); ok {
+		call.Command = command
+	}
+	if r.state.Calls == nil {
+		r.state.Calls = make(map[string]rolloutCall)
+	}
+	r.state.Calls[item.CallID] = call
+}
+
+// logToolOutput logs a tool result, and the command it ran or the files it patched
+func (r *rolloutIngester) logToolOutput(line *codex.Line) {
+	item := &line.Item
+	call, known := r.state.Calls[item.CallID]
+	delete(r.state.Calls, item.CallID)
+
+	output := item.ToolOutput()
+	duration := output.Duration
+	if duration == nil && known {
+		duration = elapsedBetween(call.Timestamp, line.Timestamp)
+	}
+
+	callAttrs := r.attrs(line)
+	if known {
+		callAttrs = append(callAttrs, slog.String("tool_name", call.Name))
+	}
+	callAttrs = append(callAttrs, slog.String("tool_use_id", item.CallID))
+
+	attrs := slices.Clip(callAttrs)
+	if output.Success != nil {
+		attrs = append(attrs, slog.Bool("success", *output.Success))
+	}
+	if duration != nil {
+		attrs = append(attrs, slog.Int64("duration_ms", duration.Milliseconds()))
+	}
+
+	r.log.Log(logger.Entry{
+		SessionID: r.sessionID,
+		Role:      "tool",
+		Event:     "tool_result",
+		Content:   output.Text,
+		Attrs:     attrs,
+	})
+
+	switch {
+	case call.Patch != "" && (output.Success == nil || *output.Success):
+		r.logPatch(call.Patch, callAttrs)
+	case call.Command != "":
+		r.log.LogCommandExec(logger.CommandExec{
+			SessionID: r.sessionID,
+			Command:   call.Command,
+			Cwd:       r.state.Cwd,
+			ExitCode:  output.ExitCode,
+			Duration:  duration,
+			Attrs:     callAttrs,
+		})
+	}
+}
+
+// logPatch logs one file_edit per file an applied patch touched
+func (r *rolloutIngester) logPatch(patch string, attrs []slog.Attr) {
+	diffs, err := diff.ParseApplyPatch(patch)
+	if err != nil {
+		return
+	}
+
+	for _, fileDiff := range diffs {
+		path := fileDiff.Path
+		if !filepath.IsAbs(path) && r.state.Cwd != "" {
+			path = filepath.Join(r.state.Cwd, path)
+		}
+		r.log.LogFileEdit(logger.FileEdit{
+			SessionID: r.sessionID,
+			Path:      git.RelativePath(path),
+			Diff:      fileDiff.Text,
+			Added:     fileDiff.Added,
+			Removed:   fileDiff.Removed,
+			Attrs:     attrs,
+		})
+	}
+}
+
+// elapsedBetween returns the time between two rollout timestamps, or nil if either is unparsable
+func elapsedBetween(start, end string) *time.Duration {
+	from, err := time.Parse(time.RFC3339Nano, start)
+	if err != nil {
+		return nil
+	}
+	to, err := time.Parse(time.RFC3339Nano, end)
+	if err != nil {
+		return nil
+	}
+	elapsed := to.Sub(from)
+	return &elapsed
+}
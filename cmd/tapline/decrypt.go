@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hirosassa/tapline/pkg/encrypt"
+)
+
+func handleDecrypt(args []string) {
+	fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	keyPath := fs.String("key", os.Getenv("TAPLINE_SECRET_KEY_FILE"), "secret key file (default $TAPLINE_SECRET_KEY_FILE)")
+	//nolint:errcheck // ExitOnError handles parse failures
+	fs.Parse(args)
+
+	if *keyPath == "" {
+		fmt.Fprintln(os.Stderr, "decrypt requires --key or TAPLINE_SECRET_KEY_FILE")
+		os.Exit(1)
+	}
+
+	decryptor, err := encrypt.LoadDecryptor(*keyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load secret key: %v\n", err)
+		os.Exit(1)
+	}
+
+	inputs := fs.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+
+	failed := false
+	for _, input := range inputs {
+		if err := decryptFile(decryptor, input, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", input, err)
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// decryptFile decrypts each line of a JSON Lines log; "-" reads stdin.
+// Lines that cannot be decrypted are passed through and reported.
+func decryptFile(decryptor *encrypt.Decryptor, path string, out io.Writer) error {
+	in, closeInput, err := openInput(path)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	reader := bufio.NewReader(in)
+	writer := bufio.NewWriter(out)
+
+	lineNum := 0
+	failures := 0
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			lineNum++
+			trimmed := trimNewline(line)
+			plain, err := decryptor.DecryptLine(trimmed)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "line %d: %v\n", lineNum, err)
+				failures++
+				plain = trimmed
+			}
+			if _, err := writer.Write(append(plain, '\n')); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d line(s) could not be decrypted", failures)
+	}
+	return nil
+}
+
+func openInput(path string) (io.Reader, func(), error) {
+	if path == "-" {
+		return os.Stdin, func() {}, nil
+	}
+	f, err := os.Open(path) //nolint:gosec // Log path is chosen by the user
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func() { f.Close() }, nil //nolint:errcheck // Read-only file
+}
+
+func trimNewline(line []byte) []byte {
+	for len(line) > 0 && (line[len(line)-1] == '\n' || line[len(line)-1] == '\r') {
+		line = line[:len(line)-1]
+	}
+	return line
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hirosassa/tapline/pkg/encrypt"
+)
+
+func TestDecrypt_EncryptedUserPrompt(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if os.Getenv("TEST_DECRYPT_USER_PROMPT") == "1" {
+		handleUserPrompt([]string{"top", "secret", "prompt"})
+		return
+	}
+
+	secretKey, publicKey, err := encrypt.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionDir := filepath.Join(tmpDir, ".tapline")
+	os.MkdirAll(sessionDir, 0o750)
+	os.WriteFile(filepath.Join(sessionDir, "session_id"), []byte("test-session-id"), 0o600)
+
+	ctx := context.Background()
+	cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=TestDecrypt_EncryptedUserPrompt")
+	cmd.Env = append(os.Environ(),
+		"TEST_DECRYPT_USER_PROMPT=1",
+		"HOME="+tmpDir,
+		"TAPLINE_ENCRYPTION_RECIPIENT="+publicKey,
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Expected success, got error: %v", err)
+	}
+
+	if strings.Contains(string(output), "top secret prompt") {
+		t.Fatalf("Expected encrypted output, got: %s", output)
+	}
+
+	logFile := filepath.Join(tmpDir, "conversation.log")
+	os.WriteFile(logFile, output, 0o600)
+
+	decryptor, err := encrypt.NewDecryptor(secretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := decryptFile(decryptor, logFile, &buf); err != nil {
+		t.Fatalf("Failed to decrypt log: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), `"content":"top secret prompt"`) {
+		t.Errorf("Expected decrypted prompt, got: %s", buf.String())
+	}
+}
+
+func TestDecrypt_WrongKeyReportsFailure(t *testing.T) {
+	_, publicKey, _ := encrypt.GenerateKey()
+	otherSecret, _, _ := encrypt.GenerateKey()
+
+	enc, _ := encrypt.NewEncryptor(publicKey)
+	ciphertext, _ := enc.Encrypt([]byte("hello"))
+
+	logFile := filepath.Join(t.TempDir(), "conversation.log")
+	os.WriteFile(logFile, []byte(`{"content":"`+ciphertext+`"}`+"\n"), 0o600)
+
+	decryptor, _ := encrypt.NewDecryptor(otherSecret)
+
+	var buf bytes.Buffer
+	if err := decryptFile(decryptor, logFile, &buf); err == nil {
+		t.Error("Expected error when decrypting with the wrong key")
+	}
+	if !strings.Contains(buf.String(), ciphertext) {
+		t.Errorf("Expected undecryptable line to be passed through, got: %s", buf.String())
+	}
+}
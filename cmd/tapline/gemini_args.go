@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hirosassa/tapline/pkg/gemini"
+	"github.com/hirosassa/tapline/pkg/wrap"
+)
+
+// geminiArgs is a gemini command line split into the prompt and the options
+// by the gemini wrap profile
+type geminiArgs struct {
+	wrap.Invocation
+}
+
+// parseGeminiArgs splits args into the prompt and the options. Positional words
+// form the prompt, as gemini joins them, unless -p or -i gave one.
+func parseGeminiArgs(profile *wrap.Profile, args []string) geminiArgs {
+	return geminiArgs{profile.ParseArgs(args)}
+}
+
+// promptOnly reports whether -p/--prompt gave the prompt, which runs gemini non-interactively
+func (a geminiArgs) promptOnly() bool {
+	return a.PromptFlag == "-p" || a.PromptFlag == "--prompt"
+}
+
+// interactive reports whether -i/--prompt-interactive gave the prompt, which keeps
+// the UI open after it
+func (a geminiArgs) interactive() bool {
+	return a.PromptFlag == "-i" || a.PromptFlag == "--prompt-interactive"
+}
+
+// jsonOutput reports whether the user asked gemini for a JSON result
+func (a geminiArgs) jsonOutput() bool {
+	return a.Value("-o", "--output-format") == gemini.OutputFormatJSON
+}
+
+// injectJSON reports whether tapline asks gemini for a JSON result to log its
+// stats. JSON output is only available for non-interactive runs, with -p/--prompt
+// or piped input, and an output format the user chose is left alone.
+func (a geminiArgs) injectJSON(piped bool) bool {
+	if a.Value("-o", "--output-format") != "" || a.interactive() {
+		return false
+	}
+	return a.promptOnly() || piped
+}
+
+// pipedPrompt returns the prompt a tool receives along with piped input: the
+// input followed by the prompt argument, as gemini and similar CLIs combine them
+func pipedPrompt(prompt string, stdin []byte) string {
+	piped := strings.TrimSpace(string(stdin))
+	switch {
+	case piped == "":
+		return prompt
+	case prompt == "":
+		return piped
+	default:
+		return piped + "\n\n" + prompt
+	}
+}
+
+// pipedInputWait is how long gemini waits for piped input before deciding that
+// nothing was piped
+const pipedInputWait = 500 * time.Millisecond
+
+// readPipedInput reads piped input as gemini does before it starts: to the end,
+// unless nothing arrives within wait. It returns the input read and a reader
+// that replays it followed by anything stdin delivers later.
+func readPipedInput(stdin io.Reader, wait time.Duration) (data []byte, replay io.Reader) {
+	chunks := make(chan []byte)
+	go func() {
+		defer close(chunks)
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := stdin.Read(buf)
+			if n > 0 {
+				chunks <- bytes.Clone(buf[:n])
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	timeout := timer.C
+
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				return data, bytes.NewReader(data)
+			}
+			data = append(data, chunk...)
+			// Once input arrives it is read to the end
+			timeout = nil
+		case <-timeout:
+			return data, io.MultiReader(bytes.NewReader(data), &chanReader{chunks: chunks})
+		}
+	}
+}
+
+// chanReader reads the chunks sent on a channel until it is closed
+type chanReader struct {
+	chunks <-chan []byte
+	rest   []byte
+}
+
+func (r *chanReader) Read(p []byte) (int, error) {
+	for len(r.rest) == 0 {
+		chunk, ok := <-r.chunks
+		if !ok {
+			return 0, io.EOF
+		}
+		r.rest = chunk
+	}
+	n := copy(p, r.rest)
+	r.rest = r.rest[n:]
+	return n, nil
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hirosassa/tapline/pkg/logger"
+	"github.com/hirosassa/tapline/pkg/redact"
+	"github.com/hirosassa/tapline/pkg/session"
+	"github.com/hirosassa/tapline/pkg/term"
+)
+
+// executeGeminiPTY runs gemini's interactive UI under a pseudo-terminal, with the
+// user's terminal in raw mode and window resizes passed on, and records its turns.
+// interrupted is the signal that stopped gemini, if any, and err is set only
+// when gemini could not be started under a PTY.
+func executeGeminiPTY(args, env []string, turns *geminiTurnRecorder) (exitCode int, interrupted os.Signal, err error) {
+	geminiPath, err := exec.LookPath("gemini")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: 'gemini' command not found in PATH\n")
+		return 1, nil, nil
+	}
+
+	ctx := context.Background()
+	cmd := exec.CommandContext(ctx, geminiPath, args...)
+	cmd.Env = env
+
+	pty, err := term.Start(cmd)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer pty.Close() //nolint:errcheck // Only read and written while gemini runs
+
+	// gemini leads its own session, so its process ID is its group ID. Ctrl-C
+	// reaches it through the PTY; signals sent to tapline are forwarded.
+	process := forwardSignals(cmd, false, false)
+
+	stopResize := term.WatchSize(os.Stdin, pty)
+	defer stopResize()
+
+	if state, err := term.MakeRaw(os.Stdin); err == nil {
+		defer term.Restore(os.Stdin, state) //nolint:errcheck // Nothing to do if the terminal cannot be restored
+	}
+
+	go func() {
+		io.Copy(pty, io.TeeReader(os.Stdin, geminiKeys{turns})) //nolint:errcheck // Ends when gemini exits
+	}()
+	// The copy ends with EIO or EOF once gemini has exited
+	io.Copy(os.Stdout, io.TeeReader(pty, turns)) //nolint:errcheck // See above
+
+	exitCode, interrupted = process.wait()
+	return exitCode, interrupted, nil
+}
+
+// geminiTurn is a prompt submitted in the interactive UI and the output that followed it
+type geminiTurn struct {
+	Prompt   string `json:"prompt,omitempty"`
+	Response string `json:"response,omitempty"`
+}
+
+// geminiTurnRecorder splits an interactive session into turns. Prompts are
+// rebuilt from the keys typed until Enter; the response is the text the UI left
+// on screen before the next prompt. Both are best effort: the UI's completion
+// menus and editing shortcuts beyond the basic ones are not followed.
+type geminiTurnRecorder struct {
+	mu      sync.Mutex
+	input   []rune
+	keys    keyParser
+	history []string
+	recall  int
+	screen  *term.Screen
+	turns   []geminiTurn
+
+	// checkpoint, when set, is given the turns so far after each prompt and
+	// periodically while output arrives
+	checkpoint     func([]geminiTurn)
+	lastCheckpoint time.Time
+}
+
+// newGeminiTurnRecorder returns a recorder; with prompted, the output before the
+// first submitted prompt answers the prompt given on the command line
+func newGeminiTurnRecorder(prompted bool) *geminiTurnRecorder {
+	r := &geminiTurnRecorder{}
+	if prompted {
+		r.turns = append(r.turns, geminiTurn{})
+		r.screen = &term.Screen{}
+	}
+	return r
+}
+
+// Write records output of the UI
+func (r *geminiTurnRecorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.screen == nil {
+		return len(p), nil
+	}
+
+	n, err := r.screen.Write(p)
+	if time.Since(r.lastCheckpoint) >= partialResponseInterval {
+		r.saveCheckpoint()
+	}
+	return n, err
+}
+
+func (r *geminiTurnRecorder) saveCheckpoint() {
+	if r.checkpoint == nil {
+		return
+	}
+
+	turns := slices.Clone(r.turns)
+	if r.screen != nil && len(turns) > 0 {
+		turns[len(turns)-1].Response = geminiScreenText(r.screen.Text())
+	}
+	r.checkpoint(turns)
+	r.lastCheckpoint = time.Now()
+}
+
+// geminiKeys records the keys typed into the UI
+type geminiKeys struct {
+	r *geminiTurnRecorder
+}
+
+func (k geminiKeys) Write(p []byte) (int, error) {
+	k.r.mu.Lock()
+	defer k.r.mu.Unlock()
+
+	k.r.keys.parse(p, k.r.key)
+	return len(p), nil
+}
+
+func (r *geminiTurnRecorder) key(key rune, sequence string) {
+	switch {
+	case sequence == "A" || sequence == "B":
+		r.recallHistory(sequence == "A")
+	case sequence != "":
+		// Other cursor and function keys
+	case key == '\r' || key == '\n':
+		if r.keys.pasting {
+			r.input = append(r.input, '\n')
+		} else if n := len(r.input); n > 0 && r.input[n-1] == '\\' {
+			// A trailing backslash turns Enter into a newline
+			r.input[n-1] = '\n'
+		} else {
+			r.submit()
+		}
+	case key == 0x7f || key == '\b':
+		if len(r.input) > 0 {
+			r.input = r.input[:len(r.input)-1]
+		}
+	case key == 0x03 || key == 0x15:
+		// Ctrl-C and Ctrl-U clear the input
+		r.input = r.input[:0]
+	case key == 0x17:
+		// Ctrl-W deletes the last word
+		trimmed := strings.TrimRightFunc(string(r.input), unicode.IsSpace)
+		r.input = []rune(strings.TrimRightFunc(trimmed, func(c rune) bool { return !unicode.IsSpace(c) }))
+	case key >= 0x20:
+		r.input = append(r.input, key)
+	}
+}
+
+// recallHistory replaces the input with an earlier prompt, as the arrow keys do in the UI
+func (r *geminiTurnRecorder) recallHistory(older bool) {
+	if older {
+		r.recall = max(r.recall-1, 0)
+	} else {
+		r.recall = min(r.recall+1, len(r.history))
+	}
+	r.input = r.input[:0]
+	if r.recall < len(r.history) {
+		r.input = append(r.input, []rune(r.history[r.recall])...)
+	}
+}
+
+func (r *geminiTurnRecorder) submit() {
+	prompt := strings.TrimSpace(string(r.input))
+	r.input = r.input[:0]
+	if prompt == "" {
+		return
+	}
+	r.history = append(r.history, prompt)
+	r.recall = len(r.history)
+
+	r.closeTurn()
+	r.turns = append(r.turns, geminiTurn{Prompt: prompt})
+	r.screen = &term.Screen{}
+	r.saveCheckpoint()
+}
+
+func (r *geminiTurnRecorder) closeTurn() {
+	if r.screen != nil && len(r.turns) > 0 {
+		r.turns[len(r.turns)-1].Response = geminiScreenText(r.screen.Text())
+	}
+	r.screen = nil
+}
+
+// finish closes the open turn and returns all turns
+func (r *geminiTurnRecorder) finish() []geminiTurn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closeTurn()
+	return r.turns
+}
+
+// geminiScreenText removes the frames the UI draws around its input box and
+// panels from rendered output
+func geminiScreenText(text string) string {
+	isFrame := func(r rune) bool { return r >= 0x2500 && r <= 0x257f }
+
+	var lines []string
+	for line := range strings.SplitSeq(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if first, _ := utf8.DecodeRuneInString(trimmed); isFrame(first) {
+			line = strings.TrimFunc(trimmed, func(r rune) bool { return isFrame(r) || unicode.IsSpace(r) })
+			if line == "" {
+				continue
+			}
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// logGeminiTurns logs the turns of an interactive session. They are logged
+// when the session ends, as log lines written into the running UI would
+// corrupt its display. status applies to the last turn; the others completed.
+func logGeminiTurns(log *logger.Logger, sessionID string, turns []geminiTurn, status string) {
+	for i, turn := range turns {
+		attrs := []slog.Attr{slog.String("source", "pty"), slog.Int("turn", i+1)}
+		if turn.Prompt != "" {
+			log.Log(logger.Entry{SessionID: sessionID, Role: "user", Content: turn.Prompt, Attrs: attrs})
+		}
+		if turn.Response != "" {
+			turnStatus := responseComplete
+			if i == len(turns)-1 {
+				turnStatus = status
+			}
+			log.Log(logger.Entry{
+				SessionID: sessionID,
+				Role:      "assistant",
+				Content:   turn.Response,
+				Attrs:     append(attrs, slog.String("status", turnStatus)),
+			})
+		}
+	}
+}
+
+// redactGeminiTurns returns a copy of turns with secrets redacted as they would be in the log
+func redactGeminiTurns(redactor *redact.Redactor, turns []geminiTurn) []geminiTurn {
+	if turns == nil {
+		return nil
+	}
+	redacted := make([]geminiTurn, len(turns))
+	for i, turn := range turns {
+		redacted[i].Prompt, _ = redactor.Redact(turn.Prompt)
+		redacted[i].Response, _ = redactor.Redact(turn.Response)
+	}
+	return redacted
+}
+
+// geminiCheckpointState names the state holding the unlogged turns of the
+// interactive sessions running under sessionID, keyed by wrapper process ID
+func geminiCheckpointState(sessionID string) string {
+	return "gemini-pty-" + sessionID
+}
+
+// geminiCheckpoints keeps the turns of running interactive sessions, so a
+// wrapper killed before it could log them leaves them for the next one
+type geminiCheckpoints map[int][]geminiTurn
+
+// updateGeminiCheckpoint stores the turns of this process, or removes them when turns is nil
+func updateGeminiCheckpoint(mgr *session.Manager, sessionID string, turns []geminiTurn) error {
+	name := geminiCheckpointState(sessionID)
+	return mgr.WithLock(name, func() error {
+		checkpoints := geminiCheckpoints{}
+		if err := mgr.LoadState(name, &checkpoints); err != nil {
+			return err
+		}
+		if turns == nil {
+			delete(checkpoints, os.Getpid())
+		} else {
+			checkpoints[os.Getpid()] = turns
+		}
+		return saveGeminiCheckpoints(mgr, name, checkpoints)
+	})
+}
+
+// recoverGeminiTurns logs, as interrupted, the checkpointed turns of wrappers
+// under sessionID that are no longer running
+func recoverGeminiTurns(log *logger.Logger, mgr *session.Manager, sessionID string) error {
+	name := geminiCheckpointState(sessionID)
+	return mgr.WithLock(name, func() error {
+		checkpoints := geminiCheckpoints{}
+		if err := mgr.LoadState(name, &checkpoints); err != nil {
+			return err
+		}
+
+		recovered := false
+		for _, pid := range slices.Sorted(maps.Keys(checkpoints)) {
+			if processRunning(pid) {
+				continue
+			}
+			logGeminiTurns(log, sessionID, checkpoints[pid], responseInterrupted)
+			delete(checkpoints, pid)
+			recovered = true
+		}
+		if !recovered {
+			return nil
+		}
+		return saveGeminiCheckpoints(mgr, name, checkpoints)
+	})
+}
+
+// saveGeminiCheckpoints stores the checkpoints, removing the state once no session is left
+func saveGeminiCheckpoints(mgr *session.Manager, name string, checkpoints geminiCheckpoints) error {
+	if len(checkpoints) == 0 {
+		return mgr.DeleteState(name)
+	}
+	return mgr.SaveState(name, checkpoints)
+}
+
+// processRunning reports whether a process with pid exists
+func processRunning(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+// keyParser splits terminal input into keys and escape sequences
+type keyParser struct {
+	escape   bool
+	sequence []byte
+	partial  []byte
+	pasting  bool
+}
+
+// parse calls onKey with each typed rune, or with the final part of each cursor
+// or function key sequence such as "A" for up
+func (p *keyParser) parse(data []byte, onKey func(key rune, sequence string)) {
+	if len(p.partial) > 0 {
+		data = append(p.partial, data...)
+		p.partial = nil
+	}
+
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && !utf8.FullRune(data) {
+			p.partial = append([]byte(nil), data...)
+			return
+		}
+		data = data[size:]
+
+		switch {
+		case p.sequence != nil:
+			p.sequence = append(p.sequence, byte(r))
+			if r >= 0x40 && r <= 0x7e && len(p.sequence) > 1 {
+				p.endSequence(onKey)
+			}
+		case p.escape:
+			p.escape = false
+			if r == '[' || r == 'O' {
+				p.sequence = []byte{byte(r)}
+			}
+		case r == 0x1b:
+			p.escape = true
+		default:
+			onKey(r, "")
+		}
+	}
+}
+
+func (p *keyParser) endSequence(onKey func(key rune, sequence string)) {
+	sequence := string(p.sequence[1:])
+	p.sequence = nil
+
+	switch sequence {
+	case "200~":
+		p.pasting = true
+	case "201~":
+		p.pasting = false
+	default:
+		// Modifiers such as "1;5A" keep the key as the final byte
+		onKey(0, sequence[len(sequence)-1:])
+	}
+}
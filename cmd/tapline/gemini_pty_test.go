@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hirosassa/tapline/pkg/logger"
+	"github.com/hirosassa/tapline/pkg/session"
+	"github.com/hirosassa/tapline/pkg/term"
+)
+
+func TestGeminiTurnRecorder(t *testing.T) {
+	r := newGeminiTurnRecorder(false)
+	keys := geminiKeys{r}
+	write := func(w interface{ Write([]byte) (int, error) }, s string) {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(r, "Welcome to Gemini\r\n╭──────╮\r\n│ > │\r\n╰──────╯\r\n")
+	write(keys, "Helo\x7flo\r")
+	write(r, "\x1b[2K\x1b[1A\x1b[2K\x1b[G✦ Hi there!\r\n")
+	write(keys, "\x1b[200~line one\rline two\x1b[201~\r")
+	write(r, "✦ Two lines\r\n")
+	write(keys, "\x1b[A\x1b[A\x1b[B\r")
+	write(r, "✦ Again\r\n")
+	write(keys, "caf\xc3")
+	write(keys, "\xa9 \x17tea\\\rtime\r")
+	write(keys, "   \r")
+
+	turns := r.finish()
+	want := []geminiTurn{
+		{Prompt: "Hello", Response: "✦ Hi there!"},
+		{Prompt: "line one\nline two", Response: "✦ Two lines"},
+		{Prompt: "line one\nline two", Response: "✦ Again"},
+		{Prompt: "tea\ntime"},
+	}
+	if len(turns) != len(want) {
+		t.Fatalf("Expected %d turns, got %+v", len(want), turns)
+	}
+	for i := range want {
+		if turns[i] != want[i] {
+			t.Errorf("Turn %d: expected %+v, got %+v", i+1, want[i], turns[i])
+		}
+	}
+}
+
+func TestGeminiTurnRecorder_Prompted(t *testing.T) {
+	r := newGeminiTurnRecorder(true)
+	if _, err := r.Write([]byte("Answer to the command line prompt\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	turns := r.finish()
+	if len(turns) != 1 || turns[0].Prompt != "" || turns[0].Response != "Answer to the command line prompt" {
+		t.Errorf("Expected the response to the argument prompt, got %+v", turns)
+	}
+}
+
+func TestGeminiScreenText(t *testing.T) {
+	text := "╭────╮\n│ > hello │\n╰────╯\n  indented code\n\nnext paragraph"
+	if got := geminiScreenText(text); got != "> hello\n  indented code\n\nnext paragraph" {
+		t.Errorf("Unexpected text %q", got)
+	}
+}
+
+func TestWrapGemini_Interactive(t *testing.T) {
+	if os.Getenv("TEST_WRAP_GEMINI_PTY") == "1" {
+		wrapGemini(nil)
+		return
+	}
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("pseudo-terminals are not supported on this platform")
+	}
+
+	tmpDir := t.TempDir()
+	mockGemini := `#!/bin/sh
+test -t 0 || { echo "stdin is not a terminal"; exit 3; }
+printf 'Welcome\n'
+IFS= read -r line
+printf 'Answer to %s\n' "$line"
+exit 4
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "gemini"), []byte(mockGemini), 0o755); err != nil { //nolint:gosec // The mock must be executable
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=TestWrapGemini_Interactive")
+	cmd.Env = append(os.Environ(), "TEST_WRAP_GEMINI_PTY=1", "HOME="+tmpDir, "PATH="+tmpDir+":"+os.Getenv("PATH"))
+	pty, err := term.Start(cmd)
+	if err != nil {
+		t.Fatalf("Failed to start under a PTY: %v", err)
+	}
+	defer pty.Close()
+
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(pty)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.Contains(line, "Welcome") {
+			if _, err := pty.Write([]byte("What is Go?\r")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var record map[string]interface{}
+		if json.Unmarshal([]byte(line), &record) == nil && record["msg"] == "conversation" {
+			records = append(records, record)
+		}
+	}
+
+	exitErr := &exec.ExitError{}
+	if err := cmd.Wait(); !errors.As(err, &exitErr) || exitErr.ExitCode() != 4 {
+		t.Errorf("Expected gemini's exit code 4, got %v", err)
+	}
+
+	var turns []string
+	for _, record := range records {
+		if record["source"] == "pty" {
+			turns = append(turns, record["role"].(string)+": "+record["content"].(string))
+		}
+	}
+	want := []string{"user: What is Go?", "assistant: What is Go?\nAnswer to What is Go?"}
+	if strings.Join(turns, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected turns %q, got %q", want, turns)
+	}
+}
+
+func TestRecoverGeminiTurns(t *testing.T) {
+	if os.Getenv("TEST_RECOVER_GEMINI") == "1" {
+		mgr, err := session.NewManager()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := recoverGeminiTurns(logger.NewLogger(geminiService, mgr), mgr, "session-1"); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	mgr, err := session.NewManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finished := exec.CommandContext(context.Background(), "true")
+	if err := finished.Run(); err != nil {
+		t.Fatal(err)
+	}
+	checkpoints := geminiCheckpoints{
+		finished.Process.Pid: {{Prompt: "First", Response: "Done"}, {Prompt: "Second", Response: "Half of"}},
+		os.Getpid():          {{Prompt: "Still running"}},
+	}
+	if err := mgr.SaveState(geminiCheckpointState("session-1"), checkpoints); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.CommandContext(context.Background(), os.Args[0], "-test.run=TestRecoverGeminiTurns")
+	cmd.Env = append(os.Environ(), "TEST_RECOVER_GEMINI=1")
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+
+	var turns []string
+	for line := range strings.SplitSeq(string(output), "\n") {
+		var record map[string]interface{}
+		if json.Unmarshal([]byte(line), &record) != nil || record["msg"] != "conversation" {
+			continue
+		}
+		turn := record["role"].(string) + ": " + record["content"].(string)
+		if status, ok := record["status"].(string); ok {
+			turn += " (" + status + ")"
+		}
+		turns = append(turns, turn)
+	}
+	want := []string{"user: First", "assistant: Done (complete)", "user: Second", "assistant: Half of (interrupted)"}
+	if strings.Join(turns, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected turns %q, got %q", want, turns)
+	}
+
+	remaining := geminiCheckpoints{}
+	if err := mgr.LoadState(geminiCheckpointState("session-1"), &remaining); err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 1 || len(remaining[os.Getpid()]) != 1 {
+		t.Errorf("Expected only the running session's checkpoint to remain, got %v", remaining)
+	}
+}
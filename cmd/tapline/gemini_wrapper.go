@@ -1,20 +1,23 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"os/exec"
-	"strings"
 
 	"github.com/google/uuid"
+	"github.com/hirosassa/tapline/pkg/gemini"
 	"github.com/hirosassa/tapline/pkg/logger"
 	"github.com/hirosassa/tapline/pkg/session"
+	"github.com/hirosassa/tapline/pkg/term"
 )
 
+const geminiService = "gemini-cli"
+
 func wrapGemini(args []string) {
 	sessionMgr, err := session.NewManager()
 	if err != nil {
@@ -23,32 +26,95 @@ func wrapGemini(args []string) {
 		return
 	}
 
-	log := logger.NewLogger("gemini-cli", sessionMgr)
+	log := logger.NewWrapperLogger(geminiService, sessionMgr)
 
-	if !sessionMgr.HasActiveSession() {
-		newSessionID := uuid.New().String()
-		if err := sessionMgr.SetSessionID(newSessionID); err != nil {
+	sessionID, err := sessionMgr.SessionFor(geminiService, log.Lineage)
+	if err != nil {
+		sessionID = uuid.New().String()
+		if err := sessionMgr.SetSessionFor(geminiService, log.Lineage, sessionID); err != nil {
 			runGeminiDirectly(args)
 			return
 		}
-		log.LogSessionStart(newSessionID, nil)
+		log.LogSessionStart(sessionID, nil)
 	}
 
-	sessionID, err := sessionMgr.GetSessionID()
-	if err != nil {
-		runGeminiDirectly(args)
-		return
+	parsed := parseGeminiArgs(lookupProfile(geminiService, "gemini"), args)
+
+	// Piped input is part of the prompt. gemini reads it all before it starts, so
+	// reading it first and replaying it does not change what gemini sees.
+	var stdin io.Reader = os.Stdin
+	var piped []byte
+	if !parsed.interactive() && !term.IsTerminal(os.Stdin) {
+		piped, stdin = readPipedInput(os.Stdin, pipedInputWait)
 	}
 
-	if len(args) > 0 {
-		prompt := strings.Join(args, " ")
-		log.LogUserPrompt(sessionID, prompt)
+	if prompt := pipedPrompt(parsed.Prompt, piped); prompt != "" {
+		log.Log(logger.Entry{
+			SessionID: sessionID,
+			Role:      "user",
+			Content:   prompt,
+			Metadata:  wrapMetadata(parsed.Invocation, piped),
+		})
 	}
 
-	response, exitCode := executeGemini(args)
+	env := log.Lineage.Env(os.Environ(), sessionID)
 
-	if response != "" {
-		log.LogAssistantResponse(sessionID, response)
+	// JSON output carries model and usage stats; it is requested when the user did not pick a format
+	injected := parsed.injectJSON(len(piped) > 0)
+	jsonOutput := injected || parsed.jsonOutput()
+
+	if !jsonOutput && !parsed.promptOnly() && term.IsTerminal(os.Stdin) && term.IsTerminal(os.Stdout) {
+		// The interactive UI needs a terminal; without a PTY it falls back to pipes below
+		if err := recoverGeminiTurns(log, sessionMgr, sessionID); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+
+		turns := newGeminiTurnRecorder(parsed.Prompt != "")
+		// Checkpoints are plain content, so they are only kept when the log itself would hold it
+		if log.StoresContent() {
+			turns.checkpoint = func(checkpoint []geminiTurn) {
+				updateGeminiCheckpoint(sessionMgr, sessionID, redactGeminiTurns(log.Redactor, checkpoint)) //nolint:errcheck // The turns are still logged at the end
+			}
+		}
+		exitCode, interrupted, err := executeGeminiPTY(args, env, turns)
+		if err == nil {
+			logGeminiTurns(log, sessionID, turns.finish(), responseStatus(interrupted))
+			if interrupted != nil {
+				logInterrupted(log, sessionID, interrupted, exitCode)
+			}
+			if err := updateGeminiCheckpoint(sessionMgr, sessionID, nil); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+			if exitCode != 0 {
+				os.Exit(exitCode)
+			}
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %v, running gemini without a terminal\n", err)
+	}
+
+	var echo io.Writer = os.Stdout
+	var stream *responseStream
+	if injected {
+		// The requested JSON is rendered as text once gemini is done
+		args = append([]string{"--output-format", gemini.OutputFormatJSON}, args...)
+		echo = io.Discard
+	}
+	if !jsonOutput {
+		// A JSON result arrives in one piece at the end, so only text is streamed
+		stream = newResponseStream(log, sessionID)
+	}
+
+	output, exitCode, interrupted := executeGemini(args, env, stdin, echo, stream)
+
+	// What was captured is logged even when gemini was stopped
+	if jsonOutput {
+		logGeminiJSON(log, sessionID, output, injected)
+	} else {
+		stream.finish(output.text(), output.stderr(), responseStatus(interrupted))
+	}
+	if interrupted != nil {
+		logInterrupted(log, sessionID, interrupted, exitCode)
 	}
 
 	if exitCode != 0 {
@@ -56,93 +122,104 @@ func wrapGemini(args []string) {
 	}
 }
 
-func executeGemini(args []string) (response string, exitCode int) {
+// executeGemini runs gemini with env and stdin, capturing its output, echoing it to
+// echo and passing it to stream when set. interrupted is the signal that stopped
+// gemini, if any.
+func executeGemini(args, env []string, stdin io.Reader, echo io.Writer, stream *responseStream) (output capturedOutput, exitCode int, interrupted os.Signal) {
 	geminiPath, err := exec.LookPath("gemini")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: 'gemini' command not found in PATH\n")
-		return "", 1
+		return capturedOutput{}, 1, nil
 	}
 
-	ctx := context.Background()
-	cmd := exec.CommandContext(ctx, geminiPath, args...)
-	cmd.Stdin = os.Stdin
+	return runCaptured(geminiPath, args, env, stdin, echo, stream)
+}
 
-	stdout, err := cmd.StdoutPipe()
+// logGeminiJSON logs the response of a JSON result with its stats. When tapline
+// requested the JSON, render prints the response as gemini would have printed
+// it; output that is not JSON, such as a message from a gemini without JSON
+// support, is printed and logged as it is.
+func logGeminiJSON(log *logger.Logger, sessionID string, output capturedOutput, render bool) {
+	result, err := gemini.ParseOutput([]byte(output.Stdout))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating stdout pipe: %v\n", err)
-		return "", 1
+		if render {
+			fmt.Print(output.Stdout)
+		}
+		if text, stderr := output.text(), output.stderr(); text != "" || stderr != "" {
+			log.Log(logger.Entry{
+				SessionID: sessionID,
+				Role:      "assistant",
+				Content:   text,
+				Stderr:    stderr,
+			})
+		}
+		return
 	}
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating stderr pipe: %v\n", err)
-		return "", 1
+	if render {
+		if result.Response != "" {
+			fmt.Println(result.Response)
+		}
+		if result.Error != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", result.Error.Message)
+		}
 	}
 
-	if err := cmd.Start(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error starting gemini: %v\n", err)
-		return "", 1
+	if result.Error != nil {
+		log.Log(logger.Entry{
+			SessionID: sessionID,
+			Role:      "system",
+			Event:     "error",
+			Content:   result.Error.Message,
+			Stderr:    output.stderr(),
+			Attrs:     []slog.Attr{slog.String("error_type", result.Error.Type)},
+		})
 	}
 
-	response = captureOutput(stdout, stderr)
-
-	err = cmd.Wait()
-	if err != nil {
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			exitCode = exitErr.ExitCode()
-			return
-		}
-		exitCode = 1
-		return
+	if result.Response != "" {
+		log.Log(logger.Entry{
+			SessionID: sessionID,
+			Role:      "assistant",
+			Content:   result.Response,
+			Stderr:    output.stderr(),
+			Attrs:     geminiStatsAttrs(result.Stats),
+		})
 	}
-
-	exitCode = 0
-	return
 }
 
-func captureOutput(stdout, stderr io.Reader) string {
-	lines := make(chan string, 100)
-	stdoutDone := make(chan bool)
-	stderrDone := make(chan bool)
-
-	go func() {
-		scanner := bufio.NewScanner(stdout)
-		for scanner.Scan() {
-			line := scanner.Text()
-			fmt.Println(line)
-			lines <- line
-		}
-		if err := scanner.Err(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading stdout: %v\n", err)
-		}
-		stdoutDone <- true
-	}()
+// geminiStatsAttrs returns the model, token usage and tool call stats of a run
+func geminiStatsAttrs(stats *gemini.Stats) []slog.Attr {
+	if stats == nil {
+		return nil
+	}
 
-	go func() {
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			fmt.Fprintln(os.Stderr, scanner.Text())
-		}
-		if err := scanner.Err(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading stderr: %v\n", err)
+	tokens := stats.Tokens()
+	requests, latencyMs := stats.APIRequests()
+	attrs := []slog.Attr{
+		slog.String("model", stats.Model()),
+		slog.Int("input_tokens", tokens.Prompt),
+		slog.Int("output_tokens", tokens.Candidates),
+		slog.Int("cached_tokens", tokens.Cached),
+		slog.Int("thoughts_tokens", tokens.Thoughts),
+		slog.Int("tool_tokens", tokens.Tool),
+		slog.Int("total_tokens", tokens.Total),
+		slog.Int("api_requests", requests),
+		slog.Int64("api_latency_ms", latencyMs),
+		slog.Int("tool_calls", stats.Tools.TotalCalls),
+		slog.Int("tool_calls_success", stats.Tools.TotalSuccess),
+		slog.Int("tool_calls_failed", stats.Tools.TotalFail),
+		slog.Int64("tool_duration_ms", stats.Tools.TotalDurationMs),
+		slog.Int("lines_added", stats.Files.TotalLinesAdded),
+		slog.Int("lines_removed", stats.Files.TotalLinesRemoved),
+	}
+	if len(stats.Tools.ByName) > 0 {
+		calls := make(map[string]int, len(stats.Tools.ByName))
+		for name, tool := range stats.Tools.ByName {
+			calls[name] = tool.Count
 		}
-		stderrDone <- true
-	}()
-
-	go func() {
-		<-stdoutDone
-		<-stderrDone
-		close(lines)
-	}()
-
-	var response strings.Builder
-	for line := range lines {
-		response.WriteString(line)
-		response.WriteString("\n")
+		attrs = append(attrs, slog.Any("tool_calls_by_name", calls))
 	}
-
-	return strings.TrimSpace(response.String())
+	return attrs
 }
 
 func runGeminiDirectly(args []string) {
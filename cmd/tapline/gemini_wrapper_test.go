@@ -1,7 +1,9 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"io"
 	"os"
@@ -160,23 +162,203 @@ exit 42
 	}
 }
 
+const geminiJSONResult = `{"response":"Quantum computers use qubits.","stats":{"models":{"gemini-2.5-pro":{"api":{"totalRequests":1,"totalErrors":0,"totalLatencyMs":900},"tokens":{"prompt":1200,"candidates":40,"total":1290,"cached":800,"thoughts":50,"tool":0}}},"tools":{"totalCalls":1,"totalSuccess":1,"totalFail":0,"totalDurationMs":30,"byName":{"read_file":{"count":1,"success":1,"fail":0,"durationMs":30}}},"files":{"totalLinesAdded":0,"totalLinesRemoved":0}}}`
+
+func TestWrapGemini_JSONOutput(t *testing.T) {
+	if os.Getenv("TEST_WRAP_GEMINI_JSON") == "1" {
+		wrapGemini([]string{"-p", "Explain quantum computing", "--output-format", "json"})
+		return
+	}
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "result.json"), []byte(geminiJSONResult+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mockGemini := "#!/bin/sh\n[ \"$*\" = \"-p Explain quantum computing --output-format json\" ] || { echo \"unexpected args: $*\"; exit 2; }\ncat \"" + filepath.Join(tmpDir, "result.json") + "\"\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "gemini"), []byte(mockGemini), 0o755); err != nil { //nolint:gosec // The mock must be executable
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=TestWrapGemini_JSONOutput")
+	cmd.Env = append(os.Environ(), "TEST_WRAP_GEMINI_JSON=1", "HOME="+tmpDir, "PATH="+tmpDir+":"+os.Getenv("PATH"))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+
+	var assistant map[string]interface{}
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		var record map[string]interface{}
+		if json.Unmarshal([]byte(line), &record) == nil && record["role"] == "assistant" {
+			assistant = record
+		}
+	}
+
+	// The test binary adds its own PASS line after the wrapper returns
+	if !strings.HasPrefix(string(output), geminiJSONResult+"\n") || strings.Contains(string(output), `"msg":"conversation"`) {
+		t.Errorf("Expected the JSON result to be printed unchanged, got %q", output)
+	}
+	if assistant == nil {
+		t.Fatal("Expected an assistant record")
+	}
+	if assistant["content"] != "Quantum computers use qubits." || assistant["model"] != "gemini-2.5-pro" {
+		t.Errorf("Unexpected assistant record: %v", assistant)
+	}
+	if assistant["input_tokens"] != float64(1200) || assistant["output_tokens"] != float64(40) || assistant["cached_tokens"] != float64(800) || assistant["tool_calls"] != float64(1) {
+		t.Errorf("Unexpected stats on assistant record: %v", assistant)
+	}
+}
+
+func TestWrapGemini_InjectsJSONOutput(t *testing.T) {
+	if os.Getenv("TEST_WRAP_GEMINI_INJECT") == "1" {
+		wrapGemini([]string{"-p", "Explain quantum computing"})
+		return
+	}
+
+	tmpDir := t.TempDir()
+	result := `{"response":"Partly done.","error":{"type":"FatalToolError","message":"tool failed"},"stats":{"models":{"gemini-2.5-pro":{"api":{"totalRequests":1},"tokens":{"prompt":100,"candidates":5,"total":105}}}}}`
+	if err := os.WriteFile(filepath.Join(tmpDir, "result.json"), []byte(result+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Without an output format of the user's, the wrapper asks for JSON
+	mockGemini := "#!/bin/sh\n[ \"$*\" = \"--output-format json -p Explain quantum computing\" ] || { echo \"unexpected args: $*\"; exit 2; }\ncat \"" + filepath.Join(tmpDir, "result.json") + "\"\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "gemini"), []byte(mockGemini), 0o755); err != nil { //nolint:gosec // The mock must be executable
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=TestWrapGemini_InjectsJSONOutput")
+	cmd.Env = append(os.Environ(), "TEST_WRAP_GEMINI_INJECT=1", "HOME="+tmpDir, "PATH="+tmpDir+":"+os.Getenv("PATH"))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+
+	// The user sees the response text, not the JSON
+	if !strings.HasPrefix(string(output), "Partly done.\n") || strings.Contains(string(output), `"response"`) {
+		t.Errorf("Expected the response text to be printed, got %q", output)
+	}
+	if !strings.Contains(stderr.String(), "Error: tool failed\n") {
+		t.Errorf("Expected the error to be printed to stderr, got %q", stderr.String())
+	}
+
+	var assistant, errorEvent map[string]interface{}
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		var record map[string]interface{}
+		if json.Unmarshal([]byte(line), &record) != nil {
+			continue
+		}
+		switch {
+		case record["role"] == "assistant":
+			assistant = record
+		case record["event"] == "error":
+			errorEvent = record
+		}
+	}
+	if assistant == nil || assistant["content"] != "Partly done." || assistant["model"] != "gemini-2.5-pro" || assistant["input_tokens"] != float64(100) {
+		t.Errorf("Expected the response logged with its stats, got %v", assistant)
+	}
+	if assistant["event"] == "response_partial" {
+		t.Errorf("Expected no partial records for a JSON result, got %v", assistant)
+	}
+	if errorEvent == nil || errorEvent["content"] != "tool failed" || errorEvent["error_type"] != "FatalToolError" {
+		t.Errorf("Expected an error event, got %v", errorEvent)
+	}
+}
+
+func TestWrapGemini_StreamsPartialResponses(t *testing.T) {
+	if os.Getenv("TEST_WRAP_GEMINI_STREAM") == "1" {
+		wrapGemini([]string{"Explain quantum computing"})
+		return
+	}
+
+	tests := []struct {
+		name     string
+		script   string
+		content  string
+		status   string
+		partials int
+		partial  string
+	}{
+		{"complete", "echo first\nsleep 3\necho second\n", "first\nsecond", responseComplete, 1, "first\n"},
+		{"interrupted", "echo partial\nkill -TERM $$\n", "partial", responseInterrupted, 0, ""},
+		// Output still on its line, such as a spinner, is logged without waiting for the newline
+		{"unfinished line", "printf working\nsleep 3\necho ' done'\n", "working done", responseComplete, 1, "working"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, "gemini"), []byte("#!/bin/sh\n"+tt.script), 0o755); err != nil { //nolint:gosec // The mock must be executable
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=TestWrapGemini_StreamsPartialResponses")
+			cmd.Env = append(os.Environ(), "TEST_WRAP_GEMINI_STREAM=1", "HOME="+tmpDir, "PATH="+tmpDir+":"+os.Getenv("PATH"))
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+			cmd.Run() //nolint:errcheck // The interrupted run exits with the signal's status
+
+			output := stderr.String()
+			var partials, finals []map[string]interface{}
+			for _, line := range strings.Split(output, "\n") {
+				var record map[string]interface{}
+				if json.Unmarshal([]byte(line), &record) != nil || record["role"] != "assistant" {
+					continue
+				}
+				if record["event"] == "response_partial" {
+					partials = append(partials, record)
+				} else {
+					finals = append(finals, record)
+				}
+			}
+
+			if len(finals) != 1 {
+				t.Fatalf("Expected one final response, got %d in %q", len(finals), output)
+			}
+			final := finals[0]
+			if final["content"] != tt.content || final["status"] != tt.status || final["partial_count"] != float64(tt.partials) {
+				t.Errorf("Unexpected final response: %v", final)
+			}
+			if len(partials) != tt.partials {
+				t.Fatalf("Expected %d partial records, got %d", tt.partials, len(partials))
+			}
+			for _, partial := range partials {
+				if partial["response_event_id"] != final["event_id"] || partial["content"] != tt.partial {
+					t.Errorf("Unexpected partial record: %v", partial)
+				}
+			}
+		})
+	}
+}
+
 func TestCaptureOutput_BasicOutput(t *testing.T) {
 	stdout := strings.NewReader("line 1\nline 2\nline 3")
 	stderr := strings.NewReader("")
 
-	result := captureOutput(stdout, stderr)
+	var echo bytes.Buffer
+	result := captureOutputTo(stdout, stderr, &echo, nil).text()
 
 	expected := "line 1\nline 2\nline 3"
 	if result != expected {
 		t.Errorf("Expected %q, got %q", expected, result)
 	}
+	if echo.String() != expected {
+		t.Errorf("Expected the output echoed unchanged, got %q", echo.String())
+	}
 }
 
 func TestCaptureOutput_EmptyOutput(t *testing.T) {
 	stdout := strings.NewReader("")
 	stderr := strings.NewReader("")
 
-	result := captureOutput(stdout, stderr)
+	result := captureOutputTo(stdout, stderr, io.Discard, nil).text()
 
 	if result != "" {
 		t.Errorf("Expected empty string, got %q", result)
@@ -187,7 +369,7 @@ func TestCaptureOutput_WithTrailingNewline(t *testing.T) {
 	stdout := strings.NewReader("line 1\nline 2\n")
 	stderr := strings.NewReader("")
 
-	result := captureOutput(stdout, stderr)
+	result := captureOutputTo(stdout, stderr, io.Discard, nil).text()
 
 	expected := "line 1\nline 2"
 	if result != expected {
@@ -199,7 +381,7 @@ func TestCaptureOutput_StderrIgnored(t *testing.T) {
 	stdout := strings.NewReader("stdout line")
 	stderr := strings.NewReader("stderr line")
 
-	result := captureOutput(stdout, stderr)
+	result := captureOutputTo(stdout, stderr, io.Discard, nil).text()
 
 	expected := "stdout line"
 	if result != expected {
@@ -212,7 +394,7 @@ func TestCaptureOutput_MultipleLines(t *testing.T) {
 	stdout := strings.NewReader(lines)
 	stderr := strings.NewReader("")
 
-	result := captureOutput(stdout, stderr)
+	result := captureOutputTo(stdout, stderr, io.Discard, nil).text()
 
 	if !strings.Contains(result, "line") {
 		t.Error("Expected output to contain 'line'")
@@ -239,7 +421,7 @@ func TestCaptureOutput_ConcurrentReads(t *testing.T) {
 		w2.Close()
 	}()
 
-	result := captureOutput(r1, r2)
+	result := captureOutputTo(r1, r2, io.Discard, nil).text()
 
 	if !strings.Contains(result, "line 1") {
 		t.Error("Expected output to contain 'line 1'")
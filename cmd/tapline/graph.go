@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hirosassa/tapline/pkg/graph"
+)
+
+func handleGraph(args []string) {
+	fs := flag.NewFlagSet("graph", flag.ExitOnError)
+	sessionID := fs.String("session", "", "session to graph with its subagents and nested sessions (default all sessions)")
+	format := fs.String("format", graph.FormatMermaid, "output format: mermaid or dot")
+	//nolint:errcheck // ExitOnError handles parse failures
+	fs.Parse(args)
+
+	if *format != graph.FormatMermaid && *format != graph.FormatDOT {
+		fmt.Fprintf(os.Stderr, "Unknown format %q (use %s or %s)\n", *format, graph.FormatMermaid, graph.FormatDOT)
+		os.Exit(1)
+	}
+
+	inputs := fs.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+
+	if err := graphFiles(inputs, *sessionID, *format, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+// graphFiles renders the session hierarchy found in the logs; "-" reads stdin
+func graphFiles(paths []string, sessionID, format string, out io.Writer) error {
+	readers := make([]io.Reader, 0, len(paths))
+	for _, path := range paths {
+		in, closeInput, err := openInput(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		defer closeInput()
+		readers = append(readers, in)
+	}
+
+	g, err := graph.Build(readers, sessionID)
+	if err != nil {
+		return err
+	}
+	return g.Render(out, format)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hirosassa/tapline/pkg/audit"
+	"github.com/hirosassa/tapline/pkg/encrypt"
+)
+
+func handleKeygen(args []string) {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	output := fs.String("o", "", "write the secret key to this file instead of stdout")
+	sign := fs.Bool("sign", false, "generate an Ed25519 audit signing key instead of an encryption key")
+	//nolint:errcheck // ExitOnError handles parse failures
+	fs.Parse(args)
+
+	generate := encrypt.GenerateKey
+	if *sign {
+		generate = audit.GenerateSigningKey
+	}
+
+	secretKey, publicKey, err := generate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate key: %v\n", err)
+		os.Exit(1)
+	}
+
+	keyFile := fmt.Sprintf("# public key: %s\n%s\n", publicKey, secretKey)
+
+	if *output == "" {
+		fmt.Print(keyFile)
+		return
+	}
+
+	f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create key file: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close() //nolint:errcheck // Write errors are checked below
+
+	if _, err := f.WriteString(keyFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write key file: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Public key: %s\n", publicKey)
+}
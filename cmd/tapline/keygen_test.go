@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hirosassa/tapline/pkg/audit"
+	"github.com/hirosassa/tapline/pkg/encrypt"
+)
+
+func TestKeygen_WritesKeyFile(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "tapline.key")
+
+	if os.Getenv("TEST_KEYGEN") == "1" {
+		handleKeygen([]string{"-o", os.Getenv("TEST_KEYGEN_OUT")})
+		return
+	}
+
+	ctx := context.Background()
+	cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=TestKeygen_WritesKeyFile")
+	cmd.Env = append(os.Environ(), "TEST_KEYGEN=1", "TEST_KEYGEN_OUT="+keyFile)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Expected success, got error: %v\nOutput: %s", err, output)
+	}
+
+	if !strings.Contains(string(output), encrypt.PublicKeyPrefix) {
+		t.Errorf("Expected public key in output, got: %s", output)
+	}
+
+	info, err := os.Stat(keyFile)
+	if err != nil {
+		t.Fatalf("Expected key file to exist: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected key file mode 0600, got %o", info.Mode().Perm())
+	}
+
+	if _, err := encrypt.LoadDecryptor(keyFile); err != nil {
+		t.Errorf("Expected usable key file, got %v", err)
+	}
+}
+
+func TestKeygen_SigningKey(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "signing.key")
+
+	if os.Getenv("TEST_KEYGEN_SIGN") == "1" {
+		handleKeygen([]string{"-sign", "-o", os.Getenv("TEST_KEYGEN_OUT")})
+		return
+	}
+
+	ctx := context.Background()
+	cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=TestKeygen_SigningKey")
+	cmd.Env = append(os.Environ(), "TEST_KEYGEN_SIGN=1", "TEST_KEYGEN_OUT="+keyFile)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Expected success, got error: %v\nOutput: %s", err, output)
+	}
+
+	if !strings.Contains(string(output), audit.VerifyKeyPrefix) {
+		t.Errorf("Expected verification key in output, got: %s", output)
+	}
+
+	if _, err := audit.LoadSigningKey(keyFile); err != nil {
+		t.Errorf("Expected usable signing key file, got %v", err)
+	}
+}
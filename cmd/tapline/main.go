@@ -33,10 +33,28 @@ func main() {
 		handleUserPrompt(os.Args[2:])
 	case "assistant_response":
 		handleAssistantResponse(os.Args[2:])
+	case "claude-hook":
+		handleClaudeHook()
+	case "wrap-claude":
+		wrapClaude(os.Args[2:])
 	case "wrap-gemini":
 		wrapGemini(os.Args[2:])
 	case "notify-codex":
-		notifyCodex()
+		notifyCodex(os.Args[2:])
+	case "wrap-codex-exec":
+		wrapCodexExec(os.Args[2:])
+	case "wrap":
+		wrapCommand(os.Args[2:])
+	case "keygen":
+		handleKeygen(os.Args[2:])
+	case "decrypt":
+		handleDecrypt(os.Args[2:])
+	case "verify":
+		handleVerify(os.Args[2:])
+	case "reassemble":
+		handleReassemble(os.Args[2:])
+	case "graph":
+		handleGraph(os.Args[2:])
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
 		os.Exit(1)
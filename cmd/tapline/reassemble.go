@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hirosassa/tapline/pkg/chunk"
+)
+
+func handleReassemble(args []string) {
+	inputs := args
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+
+	failed := false
+	for _, input := range inputs {
+		if err := reassembleFile(input); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", input, err)
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// reassembleFile writes a log to stdout with chunked events merged back together; "-" reads stdin
+func reassembleFile(path string) error {
+	in, closeInput, err := openInput(path)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	return chunk.Reassemble(in, os.Stdout)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hirosassa/tapline/pkg/logger"
+)
+
+// Status of a logged response
+const (
+	responseComplete    = "complete"
+	responseInterrupted = "interrupted"
+)
+
+// partialResponseInterval is how often output streamed since the last partial
+// record is logged while a response is still running
+const partialResponseInterval = 2 * time.Second
+
+// responseStream logs a response while it streams. Output is logged in
+// response_partial records, so a crash or kill mid-response loses at most the
+// last interval, and a final record carries the whole response and its status.
+// The partial records name the final record in response_event_id.
+type responseStream struct {
+	log       *logger.Logger
+	sessionID string
+	eventID   string
+	attrs     []slog.Attr
+	pending   strings.Builder
+	partials  int
+}
+
+func newResponseStream(log *logger.Logger, sessionID string, attrs ...slog.Attr) *responseStream {
+	return &responseStream{
+		log:       log,
+		sessionID: sessionID,
+		eventID:   uuid.New().String(),
+		attrs:     attrs,
+	}
+}
+
+// add queues output for the next partial record
+func (s *responseStream) add(text string) {
+	s.pending.WriteString(text)
+}
+
+// flush logs the output queued since the last partial record
+func (s *responseStream) flush() {
+	if strings.TrimSpace(s.pending.String()) == "" {
+		return
+	}
+
+	s.log.Log(logger.Entry{
+		SessionID: s.sessionID,
+		Role:      "assistant",
+		Event:     "response_partial",
+		Content:   s.pending.String(),
+		Attrs: append(slices.Clip(s.attrs),
+			slog.String("response_event_id", s.eventID),
+			slog.Int("partial_index", s.partials),
+		),
+	})
+	s.pending.Reset()
+	s.partials++
+}
+
+// finish logs the whole response with its status and attrs, such as the
+// program's stderr. Output that was never logged in a partial record is covered
+// by the final record, so it is not flushed.
+func (s *responseStream) finish(response, stderr, status string) {
+	if response == "" && s.partials == 0 && stderr == "" {
+		return
+	}
+
+	finalAttrs := append(slices.Clip(s.attrs),
+		slog.String("status", status),
+		slog.Int("partial_count", s.partials),
+	)
+	s.log.Log(logger.Entry{
+		SessionID: s.sessionID,
+		EventID:   s.eventID,
+		Role:      "assistant",
+		Content:   response,
+		Stderr:    stderr,
+		Attrs:     finalAttrs,
+	})
+}
+
+// responseStatus returns the status of a response whose program was
+// interrupted by sig, if any
+func responseStatus(sig os.Signal) string {
+	if sig != nil {
+		return responseInterrupted
+	}
+	return responseComplete
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hirosassa/tapline/pkg/audit"
+	"github.com/hirosassa/tapline/pkg/session"
+)
+
+func handleVerify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	keyArg := fs.String("key", "", "verification key (tapline-sign-pk1-...) or key file; requires every record to be signed")
+	chain := fs.String("chain", "", "also check that the log ends at the local state of this audit chain")
+	//nolint:errcheck // ExitOnError handles parse failures
+	fs.Parse(args)
+
+	var verifyKey ed25519.PublicKey
+	if *keyArg != "" {
+		key, err := audit.LoadVerifyKey(*keyArg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load verification key: %v\n", err)
+			os.Exit(1)
+		}
+		verifyKey = key
+	}
+
+	inputs := fs.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+
+	ok := true
+	for _, input := range inputs {
+		report, err := verifyFile(input, verifyKey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", input, err)
+			ok = false
+			continue
+		}
+
+		if *chain != "" {
+			checkChainTail(report, *chain)
+		}
+
+		printReport(input, report)
+		ok = ok && report.OK()
+	}
+
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+func verifyFile(path string, verifyKey ed25519.PublicKey) (*audit.Report, error) {
+	in, closeInput, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer closeInput()
+
+	return audit.Verify(in, verifyKey)
+}
+
+// checkChainTail compares the end of the log with the locally persisted chain
+// position, which detects records deleted or edited at the end of the log
+func checkChainTail(report *audit.Report, chain string) {
+	sessionMgr, err := session.NewManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot read chain state: %v\n", err)
+		return
+	}
+
+	var state audit.State
+	if err := sessionMgr.LoadState("audit-"+chain, &state); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot read chain state: %v\n", err)
+		return
+	}
+
+	switch {
+	case state.Seq == 0:
+		fmt.Fprintf(os.Stderr, "Warning: no local state for audit chain %q\n", chain)
+	case report.LastSeq < state.Seq:
+		report.Issues = append(report.Issues, audit.Issue{
+			Kind:   audit.IssueMissingRecords,
+			Detail: fmt.Sprintf("log ends at seq %d but chain %q is at seq %d", report.LastSeq, chain, state.Seq),
+		})
+	case report.LastSeq == state.Seq && report.LastHash != state.Hash:
+		report.Issues = append(report.Issues, audit.Issue{
+			Kind:   audit.IssueBrokenLink,
+			Detail: fmt.Sprintf("last record (seq %d) was modified", report.LastSeq),
+		})
+	}
+}
+
+func printReport(path string, report *audit.Report) {
+	for _, issue := range report.Issues {
+		if issue.Line > 0 {
+			fmt.Printf("%s:%d: %s: %s\n", path, issue.Line, issue.Kind, issue.Detail)
+		} else {
+			fmt.Printf("%s: %s: %s\n", path, issue.Kind, issue.Detail)
+		}
+	}
+
+	status := "OK"
+	if !report.OK() {
+		status = fmt.Sprintf("FAILED (%d issue(s))", len(report.Issues))
+	}
+	fmt.Printf("%s: %s, %d chained record(s), seq %d-%d, %d signed\n",
+		path, status, report.Records, report.FirstSeq, report.LastSeq, report.Signed)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerify_AuditChainedConversation(t *testing.T) {
+	if os.Getenv("TEST_VERIFY_PROMPT") == "1" {
+		handleUserPrompt([]string{os.Getenv("TEST_VERIFY_PROMPT_TEXT")})
+		return
+	}
+
+	tmpDir := t.TempDir()
+	sessionDir := filepath.Join(tmpDir, ".tapline")
+	os.MkdirAll(sessionDir, 0o750)
+	os.WriteFile(filepath.Join(sessionDir, "session_id"), []byte("test-session-id"), 0o600)
+
+	var log strings.Builder
+	for _, prompt := range []string{"first", "second", "third"} {
+		ctx := context.Background()
+		cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=TestVerify_AuditChainedConversation")
+		cmd.Env = append(os.Environ(),
+			"TEST_VERIFY_PROMPT=1",
+			"TEST_VERIFY_PROMPT_TEXT="+prompt,
+			"HOME="+tmpDir,
+			"TAPLINE_AUDIT_CHAIN=test",
+		)
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("Expected success, got error: %v", err)
+		}
+		// Keep only log records, not the test binary's own output
+		for _, line := range strings.SplitAfter(string(output), "\n") {
+			if strings.HasPrefix(line, "{") {
+				log.WriteString(line)
+			}
+		}
+	}
+
+	logFile := filepath.Join(tmpDir, "conversation.log")
+	os.WriteFile(logFile, []byte(log.String()), 0o600)
+
+	report, err := verifyFile(logFile, nil)
+	if err != nil {
+		t.Fatalf("Failed to verify log: %v", err)
+	}
+	if !report.OK() || report.Records < 3 {
+		t.Errorf("Expected chained records without issues, got %+v", report)
+	}
+
+	tampered := strings.Replace(log.String(), `"content":"second"`, `"content":"edited"`, 1)
+	os.WriteFile(logFile, []byte(tampered), 0o600)
+
+	report, err = verifyFile(logFile, nil)
+	if err != nil {
+		t.Fatalf("Failed to verify log: %v", err)
+	}
+	if report.OK() {
+		t.Error("Expected tampered log to fail verification")
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/hirosassa/tapline/pkg/config"
+	"github.com/hirosassa/tapline/pkg/logger"
+	"github.com/hirosassa/tapline/pkg/session"
+	"github.com/hirosassa/tapline/pkg/term"
+	"github.com/hirosassa/tapline/pkg/wrap"
+)
+
+// wrapCommand runs a command-line AI tool and logs its prompt and response
+// under the service given with --service, by default the program name:
+//
+//	tapline wrap --service aider -- aider --message "Fix the failing test"
+//
+// The tool's profile tells where the prompt is in its arguments and the
+// response in its output.
+func wrapCommand(args []string) {
+	fs := flag.NewFlagSet("wrap", flag.ExitOnError)
+	service := fs.String("service", "", "service name to log the tool under (default the program name)")
+	//nolint:errcheck // ExitOnError handles parse failures
+	fs.Parse(args)
+
+	command := fs.Args()
+	if len(command) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: tapline wrap [--service name] -- <command> [args...]")
+		os.Exit(1)
+	}
+	if *service == "" {
+		*service = filepath.Base(command[0])
+	}
+
+	program, err := exec.LookPath(command[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: '%s' command not found in PATH\n", command[0])
+		os.Exit(1)
+	}
+	args = command[1:]
+
+	sessionMgr, err := session.NewManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: tapline session manager unavailable, logging disabled\n")
+		exitCode, _ := runAttached(program, args, os.Environ())
+		os.Exit(exitCode)
+	}
+
+	profile := lookupProfile(*service, program)
+
+	log := logger.NewWrapperLogger(*service, sessionMgr)
+
+	sessionID, err := sessionMgr.SessionFor(*service, log.Lineage)
+	if err != nil {
+		sessionID = uuid.New().String()
+		if err := sessionMgr.SetSessionFor(*service, log.Lineage, sessionID); err != nil {
+			exitCode, _ := runAttached(program, args, os.Environ())
+			os.Exit(exitCode)
+		}
+		log.LogSessionStart(sessionID, nil)
+	}
+
+	invocation := profile.ParseArgs(args)
+
+	var stdin io.Reader = os.Stdin
+	var piped []byte
+	if profile.PipedPrompt && !term.IsTerminal(os.Stdin) {
+		piped, stdin = readPipedInput(os.Stdin, pipedInputWait)
+	}
+
+	prompt := pipedPrompt(invocation.Prompt, piped)
+	if prompt != "" {
+		log.Log(logger.Entry{
+			SessionID: sessionID,
+			Role:      "user",
+			Content:   prompt,
+			Metadata:  wrapMetadata(invocation, piped),
+		})
+	}
+
+	env := log.Lineage.Env(os.Environ(), sessionID)
+
+	if prompt == "" && term.IsTerminal(os.Stdin) && term.IsTerminal(os.Stdout) {
+		// Without a prompt the tool starts an interactive session, which needs the terminal
+		exitCode, interrupted := runAttached(program, args, env)
+		if interrupted != nil {
+			logInterrupted(log, sessionID, interrupted, exitCode)
+		}
+		os.Exit(exitCode)
+	}
+
+	stream := newResponseStream(log, sessionID)
+	partials := stream
+	if profile.ResponseFormat != "" && profile.ResponseFormat != config.ResponseFormatText {
+		// A JSON response can only be extracted once the output is complete
+		partials = nil
+	}
+
+	output, exitCode, interrupted := runCaptured(program, args, env, stdin, os.Stdout, partials)
+
+	// What was captured is logged even when the tool was stopped
+	stream.finish(profile.Response(output.Stdout), output.stderr(), responseStatus(interrupted))
+	if interrupted != nil {
+		logInterrupted(log, sessionID, interrupted, exitCode)
+	}
+
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+// lookupProfile returns the wrap profile of a tool, from the built-in and configured ones
+func lookupProfile(service, program string) *wrap.Profile {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	profiles, err := wrap.New(cfg.Wrap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return profiles.Lookup(service, program)
+}
+
+// wrapMetadata returns the model, options, files and piped input size of a run for the
+// logged prompt
+func wrapMetadata(invocation wrap.Invocation, stdin []byte) map[string]string {
+	metadata := map[string]string{}
+	if invocation.Model != "" {
+		metadata["model"] = invocation.Model
+	}
+	if len(invocation.Flags) > 0 {
+		metadata["flags"] = strings.Join(invocation.Flags, " ")
+	}
+	if len(invocation.Files) > 0 {
+		metadata["files"] = strings.Join(invocation.Files, " ")
+	}
+	if len(stdin) > 0 {
+		metadata["stdin_bytes"] = strconv.Itoa(len(stdin))
+	}
+	return metadata
+}
+
+// runAttached runs program on tapline's own stdin, stdout and stderr, without
+// capturing its output, and returns its exit status and the signal that
+// interrupted it, if any
+func runAttached(program string, args, env []string) (int, os.Signal) {
+	ctx := context.Background()
+	cmd := exec.CommandContext(ctx, program, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+
+	process, err := startWrapped(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting %s: %v\n", filepath.Base(program), err)
+		return 1, nil
+	}
+	return process.wait()
+}
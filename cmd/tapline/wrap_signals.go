@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/hirosassa/tapline/pkg/logger"
+	"github.com/hirosassa/tapline/pkg/term"
+)
+
+// wrappedProcess is a wrapped program whose process group is sent the signals
+// tapline receives, so stopping tapline stops the program and its children
+// while tapline stays alive to log what was captured
+type wrappedProcess struct {
+	cmd        *exec.Cmd
+	foreground bool
+	signals    chan os.Signal
+	done       chan struct{}
+
+	mu       sync.Mutex
+	received os.Signal
+}
+
+// startWrapped starts cmd in its own process group and forwards signals to it.
+// When tapline runs in the foreground of a terminal the group takes the terminal
+// over, so Ctrl-C and window resizes reach the program directly.
+func startWrapped(cmd *exec.Cmd) (*wrappedProcess, error) {
+	foreground := setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	if foreground {
+		// After the start, so the program does not inherit the setting
+		allowBackgroundOutput()
+	}
+	return forwardSignals(cmd, foreground, true), nil
+}
+
+// forwardSignals forwards signals to the process group of the started cmd, whose
+// process ID must be its group ID. Without resize, window size changes are left
+// to the caller.
+func forwardSignals(cmd *exec.Cmd, foreground, resize bool) *wrappedProcess {
+	p := &wrappedProcess{
+		cmd:        cmd,
+		foreground: foreground,
+		signals:    make(chan os.Signal, 4),
+		done:       make(chan struct{}),
+	}
+	signal.Notify(p.signals, forwardedSignals(resize)...)
+
+	go func() {
+		for {
+			select {
+			case sig := <-p.signals:
+				if !isResize(sig) {
+					p.mu.Lock()
+					if p.received == nil {
+						p.received = sig
+					}
+					p.mu.Unlock()
+				}
+				signalGroup(cmd.Process.Pid, sig) //nolint:errcheck // The program may have exited already
+			case <-p.done:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// wait waits for the program, stops forwarding and returns its exit status and
+// the signal that interrupted it, if any: the first one tapline received or the
+// one the program died of
+func (p *wrappedProcess) wait() (exitCode int, interrupted os.Signal) {
+	err := p.cmd.Wait()
+	signal.Stop(p.signals)
+	close(p.done)
+	if p.foreground {
+		// Also restores SIGTTOU
+		term.SetForeground(os.Stdin) //nolint:errcheck // tapline only writes its log before exiting
+	}
+
+	p.mu.Lock()
+	interrupted = p.received
+	p.mu.Unlock()
+	if interrupted == nil {
+		interrupted = exitSignal(err)
+	}
+	return exitStatus(err), interrupted
+}
+
+// exitSignal returns the signal a program that ended with err died of. Programs
+// that handle a signal by exiting with 128 plus its number, as shells report a
+// signal death, count as killed by it.
+func exitSignal(err error) os.Signal {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return nil
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return status.Signal()
+	}
+	switch sig := syscall.Signal(exitErr.ExitCode() - 128); sig {
+	case syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM:
+		return sig
+	}
+	return nil
+}
+
+// signalName returns the conventional name of sig, such as SIGINT
+func signalName(sig os.Signal) string {
+	switch sig {
+	case syscall.SIGHUP:
+		return "SIGHUP"
+	case syscall.SIGINT:
+		return "SIGINT"
+	case syscall.SIGKILL:
+		return "SIGKILL"
+	case syscall.SIGTERM:
+		return "SIGTERM"
+	}
+	return sig.String()
+}
+
+// logInterrupted logs that the wrapped program was stopped by sig; what it
+// produced until then has been logged before
+func logInterrupted(log *logger.Logger, sessionID string, sig os.Signal, exitCode int) {
+	log.Log(logger.Entry{
+		SessionID: sessionID,
+		Role:      "system",
+		Event:     "interrupted",
+		Attrs: []slog.Attr{
+			slog.String("signal", signalName(sig)),
+			slog.Int("exit_code", exitCode),
+		},
+	})
+}
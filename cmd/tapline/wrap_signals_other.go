@@ -0,0 +1,29 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup leaves cmd in tapline's console, which delivers Ctrl-C to both
+func setProcessGroup(*exec.Cmd) (foreground bool) {
+	return false
+}
+
+// allowBackgroundOutput does nothing: there is no terminal job control
+func allowBackgroundOutput() {}
+
+func forwardedSignals(bool) []os.Signal {
+	return []os.Signal{os.Interrupt}
+}
+
+func isResize(os.Signal) bool {
+	return false
+}
+
+// signalGroup does nothing: the console has delivered the interrupt to the program already
+func signalGroup(int, os.Signal) error {
+	return nil
+}
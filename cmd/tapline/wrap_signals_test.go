@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExitSignal(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   os.Signal
+	}{
+		{"success", "exit 0", nil},
+		{"failure", "exit 1", nil},
+		{"killed", "kill -KILL $$", syscall.SIGKILL},
+		{"exit after SIGINT", "exit 130", syscall.SIGINT},
+		{"exit after SIGTERM", "exit 143", syscall.SIGTERM},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := exec.CommandContext(context.Background(), "sh", "-c", tt.script).Run()
+			if got := exitSignal(err); got != tt.want {
+				t.Errorf("exitSignal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := exitSignal(errors.New("not started")); got != nil {
+		t.Errorf("Expected no signal for a start error, got %v", got)
+	}
+}
+
+func TestWrapGemini_ForwardsSignals(t *testing.T) {
+	if os.Getenv("TEST_WRAP_GEMINI_SIGNAL") == "1" {
+		wrapGemini([]string{"Explain quantum computing"})
+		return
+	}
+
+	tmpDir := t.TempDir()
+	// The child of the mock has to be stopped too, or the pipes stay open
+	script := "#!/bin/sh\necho started\nsleep 30\necho finished\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "gemini"), []byte(script), 0o755); err != nil { //nolint:gosec // The mock must be executable
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=TestWrapGemini_ForwardsSignals")
+	cmd.Env = append(os.Environ(), "TEST_WRAP_GEMINI_SIGNAL=1", "HOME="+tmpDir, "PATH="+tmpDir+":"+os.Getenv("PATH"))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if scanner.Text() == "started" {
+			if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 143 {
+		t.Fatalf("Expected exit code 143, got %v", err)
+	}
+
+	var response, interrupted map[string]interface{}
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		var record map[string]interface{}
+		if json.Unmarshal([]byte(line), &record) != nil {
+			continue
+		}
+		switch {
+		case record["event"] == "interrupted":
+			interrupted = record
+		case record["role"] == "assistant" && record["event"] == nil:
+			response = record
+		}
+	}
+
+	if response == nil || response["content"] != "started" || response["status"] != responseInterrupted {
+		t.Errorf("Expected the output so far logged as interrupted, got %v", response)
+	}
+	if interrupted == nil || interrupted["signal"] != "SIGTERM" || interrupted["exit_code"] != float64(143) {
+		t.Errorf("Expected an interrupted event for SIGTERM, got %v", interrupted)
+	}
+	if strings.Contains(strings.Join(lines, "\n"), "finished") {
+		t.Error("Expected gemini to be stopped before it finished")
+	}
+}
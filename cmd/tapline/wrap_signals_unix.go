@@ -0,0 +1,54 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/hirosassa/tapline/pkg/term"
+)
+
+// setProcessGroup makes cmd start in a process group of its own, in the
+// terminal's foreground when tapline has it, and reports whether it does
+func setProcessGroup(cmd *exec.Cmd) (foreground bool) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+
+	if cmd.Stdin == os.Stdin && term.IsForeground(os.Stdin) {
+		cmd.SysProcAttr.Foreground = true
+		cmd.SysProcAttr.Ctty = int(os.Stdin.Fd()) //nolint:gosec // A file descriptor
+		return true
+	}
+	return false
+}
+
+// allowBackgroundOutput lets tapline write the program's output to the terminal
+// while the program's group has it, also under stty tostop
+func allowBackgroundOutput() {
+	signal.Ignore(syscall.SIGTTOU)
+}
+
+func forwardedSignals(resize bool) []os.Signal {
+	signals := []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP}
+	if resize {
+		signals = append(signals, syscall.SIGWINCH)
+	}
+	return signals
+}
+
+func isResize(sig os.Signal) bool {
+	return sig == syscall.SIGWINCH
+}
+
+func signalGroup(pgid int, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return nil
+	}
+	return syscall.Kill(-pgid, s)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// runTeed runs program with args, copying each line of its output to stdout before
+// handing it to onLine, and returns its exit status and the signal that
+// interrupted it, if any. Structured output such as JSON lines is copied line by
+// line as is, so onLine must log to a sink other than stdout.
+func runTeed(program string, args, env []string, onLine func([]byte)) (int, os.Signal) {
+	ctx := context.Background()
+	cmd := exec.CommandContext(ctx, program, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating stdout pipe: %v\n", err)
+		return 1, nil
+	}
+
+	process, err := startWrapped(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting %s: %v\n", filepath.Base(program), err)
+		return 1, nil
+	}
+
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if _, writeErr := os.Stdout.Write(line); writeErr != nil {
+				fmt.Fprintf(os.Stderr, "Error writing output: %v\n", writeErr)
+			}
+			if onLine != nil {
+				onLine(bytes.TrimSpace(line))
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				fmt.Fprintf(os.Stderr, "Error reading stdout: %v\n", err)
+			}
+			break
+		}
+	}
+
+	return process.wait()
+}
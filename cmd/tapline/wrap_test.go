@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hirosassa/tapline/pkg/wrap"
+)
+
+// runWrapCommand runs the test named test as a subprocess with mock programs
+// in dir and returns the log records it wrote to stderr, and its exit code.
+// The wrapped program owns stdout, so no record may appear there.
+func runWrapCommand(t *testing.T, test, dir, stdin string, env ...string) ([]map[string]interface{}, int) {
+	t.Helper()
+
+	ctx := context.Background()
+	cmd := exec.CommandContext(ctx, os.Args[0], "-test.run="+test)
+	cmd.Env = append(os.Environ(), "HOME="+dir, "PATH="+dir+":"+os.Getenv("PATH"), "TAPLINE_CONFIG="+filepath.Join(dir, "config.json"))
+	cmd.Env = append(cmd.Env, env...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+
+	exitCode := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(output), `"msg":"conversation"`) {
+		t.Errorf("Expected no log records on stdout, got %s", output)
+	}
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		var record map[string]interface{}
+		if json.Unmarshal([]byte(line), &record) == nil && record["msg"] == "conversation" {
+			records = append(records, record)
+		}
+	}
+	return records, exitCode
+}
+
+func writeMock(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755); err != nil { //nolint:gosec // The mock must be executable
+		t.Fatal(err)
+	}
+}
+
+func findRecord(records []map[string]interface{}, role string) map[string]interface{} {
+	for _, record := range records {
+		if record["role"] == role && record["event"] == nil {
+			return record
+		}
+	}
+	return nil
+}
+
+func TestWrapCommand_BuiltinProfile(t *testing.T) {
+	if os.Getenv("TEST_WRAP_COMMAND_AIDER") == "1" {
+		wrapCommand([]string{"--service", "aider", "--", "aider", "--model", "sonnet", "--api-key", "mysecretkeyvalue", "-m", "Fix the failing test", "main.go"})
+		return
+	}
+
+	tmpDir := t.TempDir()
+	writeMock(t, tmpDir, "aider", "echo 'Aider v0.86.1'\necho 'Main model: sonnet with diff edit format'\necho\necho 'The test now passes.'\necho 'Tokens: 2.1k sent, 150 received.'\nexit 3\n")
+
+	records, exitCode := runWrapCommand(t, "TestWrapCommand_BuiltinProfile", tmpDir, "", "TEST_WRAP_COMMAND_AIDER=1")
+
+	if exitCode != 3 {
+		t.Errorf("Expected the exit code of the tool, got %d", exitCode)
+	}
+	user := findRecord(records, "user")
+	if user == nil || user["content"] != "Fix the failing test" || user["service"] != "aider" {
+		t.Fatalf("Unexpected user record: %v", user)
+	}
+	metadata, _ := user["metadata"].(map[string]interface{})
+	if metadata["model"] != "sonnet" || metadata["files"] != "main.go" || metadata["flags"] != "--model sonnet --api-key" {
+		t.Errorf("Unexpected prompt metadata: %v", metadata)
+	}
+	assistant := findRecord(records, "assistant")
+	if assistant == nil || assistant["content"] != "The test now passes." || assistant["status"] != responseComplete {
+		t.Errorf("Unexpected assistant record: %v", assistant)
+	}
+}
+
+func TestWrapCommand_ConfiguredProfile(t *testing.T) {
+	if os.Getenv("TEST_WRAP_COMMAND_CONFIG") == "1" {
+		wrapCommand([]string{"mytool", "--ask", "Summarize"})
+		return
+	}
+
+	tmpDir := t.TempDir()
+	config := `{"wrap":{"profiles":{"mytool":{"prompt_flags":["--ask"],"piped_prompt":true,"response_format":"json","response_field":"answer"}}}}`
+	if err := os.WriteFile(filepath.Join(tmpDir, "config.json"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	writeMock(t, tmpDir, "mytool", "cat > /dev/null\necho '{\"answer\":\"Two lines about logs.\"}'\necho 'warning: slow model' >&2\n")
+
+	records, exitCode := runWrapCommand(t, "TestWrapCommand_ConfiguredProfile", tmpDir, "line 1\nline 2\n", "TEST_WRAP_COMMAND_CONFIG=1")
+
+	if exitCode != 0 {
+		t.Errorf("Expected success, got exit code %d", exitCode)
+	}
+	user := findRecord(records, "user")
+	if user == nil || user["content"] != "line 1\nline 2\n\nSummarize" || user["service"] != "mytool" {
+		t.Fatalf("Unexpected user record: %v", user)
+	}
+	assistant := findRecord(records, "assistant")
+	if assistant == nil || assistant["content"] != "Two lines about logs." || assistant["stderr"] != "warning: slow model" {
+		t.Errorf("Unexpected assistant record: %v", assistant)
+	}
+}
+
+func TestWrapMetadata(t *testing.T) {
+	invocation := wrap.Invocation{
+		Model:     "gpt-4o",
+		Flags:     []string{"--model", "gpt-4o", "-s", "Be brief"},
+		FlagNames: []string{"--model", "-s"},
+	}
+
+	got := wrapMetadata(invocation, []byte("input"))
+	if got["flags"] != "--model gpt-4o -s Be brief" || got["model"] != "gpt-4o" || got["stdin_bytes"] != "5" {
+		t.Errorf("Expected options with their values, got %v", got)
+	}
+}
+
+func TestWrapCommand_Usage(t *testing.T) {
+	if os.Getenv("TEST_WRAP_COMMAND_USAGE") == "1" {
+		wrapCommand([]string{"--service", "aider"})
+		return
+	}
+
+	_, exitCode := runWrapCommand(t, "TestWrapCommand_Usage", t.TempDir(), "", "TEST_WRAP_COMMAND_USAGE=1")
+	if exitCode != 1 {
+		t.Errorf("Expected exit code 1 without a command, got %d", exitCode)
+	}
+}
@@ -0,0 +1,146 @@
+// Package audit provides tamper-evident, hash-chained log records.
+// Every record carries a sequence number and the SHA-256 of the previous
+// record in the same chain, optionally signed with an Ed25519 key, so that
+// edits, deletions and reordering can be detected after the fact.
+package audit
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Record fields added by the chain writer. They are always the last fields of a record.
+const (
+	SeqKey       = "audit_seq"
+	PrevHashKey  = "audit_prev_hash"
+	SignatureKey = "audit_sig"
+)
+
+// GenesisHash is the previous-record hash of the first record in a chain
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// State is the chain position persisted between tapline invocations
+type State struct {
+	Seq  int64  `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// StateStore persists chain state; session.Manager implements it
+type StateStore interface {
+	LoadState(name string, v any) error
+	SaveState(name string, v any) error
+	WithLock(name string, fn func() error) error
+}
+
+// ChainWriter appends chain fields to every JSON record written through it
+type ChainWriter struct {
+	w         io.Writer
+	store     StateStore
+	stateName string
+	signer    ed25519.PrivateKey
+}
+
+// NewChainWriter wraps w so records are linked into the named chain.
+// A nil signer disables signatures.
+func NewChainWriter(w io.Writer, store StateStore, chain string, signer ed25519.PrivateKey) *ChainWriter {
+	return &ChainWriter{
+		w:         w,
+		store:     store,
+		stateName: "audit-" + chain,
+		signer:    signer,
+	}
+}
+
+// Write links one JSON record (slog writes one record per call) into the chain.
+// If the chain state is unavailable the record is still written, unchained,
+// so that audit problems never lose log data; verification will flag it.
+func (cw *ChainWriter) Write(p []byte) (int, error) {
+	record := bytes.TrimRight(p, "\n")
+	if !bytes.HasSuffix(record, []byte("}")) {
+		return 0, errors.New("audit: record is not a JSON object")
+	}
+
+	written := false
+	err := cw.store.WithLock(cw.stateName, func() error {
+		var state State
+		if err := cw.store.LoadState(cw.stateName, &state); err != nil {
+			return err
+		}
+		if state.Hash == "" {
+			state.Hash = GenesisHash
+		}
+
+		line := cw.link(record, state)
+		if _, err := cw.w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		written = true
+
+		return cw.store.SaveState(cw.stateName, State{Seq: state.Seq + 1, Hash: HashLine(line)})
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: audit chain %s: %v\n", cw.stateName, err)
+	}
+
+	if !written {
+		if _, err := cw.w.Write(append(record, '\n')); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// link appends sequence, previous hash and optional signature to a record
+func (cw *ChainWriter) link(record []byte, state State) []byte {
+	var b bytes.Buffer
+	b.Write(record[:len(record)-1])
+	if len(record) > 2 {
+		b.WriteByte(',')
+	}
+	b.WriteString(`"` + SeqKey + `":` + strconv.FormatInt(state.Seq+1, 10))
+	b.WriteString(`,"` + PrevHashKey + `":"` + state.Hash + `"`)
+
+	if cw.signer != nil {
+		signed := append(bytes.Clone(b.Bytes()), '}')
+		signature := ed25519.Sign(cw.signer, signed)
+		b.WriteString(`,"` + SignatureKey + `":"` + base64.RawURLEncoding.EncodeToString(signature) + `"`)
+	}
+
+	b.WriteByte('}')
+	return b.Bytes()
+}
+
+// HashLine returns the hex SHA-256 of a record as written, without its trailing newline
+func HashLine(line []byte) string {
+	hash := sha256.Sum256(line)
+	return hex.EncodeToString(hash[:])
+}
+
+// signedPortion returns the bytes covered by a record's signature and the decoded signature
+func signedPortion(line []byte) (signed, signature []byte, ok bool) {
+	marker := []byte(`,"` + SignatureKey + `":"`)
+	idx := bytes.LastIndex(line, marker)
+	if idx < 0 {
+		return nil, nil, false
+	}
+
+	encoded := line[idx+len(marker):]
+	encoded = bytes.TrimSuffix(encoded, []byte(`"}`))
+	signature, err := base64.RawURLEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, nil, false
+	}
+
+	signed = append(bytes.Clone(line[:idx]), '}')
+	return signed, signature, true
+}
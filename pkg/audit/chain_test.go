@@ -0,0 +1,214 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// memStore is an in-memory StateStore
+type memStore struct {
+	states  map[string][]byte
+	lockErr error
+}
+
+func newMemStore() *memStore {
+	return &memStore{states: make(map[string][]byte)}
+}
+
+func (m *memStore) LoadState(name string, v any) error {
+	data, ok := m.states[name]
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (m *memStore) SaveState(name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	m.states[name] = data
+	return nil
+}
+
+func (m *memStore) WithLock(_ string, fn func() error) error {
+	if m.lockErr != nil {
+		return m.lockErr
+	}
+	return fn()
+}
+
+func writeRecords(t *testing.T, w *ChainWriter, n int) {
+	t.Helper()
+	for i := range n {
+		record := `{"msg":"conversation","content":"message ` + string(rune('a'+i)) + `"}` + "\n"
+		if _, err := w.Write([]byte(record)); err != nil {
+			t.Fatalf("Failed to write record: %v", err)
+		}
+	}
+}
+
+func splitLines(buf *bytes.Buffer) []string {
+	return strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+}
+
+func verifyLines(t *testing.T, lines []string, key ed25519.PublicKey) *Report {
+	t.Helper()
+	report, err := Verify(strings.NewReader(strings.Join(lines, "\n")+"\n"), key)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	return report
+}
+
+func issueKinds(report *Report) []string {
+	kinds := make([]string, 0, len(report.Issues))
+	for _, issue := range report.Issues {
+		kinds = append(kinds, issue.Kind)
+	}
+	return kinds
+}
+
+func TestChainWriter_LinksRecords(t *testing.T) {
+	var buf bytes.Buffer
+	store := newMemStore()
+	writeRecords(t, NewChainWriter(&buf, store, "default", nil), 3)
+
+	lines := splitLines(&buf)
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %d", len(lines))
+	}
+
+	var first map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if first[SeqKey] != float64(1) || first[PrevHashKey] != GenesisHash {
+		t.Errorf("Unexpected chain fields on first record: %v", first)
+	}
+
+	var second map[string]interface{}
+	json.Unmarshal([]byte(lines[1]), &second)
+	if second[PrevHashKey] != HashLine([]byte(lines[0])) {
+		t.Errorf("Expected second record to link to the first")
+	}
+
+	report := verifyLines(t, lines, nil)
+	if !report.OK() || report.Records != 3 || report.LastSeq != 3 {
+		t.Errorf("Expected clean report for 3 records, got %+v", report)
+	}
+
+	var state State
+	store.LoadState("audit-default", &state)
+	if state.Seq != 3 || state.Hash != HashLine([]byte(lines[2])) {
+		t.Errorf("Unexpected persisted state: %+v", state)
+	}
+}
+
+func TestVerify_DetectsTampering(t *testing.T) {
+	var buf bytes.Buffer
+	writeRecords(t, NewChainWriter(&buf, newMemStore(), "default", nil), 5)
+	lines := splitLines(&buf)
+
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{"edited", []string{lines[0], strings.Replace(lines[1], "message b", "message X", 1), lines[2], lines[3], lines[4]}, IssueBrokenLink},
+		{"deleted", []string{lines[0], lines[1], lines[3], lines[4]}, IssueMissingRecords},
+		{"reordered", []string{lines[0], lines[2], lines[1], lines[3], lines[4]}, IssueReordered},
+		{"unchained", []string{lines[0], `{"msg":"conversation"}`, lines[1]}, IssueUnchained},
+		{"malformed", []string{lines[0], `{not json`, lines[1]}, IssueMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := verifyLines(t, tt.lines, nil)
+			kinds := issueKinds(report)
+			if len(kinds) == 0 || kinds[0] != tt.want {
+				t.Errorf("Expected first issue %s, got %v", tt.want, report.Issues)
+			}
+		})
+	}
+}
+
+func TestVerify_StartsMidChain(t *testing.T) {
+	var buf bytes.Buffer
+	writeRecords(t, NewChainWriter(&buf, newMemStore(), "default", nil), 4)
+	lines := splitLines(&buf)
+
+	report := verifyLines(t, lines[2:], nil)
+	if !report.OK() {
+		t.Errorf("Expected rotated log to verify, got %v", report.Issues)
+	}
+	if report.FirstSeq != 3 {
+		t.Errorf("Expected FirstSeq 3, got %d", report.FirstSeq)
+	}
+}
+
+func TestVerify_DetectsRestart(t *testing.T) {
+	var buf bytes.Buffer
+	writeRecords(t, NewChainWriter(&buf, newMemStore(), "default", nil), 2)
+	writeRecords(t, NewChainWriter(&buf, newMemStore(), "default", nil), 1)
+
+	report := verifyLines(t, splitLines(&buf), nil)
+	if kinds := issueKinds(report); len(kinds) != 1 || kinds[0] != IssueRestarted {
+		t.Errorf("Expected restarted issue, got %v", report.Issues)
+	}
+}
+
+func TestChainWriter_Signatures(t *testing.T) {
+	signingKey, verifyKey, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ParseSigningKey(signingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ParseVerifyKey(verifyKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	writeRecords(t, NewChainWriter(&buf, newMemStore(), "default", signer), 2)
+	lines := splitLines(&buf)
+
+	report := verifyLines(t, lines, pub)
+	if !report.OK() || report.Signed != 2 {
+		t.Errorf("Expected 2 valid signatures, got %+v", report)
+	}
+
+	// Editing the last record cannot be caught by the chain, but is caught by its signature
+	tampered := []string{lines[0], strings.Replace(lines[1], "message b", "message X", 1)}
+	if kinds := issueKinds(verifyLines(t, tampered, pub)); len(kinds) != 1 || kinds[0] != IssueBadSignature {
+		t.Errorf("Expected bad_signature, got %v", kinds)
+	}
+
+	var unsigned bytes.Buffer
+	writeRecords(t, NewChainWriter(&unsigned, newMemStore(), "default", nil), 1)
+	if kinds := issueKinds(verifyLines(t, splitLines(&unsigned), pub)); len(kinds) != 1 || kinds[0] != IssueMissingSignature {
+		t.Errorf("Expected missing_signature, got %v", kinds)
+	}
+}
+
+func TestChainWriter_LockFailureStillWrites(t *testing.T) {
+	var buf bytes.Buffer
+	store := newMemStore()
+	store.lockErr = errors.New("lock unavailable")
+
+	record := `{"msg":"conversation","content":"kept"}` + "\n"
+	if _, err := NewChainWriter(&buf, store, "default", nil).Write([]byte(record)); err != nil {
+		t.Fatalf("Expected write to succeed, got %v", err)
+	}
+	if buf.String() != record {
+		t.Errorf("Expected unchained record to be written, got %s", buf.String())
+	}
+}
@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// SigningKeyPrefix marks an encoded Ed25519 signing (secret) key
+	SigningKeyPrefix = "TAPLINE-SIGN-SK1-"
+	// VerifyKeyPrefix marks an encoded Ed25519 verification (public) key
+	VerifyKeyPrefix = "tapline-sign-pk1-"
+)
+
+var keyEncoding = base64.RawURLEncoding
+
+// GenerateSigningKey creates a new Ed25519 key pair and returns the encoded signing and verification keys
+func GenerateSigningKey() (signingKey, verifyKey string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	return SigningKeyPrefix + keyEncoding.EncodeToString(priv.Seed()),
+		VerifyKeyPrefix + keyEncoding.EncodeToString(pub), nil
+}
+
+// ParseSigningKey decodes an encoded signing key
+func ParseSigningKey(encoded string) (ed25519.PrivateKey, error) {
+	raw, err := decodeKey(encoded, SigningKeyPrefix, ed25519.SeedSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %w", err)
+	}
+	return ed25519.NewKeyFromSeed(raw), nil
+}
+
+// ParseVerifyKey decodes an encoded verification key
+func ParseVerifyKey(encoded string) (ed25519.PublicKey, error) {
+	raw, err := decodeKey(encoded, VerifyKeyPrefix, ed25519.PublicKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid verification key: %w", err)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// LoadSigningKey reads a signing key file. Blank lines and lines starting with '#' are ignored.
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	key, err := readKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSigningKey(key)
+}
+
+// LoadVerifyKey accepts an encoded verification key or the path of a file containing one
+func LoadVerifyKey(keyOrPath string) (ed25519.PublicKey, error) {
+	if strings.HasPrefix(keyOrPath, VerifyKeyPrefix) {
+		return ParseVerifyKey(keyOrPath)
+	}
+	key, err := readKeyFile(keyOrPath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseVerifyKey(key)
+}
+
+func readKeyFile(path string) (string, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // Key path is chosen by the user
+	if err != nil {
+		return "", fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return line, nil
+	}
+
+	return "", fmt.Errorf("no key found in %s", path)
+}
+
+func decodeKey(encoded, prefix string, size int) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	if !strings.HasPrefix(encoded, prefix) {
+		return nil, fmt.Errorf("missing %q prefix", prefix)
+	}
+	raw, err := keyEncoding.DecodeString(strings.TrimPrefix(encoded, prefix))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != size {
+		return nil, fmt.Errorf("expected %d bytes, got %d", size, len(raw))
+	}
+	return raw, nil
+}
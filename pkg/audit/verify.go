@@ -0,0 +1,178 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Issue kinds reported by Verify
+const (
+	IssueMalformed        = "malformed"
+	IssueUnchained        = "unchained"
+	IssueBrokenLink       = "broken_link"
+	IssueMissingRecords   = "missing_records"
+	IssueReordered        = "reordered"
+	IssueRestarted        = "restarted"
+	IssueBadSignature     = "bad_signature"
+	IssueMissingSignature = "missing_signature"
+)
+
+// Issue describes one integrity problem found in a log
+type Issue struct {
+	Line   int    `json:"line"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+// Report summarizes the verification of a log
+type Report struct {
+	Records  int     `json:"records"`
+	Signed   int     `json:"signed"`
+	FirstSeq int64   `json:"first_seq"`
+	LastSeq  int64   `json:"last_seq"`
+	LastHash string  `json:"last_hash"`
+	Issues   []Issue `json:"issues,omitempty"`
+
+	seen map[int64]bool
+	gaps []gap
+}
+
+// gap is a run of sequence numbers missing at the point it was detected
+type gap struct {
+	issue    int
+	from, to int64
+}
+
+// OK reports whether no integrity problems were found
+func (r *Report) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// chainFields are the audit fields of a record
+type chainFields struct {
+	Seq      *int64 `json:"audit_seq"`
+	PrevHash string `json:"audit_prev_hash"`
+}
+
+// Verify walks a hash-chained JSON Lines log and reports broken links,
+// deletions, reordering and, when verifyKey is set, invalid signatures.
+// A log that starts mid-chain (e.g. after rotation) is not an error; FirstSeq shows where it starts.
+func Verify(r io.Reader, verifyKey ed25519.PublicKey) (*Report, error) {
+	report := &Report{seen: make(map[int64]bool)}
+	reader := bufio.NewReader(r)
+
+	var lastSeq int64
+	var lastHash string
+	lineNum := 0
+
+	for {
+		raw, readErr := reader.ReadBytes('\n')
+		line := trimLine(raw)
+		if len(line) > 0 {
+			lineNum++
+			seq, hash, ok := report.check(line, lineNum, lastSeq, lastHash, verifyKey)
+			if ok {
+				lastSeq, lastHash = seq, hash
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return report, readErr
+		}
+	}
+
+	report.LastSeq = lastSeq
+	report.LastHash = lastHash
+	report.classifyGaps()
+	return report, nil
+}
+
+// classifyGaps turns gaps whose records all appear elsewhere in the log into reorderings
+func (r *Report) classifyGaps() {
+	for _, g := range r.gaps {
+		found := true
+		for seq := g.from; seq <= g.to; seq++ {
+			if !r.seen[seq] {
+				found = false
+				break
+			}
+		}
+		if found {
+			r.Issues[g.issue].Kind = IssueReordered
+			r.Issues[g.issue].Detail = fmt.Sprintf("seq %d-%d appear elsewhere in the log", g.from, g.to)
+		}
+	}
+}
+
+// check verifies one record against the previous chained record and returns its chain position
+func (r *Report) check(line []byte, lineNum int, lastSeq int64, lastHash string, verifyKey ed25519.PublicKey) (int64, string, bool) {
+	var fields chainFields
+	if err := json.Unmarshal(line, &fields); err != nil {
+		r.addIssue(lineNum, IssueMalformed, fmt.Sprintf("not valid JSON: %v", err))
+		return 0, "", false
+	}
+	if fields.Seq == nil {
+		r.addIssue(lineNum, IssueUnchained, "record has no audit chain fields")
+		return 0, "", false
+	}
+
+	r.Records++
+	seq := *fields.Seq
+	r.seen[seq] = true
+
+	r.checkSignature(line, lineNum, verifyKey)
+
+	switch {
+	case lastHash == "":
+		r.FirstSeq = seq
+		if seq == 1 && fields.PrevHash != GenesisHash {
+			r.addIssue(lineNum, IssueBrokenLink, "first record does not start from the genesis hash")
+		}
+	case seq == 1 && fields.PrevHash == GenesisHash:
+		r.addIssue(lineNum, IssueRestarted, fmt.Sprintf("chain restarted after seq %d (chain state was reset)", lastSeq))
+	case seq == lastSeq+1:
+		if fields.PrevHash != lastHash {
+			r.addIssue(lineNum, IssueBrokenLink, fmt.Sprintf("previous record (seq %d) was modified", lastSeq))
+		}
+	case seq > lastSeq+1:
+		r.gaps = append(r.gaps, gap{issue: len(r.Issues), from: lastSeq + 1, to: seq - 1})
+		r.addIssue(lineNum, IssueMissingRecords, fmt.Sprintf("seq %d-%d missing (%d record(s) deleted)", lastSeq+1, seq-1, seq-lastSeq-1))
+	default:
+		r.addIssue(lineNum, IssueReordered, fmt.Sprintf("seq %d appears after seq %d", seq, lastSeq))
+	}
+
+	return seq, HashLine(line), true
+}
+
+func (r *Report) checkSignature(line []byte, lineNum int, verifyKey ed25519.PublicKey) {
+	signed, signature, hasSignature := signedPortion(line)
+	if hasSignature {
+		r.Signed++
+	}
+	if verifyKey == nil {
+		return
+	}
+
+	switch {
+	case !hasSignature:
+		r.addIssue(lineNum, IssueMissingSignature, "record is not signed")
+	case !ed25519.Verify(verifyKey, signed, signature):
+		r.addIssue(lineNum, IssueBadSignature, "signature does not match record")
+	}
+}
+
+func (r *Report) addIssue(line int, kind, detail string) {
+	r.Issues = append(r.Issues, Issue{Line: line, Kind: kind, Detail: detail})
+}
+
+func trimLine(line []byte) []byte {
+	for len(line) > 0 && (line[len(line)-1] == '\n' || line[len(line)-1] == '\r') {
+		line = line[:len(line)-1]
+	}
+	return line
+}
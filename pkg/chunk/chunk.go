@@ -0,0 +1,54 @@
+// Package chunk provides size limiting of logged content.
+// Oversized content is either truncated or split into linked chunk records
+// that can be reassembled losslessly.
+package chunk
+
+import "unicode/utf8"
+
+// Record fields used to link chunks of one event
+const (
+	EventIDKey = "event_id"
+	IndexKey   = "chunk_index"
+	CountKey   = "chunk_count"
+)
+
+// Split breaks s into pieces of at most maxBytes bytes without splitting UTF-8 characters.
+// A non-positive maxBytes or a short s yields a single piece.
+func Split(s string, maxBytes int) []string {
+	if maxBytes <= 0 || len(s) <= maxBytes {
+		return []string{s}
+	}
+
+	pieces := make([]string, 0, len(s)/maxBytes+1)
+	for len(s) > maxBytes {
+		cut := boundary(s, maxBytes)
+		pieces = append(pieces, s[:cut])
+		s = s[cut:]
+	}
+	if s != "" {
+		pieces = append(pieces, s)
+	}
+	return pieces
+}
+
+// Truncate shortens s to at most maxBytes bytes without splitting UTF-8 characters
+func Truncate(s string, maxBytes int) string {
+	if maxBytes <= 0 || len(s) <= maxBytes {
+		return s
+	}
+	return s[:boundary(s, maxBytes)]
+}
+
+// boundary returns the largest cut position <= n that does not split a UTF-8 character.
+// It always returns at least one character so progress is guaranteed.
+func boundary(s string, n int) int {
+	cut := n
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	if cut == 0 {
+		_, size := utf8.DecodeRuneInString(s)
+		return size
+	}
+	return cut
+}
@@ -0,0 +1,53 @@
+package chunk
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplit(t *testing.T) {
+	pieces := Split("abcdefghij", 4)
+	if len(pieces) != 3 || pieces[0] != "abcd" || pieces[2] != "ij" {
+		t.Errorf("Unexpected pieces: %q", pieces)
+	}
+
+	if got := Split("short", 10); len(got) != 1 || got[0] != "short" {
+		t.Errorf("Expected single piece, got %q", got)
+	}
+
+	if got := Split("unlimited", 0); len(got) != 1 {
+		t.Errorf("Expected no split without a limit, got %q", got)
+	}
+}
+
+func TestSplit_UTF8Boundaries(t *testing.T) {
+	s := strings.Repeat("日本語", 10)
+
+	pieces := Split(s, 7)
+	for _, piece := range pieces {
+		if !utf8.ValidString(piece) {
+			t.Errorf("Piece is not valid UTF-8: %q", piece)
+		}
+		if len(piece) > 7 {
+			t.Errorf("Piece exceeds limit: %d bytes", len(piece))
+		}
+	}
+	if strings.Join(pieces, "") != s {
+		t.Error("Expected pieces to join back to the original")
+	}
+
+	// A limit smaller than one character still makes progress
+	if got := Split("日本", 1); len(got) != 2 || got[0] != "日" {
+		t.Errorf("Expected one character per piece, got %q", got)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := Truncate("héllo", 2); got != "h" {
+		t.Errorf("Expected truncation before multi-byte character, got %q", got)
+	}
+	if got := Truncate("hello", 10); got != "hello" {
+		t.Errorf("Expected unchanged string, got %q", got)
+	}
+}
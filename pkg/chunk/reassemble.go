@@ -0,0 +1,206 @@
+package chunk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrIncomplete is returned when chunked events are missing chunks at the end of input
+var ErrIncomplete = errors.New("incomplete chunked events")
+
+// field is one top-level member of a JSON object
+type field struct {
+	key   string
+	value json.RawMessage
+}
+
+// pending collects the chunks of one event
+type pending struct {
+	lines  [][]byte
+	fields [][]field
+	parts  map[int]string
+	count  int
+}
+
+// Reassemble copies a JSON Lines log from r to w, merging chunk records that
+// share an event_id back into a single record with the full content. Records
+// without chunks pass through unchanged. Chunks still missing at the end of
+// input are written as-is and reported with ErrIncomplete.
+func Reassemble(r io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(r)
+	writer := bufio.NewWriter(w)
+
+	open := make(map[string]*pending)
+	var order []string
+
+	for {
+		raw, readErr := reader.ReadBytes('\n')
+		line := bytes.TrimRight(raw, "\r\n")
+		if len(line) > 0 {
+			merged, err := addLine(line, open, &order)
+			if err != nil {
+				return err
+			}
+			for _, out := range merged {
+				if _, err := writer.Write(append(out, '\n')); err != nil {
+					return err
+				}
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+
+	incomplete := 0
+	for _, id := range order {
+		p, ok := open[id]
+		if !ok {
+			continue
+		}
+		incomplete++
+		for _, line := range p.lines {
+			if _, err := writer.Write(append(line, '\n')); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if incomplete > 0 {
+		return fmt.Errorf("%w: %d event(s)", ErrIncomplete, incomplete)
+	}
+	return nil
+}
+
+// addLine processes one record and returns the lines ready to be written
+func addLine(line []byte, open map[string]*pending, order *[]string) ([][]byte, error) {
+	fields, err := parseObject(line)
+	if err != nil {
+		return [][]byte{line}, nil
+	}
+
+	var eventID string
+	var index, count int
+	var content string
+	hasChunk := false
+	for _, f := range fields {
+		switch f.key {
+		case EventIDKey:
+			_ = json.Unmarshal(f.value, &eventID) //nolint:errcheck // Non-string IDs are treated as absent
+		case IndexKey:
+			hasChunk = json.Unmarshal(f.value, &index) == nil
+		case CountKey:
+			_ = json.Unmarshal(f.value, &count) //nolint:errcheck // Validated below
+		case "content":
+			_ = json.Unmarshal(f.value, &content) //nolint:errcheck // Non-string content is treated as empty
+		}
+	}
+
+	if !hasChunk || eventID == "" || count < 2 || index < 0 || index >= count {
+		return [][]byte{line}, nil
+	}
+
+	p, ok := open[eventID]
+	if !ok {
+		p = &pending{parts: make(map[int]string), count: count}
+		open[eventID] = p
+		*order = append(*order, eventID)
+	}
+	p.lines = append(p.lines, line)
+	p.fields = append(p.fields, fields)
+	p.parts[index] = content
+
+	if len(p.parts) < p.count {
+		return nil, nil
+	}
+
+	delete(open, eventID)
+	merged, err := p.merge()
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{merged}, nil
+}
+
+// merge builds one record from the first chunk with the full content
+func (p *pending) merge() ([]byte, error) {
+	var full strings.Builder
+	for i := range p.count {
+		full.WriteString(p.parts[i])
+	}
+
+	content, err := json.Marshal(full.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	b.WriteByte('{')
+	first := true
+	for _, f := range p.fields[0] {
+		if f.key == IndexKey || f.key == CountKey {
+			continue
+		}
+		value := f.value
+		if f.key == "content" {
+			value = content
+		}
+		if !first {
+			b.WriteByte(',')
+		}
+		first = false
+		key, err := json.Marshal(f.key)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+
+	return b.Bytes(), nil
+}
+
+// parseObject decodes the top-level members of a JSON object, preserving their order
+func parseObject(line []byte) ([]field, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+
+	tok, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, errors.New("not a JSON object")
+	}
+
+	var fields []field
+	for decoder.More() {
+		tok, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, errors.New("invalid object key")
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		fields = append(fields, field{key: key, value: value})
+	}
+
+	return fields, nil
+}
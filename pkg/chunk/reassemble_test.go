@@ -0,0 +1,59 @@
+package chunk
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReassemble(t *testing.T) {
+	input := strings.Join([]string{
+		`{"msg":"conversation","event_id":"a","role":"user","content":"hello"}`,
+		`{"msg":"conversation","event_id":"b","role":"assistant","content":"Hel","chunk_index":0,"chunk_count":3}`,
+		`{"msg":"conversation","event_id":"b","role":"assistant","content":"lo ","chunk_index":1,"chunk_count":3}`,
+		`{"msg":"conversation","event_id":"b","role":"assistant","content":"\"world\"\n","chunk_index":2,"chunk_count":3}`,
+		`not json`,
+	}, "\n") + "\n"
+
+	var out bytes.Buffer
+	if err := Reassemble(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Reassemble failed: %v", err)
+	}
+
+	want := strings.Join([]string{
+		`{"msg":"conversation","event_id":"a","role":"user","content":"hello"}`,
+		`{"msg":"conversation","event_id":"b","role":"assistant","content":"Hello \"world\"\n"}`,
+		`not json`,
+	}, "\n") + "\n"
+
+	if out.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestReassemble_OutOfOrderChunks(t *testing.T) {
+	input := `{"event_id":"x","content":"B","chunk_index":1,"chunk_count":2}
+{"event_id":"x","content":"A","chunk_index":0,"chunk_count":2}
+`
+	var out bytes.Buffer
+	if err := Reassemble(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Reassemble failed: %v", err)
+	}
+	if out.String() != `{"event_id":"x","content":"AB"}`+"\n" {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+}
+
+func TestReassemble_Incomplete(t *testing.T) {
+	input := `{"event_id":"x","content":"A","chunk_index":0,"chunk_count":2}` + "\n"
+
+	var out bytes.Buffer
+	err := Reassemble(strings.NewReader(input), &out)
+	if !errors.Is(err, ErrIncomplete) {
+		t.Errorf("Expected ErrIncomplete, got %v", err)
+	}
+	if out.String() != input {
+		t.Errorf("Expected incomplete chunks to be passed through, got %s", out.String())
+	}
+}
@@ -0,0 +1,238 @@
+// Package claude provides parsing of Claude Code hook payloads and session transcripts.
+package claude
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Hook event names sent by Claude Code in hook_event_name
+const (
+	HookSessionStart     = "SessionStart"
+	HookSessionEnd       = "SessionEnd"
+	HookUserPromptSubmit = "UserPromptSubmit"
+	HookStop             = "Stop"
+	HookSubagentStop     = "SubagentStop"
+	HookPreToolUse       = "PreToolUse"
+	HookPostToolUse      = "PostToolUse"
+	HookNotification     = "Notification"
+	HookPreCompact       = "PreCompact"
+)
+
+// NotificationPermissionPrompt is the notification_type of a Notification asking for tool permission
+const NotificationPermissionPrompt = "permission_prompt"
+
+// Claude Code tool names with dedicated handling
+const (
+	ToolBash      = "Bash"
+	ToolEdit      = "Edit"
+	ToolMultiEdit = "MultiEdit"
+	ToolWrite     = "Write"
+	// ToolTask launches a subagent
+	ToolTask = "Task"
+)
+
+// IsFileEditTool reports whether the named tool modifies a file
+func IsFileEditTool(name string) bool {
+	return name == ToolEdit || name == ToolMultiEdit || name == ToolWrite
+}
+
+// EditInput is the tool input of the Edit, MultiEdit and Write tools
+type EditInput struct {
+	FilePath string `json:"file_path"`
+
+	// Edit
+	OldString  string `json:"old_string,omitempty"`
+	NewString  string `json:"new_string,omitempty"`
+	ReplaceAll bool   `json:"replace_all,omitempty"`
+
+	// MultiEdit
+	Edits []EditInput `json:"edits,omitempty"`
+
+	// Write
+	Content string `json:"content,omitempty"`
+}
+
+// HookInput is the JSON payload Claude Code writes to a hook command's stdin
+type HookInput struct {
+	SessionID      string `json:"session_id"`
+	TranscriptPath string `json:"transcript_path"`
+	Cwd            string `json:"cwd"`
+	HookEventName  string `json:"hook_event_name"`
+
+	// UserPromptSubmit
+	Prompt string `json:"prompt,omitempty"`
+
+	// SessionStart ("startup", "resume", "clear", "compact") and SessionEnd
+	Source string `json:"source,omitempty"`
+	Reason string `json:"reason,omitempty"`
+
+	// Stop and SubagentStop
+	StopHookActive bool `json:"stop_hook_active,omitempty"`
+
+	// SubagentStop in Claude Code versions that keep subagent transcripts in separate files
+	AgentID             string `json:"agent_id,omitempty"`
+	AgentTranscriptPath string `json:"agent_transcript_path,omitempty"`
+
+	// PreCompact ("manual" or "auto")
+	Trigger            string `json:"trigger,omitempty"`
+	CustomInstructions string `json:"custom_instructions,omitempty"`
+
+	// Notification; notification_type is missing in older Claude Code versions
+	Message          string `json:"message,omitempty"`
+	NotificationType string `json:"notification_type,omitempty"`
+
+	// PreToolUse and PostToolUse
+	ToolName     string          `json:"tool_name,omitempty"`
+	ToolUseID    string          `json:"tool_use_id,omitempty"`
+	ToolInput    json.RawMessage `json:"tool_input,omitempty"`
+	ToolResponse json.RawMessage `json:"tool_response,omitempty"`
+}
+
+// ReadHookInput decodes a hook payload
+func ReadHookInput(r io.Reader) (*HookInput, error) {
+	var input HookInput
+	if err := json.NewDecoder(r).Decode(&input); err != nil {
+		return nil, fmt.Errorf("failed to parse hook input: %w", err)
+	}
+	if input.HookEventName == "" {
+		return nil, fmt.Errorf("hook input has no hook_event_name")
+	}
+	return &input, nil
+}
+
+// PermissionTool reports whether a Notification asks the user for permission to
+// use a tool, and which one. The tool is named only in the message ("Claude needs
+// your permission to use Bash"), so the name may be empty.
+func (h *HookInput) PermissionTool() (string, bool) {
+	if h.HookEventName != HookNotification {
+		return "", false
+	}
+	if h.NotificationType != "" && h.NotificationType != NotificationPermissionPrompt {
+		return "", false
+	}
+
+	const marker = "permission to use "
+	idx := strings.Index(h.Message, marker)
+	if idx < 0 {
+		return "", h.NotificationType == NotificationPermissionPrompt
+	}
+	words := strings.Fields(h.Message[idx+len(marker):])
+	if len(words) == 0 {
+		return "", true
+	}
+	return strings.TrimRight(words[0], ".,:;!?"), true
+}
+
+// ToolResponseText returns the tool output of a PostToolUse payload: a string
+// response as-is, anything else as compact JSON
+func (h *HookInput) ToolResponseText() string {
+	if len(h.ToolResponse) == 0 {
+		return ""
+	}
+
+	var text string
+	if err := json.Unmarshal(h.ToolResponse, &text); err == nil {
+		return text
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, h.ToolResponse); err != nil {
+		return string(h.ToolResponse)
+	}
+	return buf.String()
+}
+
+// ToolSucceeded reports whether a PostToolUse response indicates success.
+// Tools report failure differently: an explicit "success": false, "is_error": true,
+// "interrupted": true or a non-empty "error". Anything else counts as success.
+func (h *HookInput) ToolSucceeded() bool {
+	var response struct {
+		Success     *bool           `json:"success"`
+		IsError     bool            `json:"is_error"`
+		Interrupted bool            `json:"interrupted"`
+		Error       json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(h.ToolResponse, &response); err != nil {
+		return true
+	}
+
+	if response.Success != nil {
+		return *response.Success
+	}
+	if response.IsError || response.Interrupted {
+		return false
+	}
+
+	errValue := string(bytes.TrimSpace(response.Error))
+	return errValue == "" || errValue == "null" || errValue == `""` || errValue == "false"
+}
+
+// BashCommand returns the command line of a Bash tool payload
+func (h *HookInput) BashCommand() string {
+	return BashCommand(h.ToolInput)
+}
+
+// BashCommand returns the command line from a Bash tool input
+func BashCommand(toolInput json.RawMessage) string {
+	var input struct {
+		Command string `json:"command"`
+	}
+	if err := json.Unmarshal(toolInput, &input); err != nil {
+		return ""
+	}
+	return input.Command
+}
+
+// ToolExitCode returns the exit code reported in a PostToolUse response, if any
+func (h *HookInput) ToolExitCode() (int, bool) {
+	var response struct {
+		ExitCode    *int `json:"exit_code"`
+		ExitCodeAlt *int `json:"exitCode"`
+		ReturnCode  *int `json:"returnCode"`
+	}
+	if err := json.Unmarshal(h.ToolResponse, &response); err != nil {
+		return 0, false
+	}
+
+	for _, code := range []*int{response.ExitCode, response.ExitCodeAlt, response.ReturnCode} {
+		if code != nil {
+			return *code, true
+		}
+	}
+	return 0, false
+}
+
+// EditInput decodes the tool input of a file editing tool
+func (h *HookInput) EditInput() (*EditInput, error) {
+	var input EditInput
+	if err := json.Unmarshal(h.ToolInput, &input); err != nil {
+		return nil, fmt.Errorf("failed to parse %s input: %w", h.ToolName, err)
+	}
+	return &input, nil
+}
+
+// OriginalFile returns the file content before the edit when the tool response includes it
+func (h *HookInput) OriginalFile() (string, bool) {
+	var response struct {
+		OriginalFile *string `json:"originalFile"`
+	}
+	if err := json.Unmarshal(h.ToolResponse, &response); err != nil || response.OriginalFile == nil {
+		return "", false
+	}
+	return *response.OriginalFile, true
+}
+
+// SubagentType returns the subagent_type of a Task tool input
+func SubagentType(toolInput json.RawMessage) string {
+	var input struct {
+		SubagentType string `json:"subagent_type"`
+	}
+	if err := json.Unmarshal(toolInput, &input); err != nil {
+		return ""
+	}
+	return input.SubagentType
+}
@@ -0,0 +1,159 @@
+package claude
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadHookInput_ToolUse(t *testing.T) {
+	payload := `{"session_id":"s1","hook_event_name":"PostToolUse","tool_name":"Bash","tool_use_id":"toolu_1",` +
+		`"tool_input":{"command":"ls"},"tool_response":{"stdout":"main.go","stderr":"","interrupted":false}}`
+
+	input, err := ReadHookInput(strings.NewReader(payload))
+	if err != nil {
+		t.Fatalf("Failed to read hook input: %v", err)
+	}
+
+	if input.ToolName != "Bash" || input.ToolUseID != "toolu_1" {
+		t.Errorf("Unexpected tool fields: %+v", input)
+	}
+	if string(input.ToolInput) != `{"command":"ls"}` {
+		t.Errorf("Expected raw tool input, got %s", input.ToolInput)
+	}
+	if got := input.ToolResponseText(); got != `{"stdout":"main.go","stderr":"","interrupted":false}` {
+		t.Errorf("Unexpected response text: %s", got)
+	}
+	if !input.ToolSucceeded() {
+		t.Error("Expected success")
+	}
+}
+
+func TestReadHookInput_MissingEvent(t *testing.T) {
+	if _, err := ReadHookInput(strings.NewReader(`{"session_id":"s1"}`)); err == nil {
+		t.Error("Expected error for payload without hook_event_name")
+	}
+}
+
+func TestToolResponseText_String(t *testing.T) {
+	input := HookInput{ToolResponse: []byte(`"file written"`)}
+	if got := input.ToolResponseText(); got != "file written" {
+		t.Errorf("Expected string response as-is, got %q", got)
+	}
+}
+
+func TestToolSucceeded(t *testing.T) {
+	tests := []struct {
+		response string
+		want     bool
+	}{
+		{``, true},
+		{`"ok"`, true},
+		{`{"stdout":"x"}`, true},
+		{`{"success":true}`, true},
+		{`{"success":false}`, false},
+		{`{"is_error":true}`, false},
+		{`{"interrupted":true}`, false},
+		{`{"error":"permission denied"}`, false},
+		{`{"error":null}`, true},
+		{`{"error":""}`, true},
+	}
+
+	for _, tt := range tests {
+		input := HookInput{ToolResponse: []byte(tt.response)}
+		if got := input.ToolSucceeded(); got != tt.want {
+			t.Errorf("ToolSucceeded(%s) = %v, want %v", tt.response, got, tt.want)
+		}
+	}
+}
+
+func TestBashCommand(t *testing.T) {
+	input := HookInput{ToolName: ToolBash, ToolInput: []byte(`{"command":"go test ./...","description":"Run tests"}`)}
+	if got := input.BashCommand(); got != "go test ./..." {
+		t.Errorf("Expected command, got %q", got)
+	}
+}
+
+func TestToolExitCode(t *testing.T) {
+	input := HookInput{ToolResponse: []byte(`{"stdout":"","exitCode":2}`)}
+	if code, ok := input.ToolExitCode(); !ok || code != 2 {
+		t.Errorf("Expected exit code 2, got %d (%v)", code, ok)
+	}
+
+	input = HookInput{ToolResponse: []byte(`{"stdout":"ok"}`)}
+	if _, ok := input.ToolExitCode(); ok {
+		t.Error("Expected no exit code")
+	}
+}
+
+func TestEditInput(t *testing.T) {
+	input := HookInput{
+		ToolName:  ToolMultiEdit,
+		ToolInput: []byte(`{"file_path":"/w/a.go","edits":[{"old_string":"a","new_string":"b"},{"old_string":"c","new_string":"d","replace_all":true}]}`),
+	}
+
+	edit, err := input.EditInput()
+	if err != nil {
+		t.Fatalf("Failed to parse edit input: %v", err)
+	}
+	if edit.FilePath != "/w/a.go" || len(edit.Edits) != 2 || !edit.Edits[1].ReplaceAll {
+		t.Errorf("Unexpected edit input: %+v", edit)
+	}
+	if !IsFileEditTool(ToolMultiEdit) || IsFileEditTool(ToolBash) {
+		t.Error("Unexpected IsFileEditTool result")
+	}
+}
+
+func TestOriginalFile(t *testing.T) {
+	input := HookInput{ToolResponse: []byte(`{"filePath":"/w/a.go","originalFile":"package a\n"}`)}
+	if original, ok := input.OriginalFile(); !ok || original != "package a\n" {
+		t.Errorf("Expected original file, got %q (%v)", original, ok)
+	}
+
+	input = HookInput{ToolResponse: []byte(`{"filePath":"/w/a.go"}`)}
+	if _, ok := input.OriginalFile(); ok {
+		t.Error("Expected no original file")
+	}
+}
+
+func TestPermissionTool(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    HookInput
+		wantTool string
+		want     bool
+	}{
+		{
+			name:     "permission notification",
+			input:    HookInput{HookEventName: HookNotification, Message: "Claude needs your permission to use Bash"},
+			wantTool: "Bash",
+			want:     true,
+		},
+		{
+			name:     "typed permission notification",
+			input:    HookInput{HookEventName: HookNotification, NotificationType: NotificationPermissionPrompt, Message: "Claude needs your permission to use mcp__github__create_issue."},
+			wantTool: "mcp__github__create_issue",
+			want:     true,
+		},
+		{
+			name:  "idle notification",
+			input: HookInput{HookEventName: HookNotification, NotificationType: "idle_prompt", Message: "Claude is waiting for your input"},
+		},
+		{
+			name:  "untyped idle notification",
+			input: HookInput{HookEventName: HookNotification, Message: "Claude is waiting for your input"},
+		},
+		{
+			name:  "other hook",
+			input: HookInput{HookEventName: HookPreToolUse, ToolName: "Bash"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool, ok := tt.input.PermissionTool()
+			if tool != tt.wantTool || ok != tt.want {
+				t.Errorf("PermissionTool() = (%q, %v), want (%q, %v)", tool, ok, tt.wantTool, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package claude
+
+import (
+	"encoding/json"
+)
+
+// Stream message types of claude -p --output-format stream-json
+const (
+	StreamSystem    = "system"
+	StreamAssistant = "assistant"
+	StreamUser      = "user"
+	StreamResult    = "result"
+)
+
+// SubtypeInit marks the system message that opens a stream
+const SubtypeInit = "init"
+
+// StreamMessage is one line of stream-json output. Fields are set according to Type.
+type StreamMessage struct {
+	Type      string `json:"type"`
+	Subtype   string `json:"subtype,omitempty"`
+	SessionID string `json:"session_id"`
+
+	// system init
+	Cwd               string   `json:"cwd,omitempty"`
+	Model             string   `json:"model,omitempty"`
+	Tools             []string `json:"tools,omitempty"`
+	PermissionMode    string   `json:"permissionMode,omitempty"`
+	ClaudeCodeVersion string   `json:"claude_code_version,omitempty"`
+
+	// assistant and user
+	Message *Message `json:"message,omitempty"`
+	// ParentToolUseID is the Task tool call a subagent message belongs to
+	ParentToolUseID string `json:"parent_tool_use_id,omitempty"`
+
+	// result
+	IsError           bool              `json:"is_error,omitempty"`
+	DurationMS        int64             `json:"duration_ms,omitempty"`
+	DurationAPIMS     int64             `json:"duration_api_ms,omitempty"`
+	NumTurns          int               `json:"num_turns,omitempty"`
+	Result            string            `json:"result,omitempty"`
+	TotalCostUSD      float64           `json:"total_cost_usd,omitempty"`
+	Usage             *Usage            `json:"usage,omitempty"`
+	PermissionDenials []json.RawMessage `json:"permission_denials,omitempty"`
+}
+
+// ParseStreamMessage decodes a line of stream-json output. Lines that are not
+// stream messages, such as plain text output, are reported as not ok.
+func ParseStreamMessage(line []byte) (*StreamMessage, bool) {
+	var msg StreamMessage
+	if err := json.Unmarshal(line, &msg); err != nil || msg.Type == "" {
+		return nil, false
+	}
+	return &msg, true
+}
@@ -0,0 +1,24 @@
+package claude
+
+import "testing"
+
+func TestParseStreamMessage(t *testing.T) {
+	msg, ok := ParseStreamMessage([]byte(`{"type":"system","subtype":"init","session_id":"s1","cwd":"/work","model":"claude-sonnet-4-5","tools":["Bash","Edit"],"permissionMode":"default","claude_code_version":"2.0.0"}`))
+	if !ok || msg.Subtype != SubtypeInit || msg.SessionID != "s1" || msg.Model != "claude-sonnet-4-5" || len(msg.Tools) != 2 {
+		t.Errorf("Unexpected init message: %+v", msg)
+	}
+
+	msg, ok = ParseStreamMessage([]byte(`{"type":"assistant","session_id":"s1","parent_tool_use_id":null,"message":{"role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"ls"}}],"usage":{"input_tokens":10,"output_tokens":5}}}`))
+	if !ok || msg.Message == nil || msg.Message.Content[0].Name != "Bash" || msg.Message.Usage.OutputTokens != 5 || msg.ParentToolUseID != "" {
+		t.Errorf("Unexpected assistant message: %+v", msg)
+	}
+
+	msg, ok = ParseStreamMessage([]byte(`{"type":"result","subtype":"success","is_error":false,"duration_ms":5200,"duration_api_ms":4100,"num_turns":3,"result":"Done","session_id":"s1","total_cost_usd":0.0123,"usage":{"input_tokens":100,"output_tokens":40},"permission_denials":[{"tool_name":"Bash"}]}`))
+	if !ok || msg.DurationMS != 5200 || msg.NumTurns != 3 || msg.TotalCostUSD != 0.0123 || len(msg.PermissionDenials) != 1 {
+		t.Errorf("Unexpected result message: %+v", msg)
+	}
+
+	if _, ok := ParseStreamMessage([]byte("Done")); ok {
+		t.Error("Expected plain text not to parse as a stream message")
+	}
+}
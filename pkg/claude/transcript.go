@@ -0,0 +1,190 @@
+package claude
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Transcript entry types
+const (
+	EntryUser      = "user"
+	EntryAssistant = "assistant"
+	EntrySystem    = "system"
+)
+
+// SubtypeCompactBoundary marks the point in a transcript where the context was compacted
+const SubtypeCompactBoundary = "compact_boundary"
+
+// Content block types
+const (
+	BlockText       = "text"
+	BlockToolUse    = "tool_use"
+	BlockToolResult = "tool_result"
+)
+
+// TranscriptEntry is one line of a Claude Code transcript JSONL file
+type TranscriptEntry struct {
+	Type        string  `json:"type"`
+	UUID        string  `json:"uuid"`
+	ParentUUID  string  `json:"parentUuid"`
+	SessionID   string  `json:"sessionId"`
+	Cwd         string  `json:"cwd"`
+	Timestamp   string  `json:"timestamp"`
+	IsSidechain bool    `json:"isSidechain"`
+	IsMeta      bool    `json:"isMeta"`
+	Message     Message `json:"message"`
+
+	// System entries
+	Subtype         string           `json:"subtype,omitempty"`
+	CompactMetadata *CompactMetadata `json:"compactMetadata,omitempty"`
+}
+
+// CompactMetadata describes a context compaction at a compact_boundary entry
+type CompactMetadata struct {
+	// Trigger is "manual" (/compact) or "auto"
+	Trigger   string `json:"trigger"`
+	PreTokens int    `json:"preTokens"`
+}
+
+// Message is the API message recorded in a transcript entry
+type Message struct {
+	ID      string  `json:"id"`
+	Role    string  `json:"role"`
+	Model   string  `json:"model"`
+	Content Content `json:"content"`
+	Usage   *Usage  `json:"usage,omitempty"`
+}
+
+// Usage is the token usage the API reported for an assistant message
+type Usage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// ContextTokens returns the size of the prompt the message answered, which is
+// how much of the context window the conversation occupied at that point
+func (u *Usage) ContextTokens() int {
+	if u == nil {
+		return 0
+	}
+	return u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
+
+// Content holds message content, which Claude Code writes either as a plain string or as blocks
+type Content []Block
+
+// UnmarshalJSON accepts both a string and an array of content blocks
+func (c *Content) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = Content{{Type: BlockText, Text: text}}
+		return nil
+	}
+
+	var blocks []Block
+	if err := json.Unmarshal(data, &blocks); err != nil {
+		return err
+	}
+	*c = blocks
+	return nil
+}
+
+// Block is a single content block
+type Block struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+// ResultText returns the text of a tool_result block, whose content is a string or text blocks
+func (b Block) ResultText() string {
+	if len(b.Content) == 0 {
+		return ""
+	}
+
+	var text string
+	if err := json.Unmarshal(b.Content, &text); err == nil {
+		return text
+	}
+
+	var blocks []Block
+	if err := json.Unmarshal(b.Content, &blocks); err != nil {
+		return string(b.Content)
+	}
+
+	parts := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		if block.Type == BlockText {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// ReadTranscript reads complete entries from path starting at byte offset and
+// returns them with the offset just past the last complete line. A trailing
+// partial line (still being written) is left for the next read. If the file is
+// shorter than offset it was rewritten, and reading restarts from the beginning.
+// Lines that are not valid JSON are skipped.
+func ReadTranscript(path string, offset int64) ([]TranscriptEntry, int64, error) {
+	f, err := os.Open(path) //nolint:gosec // Path comes from Claude Code's hook payload
+	if err != nil {
+		return nil, offset, fmt.Errorf("failed to open transcript: %w", err)
+	}
+	defer f.Close() //nolint:errcheck // Read-only file
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, offset, fmt.Errorf("failed to stat transcript: %w", err)
+	}
+	if info.Size() < offset {
+		offset = 0
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, fmt.Errorf("failed to seek transcript: %w", err)
+	}
+
+	var entries []TranscriptEntry
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return entries, offset, fmt.Errorf("failed to read transcript: %w", err)
+		}
+		offset += int64(len(line))
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry TranscriptEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, offset, nil
+}
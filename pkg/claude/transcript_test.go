@@ -0,0 +1,125 @@
+package claude
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sampleTranscript = `{"type":"user","uuid":"u1","sessionId":"s1","timestamp":"2025-12-06T10:00:00Z","message":{"role":"user","content":"List the files"}}
+{"type":"assistant","uuid":"a1","sessionId":"s1","timestamp":"2025-12-06T10:00:01Z","message":{"id":"msg_1","role":"assistant","model":"claude-sonnet-4-5","content":[{"type":"text","text":"I'll list them."},{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"ls"}}]}}
+{"type":"user","uuid":"u2","sessionId":"s1","timestamp":"2025-12-06T10:00:02Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":[{"type":"text","text":"main.go"}]}]}}
+`
+
+func writeTranscript(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "transcript.jsonl")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadTranscript(t *testing.T) {
+	path := writeTranscript(t, sampleTranscript)
+
+	entries, offset, err := ReadTranscript(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to read transcript: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+	if offset != int64(len(sampleTranscript)) {
+		t.Errorf("Expected offset %d, got %d", len(sampleTranscript), offset)
+	}
+
+	if entries[0].Message.Content[0].Text != "List the files" {
+		t.Errorf("Expected string content to become a text block, got %+v", entries[0].Message.Content)
+	}
+
+	assistant := entries[1]
+	if assistant.Message.Model != "claude-sonnet-4-5" || len(assistant.Message.Content) != 2 {
+		t.Errorf("Unexpected assistant entry: %+v", assistant)
+	}
+	if tool := assistant.Message.Content[1]; tool.Type != BlockToolUse || tool.Name != "Bash" || string(tool.Input) != `{"command":"ls"}` {
+		t.Errorf("Unexpected tool_use block: %+v", tool)
+	}
+
+	result := entries[2].Message.Content[0]
+	if result.ToolUseID != "toolu_1" || result.ResultText() != "main.go" {
+		t.Errorf("Unexpected tool_result block: %+v", result)
+	}
+
+	entries, next, err := ReadTranscript(path, offset)
+	if err != nil || len(entries) != 0 || next != offset {
+		t.Errorf("Expected nothing new at end of file, got %d entries, offset %d (%v)", len(entries), next, err)
+	}
+}
+
+func TestReadTranscript_UsageAndCompaction(t *testing.T) {
+	path := writeTranscript(t, `{"type":"assistant","uuid":"a1","message":{"role":"assistant","content":[{"type":"text","text":"Done."}],"usage":{"input_tokens":12,"cache_creation_input_tokens":3000,"cache_read_input_tokens":150000,"output_tokens":40}}}
+{"type":"system","subtype":"compact_boundary","uuid":"c1","content":"Conversation compacted","compactMetadata":{"trigger":"auto","preTokens":153012}}
+`)
+
+	entries, _, err := ReadTranscript(path, 0)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d (%v)", len(entries), err)
+	}
+
+	if got := entries[0].Message.Usage.ContextTokens(); got != 153012 {
+		t.Errorf("Expected 153012 context tokens, got %d", got)
+	}
+
+	boundary := entries[1]
+	if boundary.Type != EntrySystem || boundary.Subtype != SubtypeCompactBoundary || boundary.CompactMetadata == nil {
+		t.Fatalf("Unexpected compact boundary entry: %+v", boundary)
+	}
+	if boundary.CompactMetadata.Trigger != "auto" || boundary.CompactMetadata.PreTokens != 153012 {
+		t.Errorf("Unexpected compact metadata: %+v", boundary.CompactMetadata)
+	}
+	if boundary.Message.Usage.ContextTokens() != 0 {
+		t.Error("Expected no usage on a system entry")
+	}
+}
+
+func TestReadTranscript_PartialLine(t *testing.T) {
+	lines := strings.SplitAfter(sampleTranscript, "\n")
+	partial := lines[0] + lines[1][:20]
+	path := writeTranscript(t, partial)
+
+	entries, offset, err := ReadTranscript(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to read transcript: %v", err)
+	}
+	if len(entries) != 1 || offset != int64(len(lines[0])) {
+		t.Errorf("Expected only the complete line, got %d entries, offset %d", len(entries), offset)
+	}
+}
+
+func TestReadTranscript_Rewritten(t *testing.T) {
+	path := writeTranscript(t, sampleTranscript)
+
+	entries, _, err := ReadTranscript(path, int64(len(sampleTranscript))*2)
+	if err != nil {
+		t.Fatalf("Failed to read transcript: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("Expected reading to restart from the beginning, got %d entries", len(entries))
+	}
+}
+
+func TestReadHookInput(t *testing.T) {
+	input, err := ReadHookInput(strings.NewReader(`{"session_id":"abc","transcript_path":"/tmp/t.jsonl","hook_event_name":"Stop","stop_hook_active":true}`))
+	if err != nil {
+		t.Fatalf("Failed to read hook input: %v", err)
+	}
+	if input.SessionID != "abc" || input.HookEventName != HookStop || !input.StopHookActive {
+		t.Errorf("Unexpected hook input: %+v", input)
+	}
+
+	if _, err := ReadHookInput(strings.NewReader(`{"session_id":"abc"}`)); err == nil {
+		t.Error("Expected error without hook_event_name")
+	}
+}
@@ -0,0 +1,126 @@
+package codex
+
+import (
+	"encoding/json"
+)
+
+// codex exec --json event types
+const (
+	ExecThreadStarted = "thread.started"
+	ExecTurnStarted   = "turn.started"
+	ExecTurnCompleted = "turn.completed"
+	ExecTurnFailed    = "turn.failed"
+	ExecItemStarted   = "item.started"
+	ExecItemUpdated   = "item.updated"
+	ExecItemCompleted = "item.completed"
+	ExecError         = "error"
+)
+
+// codex exec --json item types
+const (
+	ExecAgentMessage     = "agent_message"
+	ExecReasoning        = "reasoning"
+	ExecCommandExecution = "command_execution"
+	ExecFileChange       = "file_change"
+	ExecMcpToolCall      = "mcp_tool_call"
+	ExecWebSearch        = "web_search"
+	ExecTodoList         = "todo_list"
+	ExecErrorItem        = "error"
+)
+
+// Item statuses
+const (
+	StatusInProgress = "in_progress"
+	StatusCompleted  = "completed"
+	StatusFailed     = "failed"
+)
+
+// ExecEvent is one line of codex exec --json output
+type ExecEvent struct {
+	Type string `json:"type"`
+	// ThreadID is set on thread.started
+	ThreadID string `json:"thread_id,omitempty"`
+	// Item is set on item.* events
+	Item *ExecItem `json:"item,omitempty"`
+	// Usage is set on turn.completed
+	Usage *ExecUsage `json:"usage,omitempty"`
+	// Error is set on turn.failed
+	Error *ExecErrorDetail `json:"error,omitempty"`
+	// Message is set on error
+	Message string `json:"message,omitempty"`
+}
+
+// ExecItem is a thread item: a message, a command, a file change or a tool call
+type ExecItem struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// ItemType is the name of Type in early releases of the JSON output
+	ItemType string `json:"item_type,omitempty"`
+	Status   string `json:"status,omitempty"`
+
+	// agent_message and reasoning
+	Text string `json:"text,omitempty"`
+
+	// command_execution
+	Command          string `json:"command,omitempty"`
+	AggregatedOutput string `json:"aggregated_output,omitempty"`
+	ExitCode         *int   `json:"exit_code,omitempty"`
+
+	// file_change
+	Changes []FileChange `json:"changes,omitempty"`
+
+	// mcp_tool_call
+	Server string `json:"server,omitempty"`
+	Tool   string `json:"tool,omitempty"`
+
+	// web_search
+	Query string `json:"query,omitempty"`
+
+	// error
+	Message string `json:"message,omitempty"`
+}
+
+// FileChange is one file touched by a file_change item
+type FileChange struct {
+	Path string `json:"path"`
+	// Kind is "add", "delete" or "update"
+	Kind string `json:"kind"`
+}
+
+// ExecUsage is the token usage of a turn
+type ExecUsage struct {
+	InputTokens       int `json:"input_tokens"`
+	CachedInputTokens int `json:"cached_input_tokens"`
+	OutputTokens      int `json:"output_tokens"`
+}
+
+// ExecErrorDetail describes why a turn failed
+type ExecErrorDetail struct {
+	Message string `json:"message"`
+}
+
+// Kind returns the item type
+func (i *ExecItem) Kind() string {
+	if i.Type != "" {
+		return i.Type
+	}
+	return i.ItemType
+}
+
+// Succeeded reports whether a command, file change or tool call completed successfully
+func (i *ExecItem) Succeeded() bool {
+	if i.Status == StatusFailed {
+		return false
+	}
+	return i.ExitCode == nil || *i.ExitCode == 0
+}
+
+// ParseExecEvent decodes a line of codex exec --json output. Lines that are not
+// JSON events, such as plain text output, are reported as not ok.
+func ParseExecEvent(line []byte) (*ExecEvent, bool) {
+	var event ExecEvent
+	if err := json.Unmarshal(line, &event); err != nil || event.Type == "" {
+		return nil, false
+	}
+	return &event, true
+}
@@ -0,0 +1,51 @@
+package codex
+
+import "testing"
+
+func TestParseExecEvent(t *testing.T) {
+	event, ok := ParseExecEvent([]byte(`{"type":"item.completed","item":{"id":"item_1","type":"command_execution","command":"bash -lc ls","aggregated_output":"main.go\n","exit_code":0,"status":"completed"}}`))
+	if !ok || event.Type != ExecItemCompleted || event.Item == nil {
+		t.Fatalf("Unexpected event: %+v", event)
+	}
+	item := event.Item
+	if item.Kind() != ExecCommandExecution || item.Command != "bash -lc ls" || item.AggregatedOutput != "main.go\n" || !item.Succeeded() {
+		t.Errorf("Unexpected command item: %+v", item)
+	}
+
+	event, ok = ParseExecEvent([]byte(`{"type":"turn.completed","usage":{"input_tokens":1200,"cached_input_tokens":1000,"output_tokens":40}}`))
+	if !ok || event.Usage == nil || event.Usage.InputTokens != 1200 || event.Usage.CachedInputTokens != 1000 {
+		t.Errorf("Unexpected turn.completed: %+v", event)
+	}
+
+	event, ok = ParseExecEvent([]byte(`{"type":"item.completed","item":{"id":"item_0","item_type":"reasoning","text":"Thinking"}}`))
+	if !ok || event.Item.Kind() != ExecReasoning {
+		t.Errorf("Expected item_type to be accepted, got %+v", event)
+	}
+
+	for _, line := range []string{"", "plain text output", `{"no_type":true}`} {
+		if _, ok := ParseExecEvent([]byte(line)); ok {
+			t.Errorf("Expected %q not to parse as an event", line)
+		}
+	}
+}
+
+func TestExecItem_Succeeded(t *testing.T) {
+	failedExit := 1
+	tests := []struct {
+		name string
+		item ExecItem
+		want bool
+	}{
+		{"completed", ExecItem{Status: StatusCompleted}, true},
+		{"failed status", ExecItem{Status: StatusFailed}, false},
+		{"non-zero exit", ExecItem{Status: StatusCompleted, ExitCode: &failedExit}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.item.Succeeded(); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
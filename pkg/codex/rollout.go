@@ -0,0 +1,489 @@
+// Package codex provides parsing of Codex CLI session rollout files.
+package codex
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rollout line types
+const (
+	LineSessionMeta  = "session_meta"
+	LineResponseItem = "response_item"
+	LineEventMsg     = "event_msg"
+	LineTurnContext  = "turn_context"
+)
+
+// Response item types
+const (
+	ItemMessage              = "message"
+	ItemReasoning            = "reasoning"
+	ItemFunctionCall         = "function_call"
+	ItemFunctionCallOutput   = "function_call_output"
+	ItemCustomToolCall       = "custom_tool_call"
+	ItemCustomToolCallOutput = "custom_tool_call_output"
+	ItemLocalShellCall       = "local_shell_call"
+)
+
+// Event message types
+const (
+	EventUserMessage = "user_message"
+	EventTokenCount  = "token_count"
+)
+
+// ErrNoRollout is returned when no rollout file matches
+var ErrNoRollout = errors.New("no Codex rollout found")
+
+// Line is one line of a rollout file
+type Line struct {
+	Timestamp string
+	Type      string
+	// Item is the payload of response_item, event_msg and turn_context lines
+	Item Item
+	// Meta is the payload of session_meta lines
+	Meta *SessionMeta
+	// Legacy is set for lines of older rollouts, which only hold response items
+	Legacy bool
+}
+
+// SessionMeta describes the Codex session a rollout belongs to
+type SessionMeta struct {
+	ID         string `json:"id"`
+	Timestamp  string `json:"timestamp"`
+	Cwd        string `json:"cwd,omitempty"`
+	Originator string `json:"originator,omitempty"`
+	CLIVersion string `json:"cli_version,omitempty"`
+}
+
+// Item is a response item or event message. Fields are set according to Type.
+type Item struct {
+	Type string `json:"type"`
+
+	// message
+	Role    string        `json:"role,omitempty"`
+	Content []ContentPart `json:"content,omitempty"`
+
+	// reasoning
+	Summary []ContentPart `json:"summary,omitempty"`
+
+	// function_call, custom_tool_call and local_shell_call
+	Name      string       `json:"name,omitempty"`
+	Arguments string       `json:"arguments,omitempty"`
+	Input     string       `json:"input,omitempty"`
+	CallID    string       `json:"call_id,omitempty"`
+	Action    *ShellAction `json:"action,omitempty"`
+
+	// function_call_output and custom_tool_call_output
+	Output json.RawMessage `json:"output,omitempty"`
+
+	// user_message event
+	Message string `json:"message,omitempty"`
+
+	// token_count event
+	Info *TokenInfo `json:"info,omitempty"`
+
+	// turn_context
+	Cwd   string `json:"cwd,omitempty"`
+	Model string `json:"model,omitempty"`
+}
+
+// ContentPart is a piece of message content or reasoning summary
+type ContentPart struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+}
+
+// ShellAction is the command of a local_shell_call
+type ShellAction struct {
+	Type             string   `json:"type"`
+	Command          []string `json:"command"`
+	WorkingDirectory string   `json:"working_directory,omitempty"`
+}
+
+// TokenInfo is the token accounting of a token_count event
+type TokenInfo struct {
+	TotalTokenUsage    TokenUsage `json:"total_token_usage"`
+	LastTokenUsage     TokenUsage `json:"last_token_usage"`
+	ModelContextWindow int        `json:"model_context_window,omitempty"`
+}
+
+// TokenUsage counts the tokens of one model call or of the whole session
+type TokenUsage struct {
+	InputTokens           int `json:"input_tokens"`
+	CachedInputTokens     int `json:"cached_input_tokens"`
+	OutputTokens          int `json:"output_tokens"`
+	ReasoningOutputTokens int `json:"reasoning_output_tokens"`
+	TotalTokens           int `json:"total_tokens"`
+}
+
+// Text returns the text of a message
+func (i *Item) Text() string {
+	return joinText(i.Content)
+}
+
+// SummaryText returns the reasoning summary; reasoning without one is encrypted and has no text
+func (i *Item) SummaryText() string {
+	return joinText(i.Summary)
+}
+
+func joinText(parts []ContentPart) string {
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// IsToolCall reports whether the item invokes a tool
+func (i *Item) IsToolCall() bool {
+	return i.Type == ItemFunctionCall || i.Type == ItemCustomToolCall || i.Type == ItemLocalShellCall
+}
+
+// IsToolOutput reports whether the item is the result of a tool call
+func (i *Item) IsToolOutput() bool {
+	return i.Type == ItemFunctionCallOutput || i.Type == ItemCustomToolCallOutput
+}
+
+// ToolName returns the name of the invoked tool
+func (i *Item) ToolName() string {
+	if i.Type == ItemLocalShellCall {
+		return "local_shell"
+	}
+	return i.Name
+}
+
+// ToolInput returns the input of a tool call as logged: JSON arguments, freeform input or the shell action
+func (i *Item) ToolInput() string {
+	switch {
+	case i.Arguments != "":
+		return i.Arguments
+	case i.Input != "":
+		return i.Input
+	case i.Action != nil:
+		data, err := json.Marshal(i.Action)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+	return ""
+}
+
+// ShellCommand returns the command line of a shell tool call, with the script of
+// "bash -lc <script>" style invocations unwrapped
+func (i *Item) ShellCommand() (string, bool) {
+	if i.Action != nil {
+		return commandLine(i.Action.Command), len(i.Action.Command) > 0
+	}
+	if i.Type != ItemFunctionCall {
+		return "", false
+	}
+
+	var args struct {
+		Command json.RawMessage `json:"command"`
+	}
+	if err := json.Unmarshal([]byte(i.Arguments), &args); err != nil || len(args.Command) == 0 {
+		return "", false
+	}
+
+	var argv []string
+	if err := json.Unmarshal(args.Command, &argv); err == nil {
+		return commandLine(argv), len(argv) > 0
+	}
+	var script string
+	if err := json.Unmarshal(args.Command, &script); err == nil {
+		return script, script != ""
+	}
+	return "", false
+}
+
+// commandLine renders argv as a shell command line
+func commandLine(argv []string) string {
+	if len(argv) == 3 && (argv[1] == "-lc" || argv[1] == "-c") {
+		switch filepath.Base(argv[0]) {
+		case "bash", "sh", "zsh":
+			return argv[2]
+		}
+	}
+
+	quoted := make([]string, len(argv))
+	for n, arg := range argv {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`*?[]{}()<>|&;#~") {
+			quoted[n] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		} else {
+			quoted[n] = arg
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Patch returns the apply_patch envelope of a tool call that edits files: the
+// apply_patch tool, or a shell call running apply_patch
+func (i *Item) Patch() (string, bool) {
+	if i.Name == "apply_patch" {
+		if i.Input != "" {
+			return i.Input, true
+		}
+		var args struct {
+			Input string `json:"input"`
+		}
+		if err := json.Unmarshal([]byte(i.Arguments), &args); err == nil && args.Input != "" {
+			return args.Input, true
+		}
+	}
+
+	if i.Action != nil && len(i.Action.Command) == 2 && i.Action.Command[0] == "apply_patch" {
+		return i.Action.Command[1], true
+	}
+	if i.Type == ItemFunctionCall {
+		var args struct {
+			Command []string `json:"command"`
+		}
+		if err := json.Unmarshal([]byte(i.Arguments), &args); err == nil && len(args.Command) == 2 && args.Command[0] == "apply_patch" {
+			return args.Command[1], true
+		}
+	}
+
+	if command, ok := i.ShellCommand(); ok && strings.Contains(command, "apply_patch") && strings.Contains(command, "*** Begin Patch") {
+		return command, true
+	}
+	return "", false
+}
+
+// ToolOutput is the result of a tool call
+type ToolOutput struct {
+	Text string
+	// ExitCode and Duration are set for shell commands
+	ExitCode *int
+	Duration *time.Duration
+	// Success is nil when Codex reports neither success nor an exit code
+	Success *bool
+}
+
+// ToolOutput decodes the output of a tool call. Shell output comes either as JSON
+// with exit code and duration metadata, or as text headed by "Exit code:" and
+// "Wall time:" lines; other tools return plain text or {content, success}.
+func (i *Item) ToolOutput() ToolOutput {
+	var result ToolOutput
+
+	var text string
+	if err := json.Unmarshal(i.Output, &text); err != nil {
+		var payload struct {
+			Content string `json:"content"`
+			Success *bool  `json:"success"`
+		}
+		if err := json.Unmarshal(i.Output, &payload); err != nil {
+			return result
+		}
+		result.Text, result.Success = payload.Content, payload.Success
+		return result
+	}
+
+	var shell struct {
+		Output   *string `json:"output"`
+		Metadata struct {
+			ExitCode        *int     `json:"exit_code"`
+			DurationSeconds *float64 `json:"duration_seconds"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(text), &shell); err == nil && shell.Output != nil {
+		result.Text = *shell.Output
+		result.ExitCode = shell.Metadata.ExitCode
+		if seconds := shell.Metadata.DurationSeconds; seconds != nil {
+			d := time.Duration(*seconds * float64(time.Second))
+			result.Duration = &d
+		}
+	} else {
+		result = parseShellText(text)
+	}
+
+	if result.ExitCode != nil {
+		success := *result.ExitCode == 0
+		result.Success = &success
+	}
+	return result
+}
+
+// parseShellText splits the "Exit code: N\nWall time: S seconds\nOutput:\n..." form
+// of shell output; text without that header is returned as is
+func parseShellText(text string) ToolOutput {
+	result := ToolOutput{Text: text}
+
+	rest := text
+	for {
+		line, remainder, found := strings.Cut(rest, "\n")
+		switch {
+		case strings.HasPrefix(line, "Exit code: "):
+			if code, err := strconv.Atoi(strings.TrimPrefix(line, "Exit code: ")); err == nil {
+				result.ExitCode = &code
+			}
+		case strings.HasPrefix(line, "Wall time: "):
+			value := strings.TrimSuffix(strings.TrimPrefix(line, "Wall time: "), " seconds")
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+				d := time.Duration(seconds * float64(time.Second))
+				result.Duration = &d
+			}
+		case line == "Output:" && result.ExitCode != nil:
+			result.Text = remainder
+			return result
+		default:
+			if result.ExitCode != nil {
+				// Other header lines such as "Total output lines: N"
+				break
+			}
+			return ToolOutput{Text: text}
+		}
+		if !found {
+			return result
+		}
+		rest = remainder
+	}
+}
+
+// ReadRollout reads complete lines from path starting at byte offset and returns
+// them with the offset just past the last complete line. A trailing partial line
+// is left for the next read. If the file is shorter than offset it was rewritten,
+// and reading restarts from the beginning. Lines that are not valid JSON are skipped.
+func ReadRollout(path string, offset int64) ([]Line, int64, error) {
+	f, err := os.Open(path) //nolint:gosec // Path is located under the Codex sessions directory
+	if err != nil {
+		return nil, offset, fmt.Errorf("failed to open rollout: %w", err)
+	}
+	defer f.Close() //nolint:errcheck // Read-only file
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, offset, fmt.Errorf("failed to stat rollout: %w", err)
+	}
+	if info.Size() < offset {
+		offset = 0
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, fmt.Errorf("failed to seek rollout: %w", err)
+	}
+
+	var lines []Line
+	reader := bufio.NewReader(f)
+	for {
+		raw, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return lines, offset, fmt.Errorf("failed to read rollout: %w", err)
+		}
+		offset += int64(len(raw))
+
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 {
+			continue
+		}
+		if line, ok := parseLine(raw); ok {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, offset, nil
+}
+
+// parseLine decodes a rollout line. Current rollouts wrap every record as
+// {"timestamp", "type", "payload"}; older ones start with bare session metadata
+// followed by bare response items.
+func parseLine(raw []byte) (Line, bool) {
+	var envelope struct {
+		Timestamp string          `json:"timestamp"`
+		Type      string          `json:"type"`
+		Payload   json.RawMessage `json:"payload"`
+		ID        string          `json:"id"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return Line{}, false
+	}
+
+	line := Line{Timestamp: envelope.Timestamp, Type: envelope.Type}
+	switch {
+	case len(envelope.Payload) > 0 && envelope.Type == LineSessionMeta:
+		var meta SessionMeta
+		if err := json.Unmarshal(envelope.Payload, &meta); err != nil {
+			return Line{}, false
+		}
+		line.Meta = &meta
+	case len(envelope.Payload) > 0:
+		if err := json.Unmarshal(envelope.Payload, &line.Item); err != nil {
+			return Line{}, false
+		}
+	case envelope.Type == "" && envelope.ID != "":
+		var meta SessionMeta
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			return Line{}, false
+		}
+		line = Line{Timestamp: meta.Timestamp, Type: LineSessionMeta, Meta: &meta, Legacy: true}
+	case envelope.Type != "":
+		line = Line{Type: LineResponseItem, Legacy: true}
+		if err := json.Unmarshal(raw, &line.Item); err != nil {
+			return Line{}, false
+		}
+	default:
+		// State snapshots and other bookkeeping records
+		return Line{}, false
+	}
+	return line, true
+}
+
+// SessionsDir returns the directory where Codex stores rollouts: $CODEX_HOME/sessions,
+// by default ~/.codex/sessions
+func SessionsDir() (string, error) {
+	if home := os.Getenv("CODEX_HOME"); home != "" {
+		return filepath.Join(home, "sessions"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".codex", "sessions"), nil
+}
+
+// FindRollout returns the rollout of the Codex session with the given ID. Rollouts
+// are named rollout-<timestamp>-<session id>.jsonl. Without an ID there is no telling
+// which rollout belongs to the session, so none is returned.
+func FindRollout(dir, sessionID string) (string, error) {
+	if sessionID == "" {
+		return "", ErrNoRollout
+	}
+
+	var found string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		name := d.Name()
+		if !d.IsDir() && strings.HasPrefix(name, "rollout-") && strings.HasSuffix(name, "-"+sessionID+".jsonl") {
+			found = path
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if found == "" {
+		return "", ErrNoRollout
+	}
+	return found, nil
+}
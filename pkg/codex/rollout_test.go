@@ -0,0 +1,206 @@
+package codex
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const sampleRollout = `{"timestamp":"2025-12-06T10:00:00Z","type":"session_meta","payload":{"id":"0199a213-81c0-7800-8aa1-bbab2a035a53","timestamp":"2025-12-06T10:00:00Z","cwd":"/work","originator":"codex_cli_rs","cli_version":"0.46.0"}}
+{"timestamp":"2025-12-06T10:00:01Z","type":"turn_context","payload":{"cwd":"/work","approval_policy":"on-request","model":"gpt-5-codex"}}
+{"timestamp":"2025-12-06T10:00:01Z","type":"event_msg","payload":{"type":"user_message","message":"List the files","images":[]}}
+{"timestamp":"2025-12-06T10:00:02Z","type":"response_item","payload":{"type":"reasoning","summary":[{"type":"summary_text","text":"**Listing files**"}],"encrypted_content":"gAAA"}}
+{"timestamp":"2025-12-06T10:00:02Z","type":"response_item","payload":{"type":"function_call","name":"shell","arguments":"{\"command\":[\"bash\",\"-lc\",\"ls\"],\"workdir\":\"/work\"}","call_id":"call_1"}}
+{"timestamp":"2025-12-06T10:00:03Z","type":"response_item","payload":{"type":"function_call_output","call_id":"call_1","output":"{\"output\":\"main.go\\n\",\"metadata\":{\"exit_code\":0,\"duration_seconds\":0.25}}"}}
+{"timestamp":"2025-12-06T10:00:04Z","type":"event_msg","payload":{"type":"token_count","info":{"total_token_usage":{"input_tokens":2000,"cached_input_tokens":1500,"output_tokens":50,"reasoning_output_tokens":20,"total_tokens":2050},"last_token_usage":{"input_tokens":1000,"cached_input_tokens":800,"output_tokens":30,"reasoning_output_tokens":10,"total_tokens":1030},"model_context_window":272000}}}
+{"timestamp":"2025-12-06T10:00:05Z","type":"response_item","payload":{"type":"message","role":"assistant","content":[{"type":"output_text","text":"There is one file: main.go."}]}}
+`
+
+func writeRollout(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadRollout(t *testing.T) {
+	path := writeRollout(t, t.TempDir(), "rollout.jsonl", sampleRollout)
+
+	lines, offset, err := ReadRollout(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to read rollout: %v", err)
+	}
+	if len(lines) != 8 {
+		t.Fatalf("Expected 8 lines, got %d", len(lines))
+	}
+	if offset != int64(len(sampleRollout)) {
+		t.Errorf("Expected offset %d, got %d", len(sampleRollout), offset)
+	}
+
+	if meta := lines[0].Meta; meta == nil || meta.ID != "0199a213-81c0-7800-8aa1-bbab2a035a53" || meta.Cwd != "/work" {
+		t.Errorf("Unexpected session meta: %+v", lines[0])
+	}
+	if item := lines[1].Item; lines[1].Type != LineTurnContext || item.Model != "gpt-5-codex" {
+		t.Errorf("Unexpected turn context: %+v", lines[1])
+	}
+	if item := lines[2].Item; item.Type != EventUserMessage || item.Message != "List the files" {
+		t.Errorf("Unexpected user message: %+v", item)
+	}
+	if text := lines[3].Item.SummaryText(); text != "**Listing files**" {
+		t.Errorf("Expected reasoning summary, got %q", text)
+	}
+
+	call := lines[4].Item
+	if !call.IsToolCall() || call.ToolName() != "shell" || call.CallID != "call_1" {
+		t.Errorf("Unexpected function call: %+v", call)
+	}
+	if command, ok := call.ShellCommand(); !ok || command != "ls" {
+		t.Errorf("Expected unwrapped shell command, got %q", command)
+	}
+
+	output := lines[5].Item.ToolOutput()
+	if output.Text != "main.go\n" || output.ExitCode == nil || *output.ExitCode != 0 || output.Success == nil || !*output.Success {
+		t.Errorf("Unexpected tool output: %+v", output)
+	}
+	if output.Duration == nil || *output.Duration != 250*time.Millisecond {
+		t.Errorf("Expected 250ms duration, got %v", output.Duration)
+	}
+
+	if info := lines[6].Item.Info; info == nil || info.LastTokenUsage.InputTokens != 1000 || info.ModelContextWindow != 272000 {
+		t.Errorf("Unexpected token count: %+v", lines[6].Item)
+	}
+	if text := lines[7].Item.Text(); text != "There is one file: main.go." {
+		t.Errorf("Unexpected assistant message: %q", text)
+	}
+
+	lines, next, err := ReadRollout(path, offset)
+	if err != nil || len(lines) != 0 || next != offset {
+		t.Errorf("Expected nothing new at end of file, got %d lines, offset %d (%v)", len(lines), next, err)
+	}
+}
+
+func TestReadRollout_PartialLine(t *testing.T) {
+	complete := `{"timestamp":"t","type":"event_msg","payload":{"type":"user_message","message":"hi"}}` + "\n"
+	path := writeRollout(t, t.TempDir(), "rollout.jsonl", complete+`{"timestamp":"t","type":"resp`)
+
+	lines, offset, err := ReadRollout(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1 || offset != int64(len(complete)) {
+		t.Errorf("Expected the partial line to be left for later, got %d lines, offset %d", len(lines), offset)
+	}
+}
+
+func TestReadRollout_Legacy(t *testing.T) {
+	legacy := `{"id":"5973b6c0-94b8-487b-a530-2aeb6098ae0e","timestamp":"2025-08-01T10:00:00Z","instructions":null}
+{"record_type":"state"}
+{"type":"message","role":"user","content":[{"type":"input_text","text":"Fix the bug"}]}
+{"type":"message","role":"assistant","content":[{"type":"output_text","text":"Fixed."}]}
+`
+	path := writeRollout(t, t.TempDir(), "rollout.jsonl", legacy)
+
+	lines, _, err := ReadRollout(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("Expected session meta and two messages, got %+v", lines)
+	}
+	if lines[0].Type != LineSessionMeta || lines[0].Meta.ID != "5973b6c0-94b8-487b-a530-2aeb6098ae0e" || !lines[0].Legacy {
+		t.Errorf("Unexpected legacy session meta: %+v", lines[0])
+	}
+	if lines[1].Type != LineResponseItem || lines[1].Item.Role != "user" || lines[1].Item.Text() != "Fix the bug" {
+		t.Errorf("Unexpected legacy message: %+v", lines[1])
+	}
+}
+
+func TestItem_ToolOutput(t *testing.T) {
+	failed, succeeded := false, true
+	tests := []struct {
+		name     string
+		output   string
+		text     string
+		exitCode int
+		success  *bool
+	}{
+		{"plain text", `"done"`, "done", -1, nil},
+		{"shell text", `"Exit code: 2\nWall time: 1.5 seconds\nOutput:\nno such file"`, "no such file", 2, &failed},
+		{"content object", `{"content":"patched","success":true}`, "patched", -1, &succeeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := Item{Type: ItemFunctionCallOutput, Output: []byte(tt.output)}
+			result := item.ToolOutput()
+			if result.Text != tt.text {
+				t.Errorf("Expected text %q, got %q", tt.text, result.Text)
+			}
+			if tt.exitCode >= 0 && (result.ExitCode == nil || *result.ExitCode != tt.exitCode) {
+				t.Errorf("Expected exit code %d, got %v", tt.exitCode, result.ExitCode)
+			}
+			if (tt.success == nil) != (result.Success == nil) || (tt.success != nil && *tt.success != *result.Success) {
+				t.Errorf("Expected success %v, got %v", tt.success, result.Success)
+			}
+		})
+	}
+}
+
+func TestItem_Patch(t *testing.T) {
+	patch := "*** Begin Patch\n*** Add File: hello.txt\n+hi\n*** End Patch"
+	tests := []struct {
+		name string
+		item Item
+	}{
+		{"freeform tool", Item{Type: ItemCustomToolCall, Name: "apply_patch", Input: patch}},
+		{"shell argv", Item{Type: ItemFunctionCall, Name: "shell", Arguments: `{"command":["apply_patch","*** Begin Patch\n*** Add File: hello.txt\n+hi\n*** End Patch"]}`}},
+		{"shell heredoc", Item{Type: ItemFunctionCall, Name: "shell", Arguments: `{"command":["bash","-lc","apply_patch <<'EOF'\n*** Begin Patch\n*** Add File: hello.txt\n+hi\n*** End Patch\nEOF"]}`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.item.Patch()
+			if !ok {
+				t.Fatal("Expected a patch")
+			}
+			if got != patch && got != "apply_patch <<'EOF'\n"+patch+"\nEOF" {
+				t.Errorf("Unexpected patch %q", got)
+			}
+		})
+	}
+
+	shell := Item{Type: ItemFunctionCall, Name: "shell", Arguments: `{"command":["ls"]}`}
+	if _, ok := shell.Patch(); ok {
+		t.Error("Expected no patch for a plain shell command")
+	}
+}
+
+func TestFindRollout(t *testing.T) {
+	dir := t.TempDir()
+	day := filepath.Join(dir, "2025", "12", "06")
+	older := writeRollout(t, day, "rollout-2025-12-06T10-00-00-aaaa.jsonl", "")
+	newer := writeRollout(t, day, "rollout-2025-12-06T11-00-00-bbbb.jsonl", "")
+
+	if path, err := FindRollout(dir, "aaaa"); err != nil || path != older {
+		t.Errorf("Expected %s, got %s (%v)", older, path, err)
+	}
+	if path, err := FindRollout(dir, "bbbb"); err != nil || path != newer {
+		t.Errorf("Expected %s, got %s (%v)", newer, path, err)
+	}
+	// Without a session ID the most recent rollout may belong to another session
+	if _, err := FindRollout(dir, ""); err != ErrNoRollout {
+		t.Errorf("Expected ErrNoRollout without a session ID, got %v", err)
+	}
+	if _, err := FindRollout(dir, "cccc"); err != ErrNoRollout {
+		t.Errorf("Expected ErrNoRollout, got %v", err)
+	}
+	if _, err := FindRollout(filepath.Join(dir, "missing"), "aaaa"); err != ErrNoRollout {
+		t.Errorf("Expected ErrNoRollout for a missing directory, got %v", err)
+	}
+}
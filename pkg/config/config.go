@@ -0,0 +1,333 @@
+// Package config provides loading of the optional tapline configuration file.
+// The file is read from ~/.tapline/config.json, or from the path given in the
+// TAPLINE_CONFIG environment variable. A missing file yields the defaults.
+// Selected settings can be overridden with TAPLINE_* environment variables.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	configDirName  = ".tapline"
+	configFileName = "config.json"
+)
+
+// Encryption scopes
+const (
+	// EncryptionScopeContent encrypts only the content field
+	EncryptionScopeContent = "content"
+	// EncryptionScopeRecord encrypts each log record as a whole
+	EncryptionScopeRecord = "record"
+)
+
+// Oversize content modes
+const (
+	// OversizeTruncate cuts content at the limit and records its original length and hash
+	OversizeTruncate = "truncate"
+	// OversizeChunk splits content into linked chunk records
+	OversizeChunk = "chunk"
+)
+
+// Privacy modes
+const (
+	// PrivacyModeFull logs conversation text verbatim (after redaction)
+	PrivacyModeFull = "full"
+	// PrivacyModeMetadata replaces conversation text with a content digest
+	PrivacyModeMetadata = "metadata"
+)
+
+// Response formats of wrapped tools
+const (
+	// ResponseFormatText logs the output as printed, without escape sequences
+	ResponseFormatText = "text"
+	// ResponseFormatJSON reads the response from a field of a JSON document
+	ResponseFormatJSON = "json"
+	// ResponseFormatJSONL reads the response from a field of each JSON line
+	ResponseFormatJSONL = "jsonl"
+)
+
+// Config holds all user-configurable tapline settings
+type Config struct {
+	Redaction  RedactionConfig  `json:"redaction"`
+	Privacy    PrivacyConfig    `json:"privacy"`
+	Encryption EncryptionConfig `json:"encryption"`
+	Audit      AuditConfig      `json:"audit"`
+	Limits     LimitsConfig     `json:"limits"`
+	Commands   CommandsConfig   `json:"commands"`
+	Codex      CodexConfig      `json:"codex"`
+	Wrap       WrapConfig       `json:"wrap"`
+	Output     OutputConfig     `json:"output"`
+}
+
+// RedactionConfig controls secret and PII redaction of logged content
+type RedactionConfig struct {
+	// Enabled turns redaction on or off. Redaction is on unless explicitly disabled.
+	Enabled *bool `json:"enabled,omitempty"`
+	// DisableBuiltin lists built-in detector names to skip (e.g. "email").
+	DisableBuiltin []string `json:"disable_builtin,omitempty"`
+	// Rules are additional user-defined regular expressions.
+	Rules []RedactionRule `json:"rules,omitempty"`
+}
+
+// RedactionRule is a user-defined redaction pattern
+type RedactionRule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// PrivacyConfig controls how much conversation text leaves the machine
+type PrivacyConfig struct {
+	// Mode is "full" (default) or "metadata"
+	Mode string `json:"mode,omitempty"`
+}
+
+// MetadataOnly reports whether conversation text must be replaced by a digest
+func (p PrivacyConfig) MetadataOnly() bool {
+	return p.Mode == PrivacyModeMetadata
+}
+
+// EncryptionConfig controls encryption of logged content at rest
+type EncryptionConfig struct {
+	// Recipient is the public key (tapline-pk1-...) records are encrypted to; empty disables encryption
+	Recipient string `json:"recipient,omitempty"`
+	// Scope is "content" (default) or "record"
+	Scope string `json:"scope,omitempty"`
+}
+
+// Enabled reports whether encryption is configured
+func (e EncryptionConfig) Enabled() bool {
+	return e.Recipient != ""
+}
+
+// WholeRecord reports whether entire records, not just content, are encrypted
+func (e EncryptionConfig) WholeRecord() bool {
+	return e.Scope == EncryptionScopeRecord
+}
+
+// AuditConfig controls the tamper-evident hash chain
+type AuditConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Chain names the chain records are linked into; use one chain per log file. Defaults to "default".
+	Chain string `json:"chain,omitempty"`
+	// SigningKey is the path of an Ed25519 signing key file; empty disables signatures
+	SigningKey string `json:"signing_key,omitempty"`
+}
+
+// ChainName returns the configured chain name or the default
+func (a AuditConfig) ChainName() string {
+	if a.Chain == "" {
+		return "default"
+	}
+	return a.Chain
+}
+
+// LimitsConfig controls the size of logged content
+type LimitsConfig struct {
+	// MaxContentBytes is the largest content written in one record; 0 means unlimited
+	MaxContentBytes int `json:"max_content_bytes,omitempty"`
+	// Oversize is "truncate" (default) or "chunk"
+	Oversize string `json:"oversize,omitempty"`
+}
+
+// Chunked reports whether oversized content is split rather than truncated
+func (l LimitsConfig) Chunked() bool {
+	return l.Oversize == OversizeChunk
+}
+
+// CommandsConfig controls risk flagging of agent shell commands
+type CommandsConfig struct {
+	// DisableBuiltin lists built-in risk pattern names to skip (e.g. "privilege_escalation").
+	DisableBuiltin []string `json:"disable_builtin,omitempty"`
+	// RiskPatterns are additional user-defined regular expressions that flag a command as risky.
+	RiskPatterns []RiskPattern `json:"risk_patterns,omitempty"`
+}
+
+// RiskPattern is a user-defined command risk pattern
+type RiskPattern struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// CodexConfig controls the Codex CLI integration
+type CodexConfig struct {
+	// Notify lists programs that receive each Codex notification after tapline has
+	// logged it, as they would from Codex's own notify setting. Each is an argv list.
+	Notify [][]string `json:"notify,omitempty"`
+}
+
+// WrapConfig controls the generic wrapper, tapline wrap
+type WrapConfig struct {
+	// Profiles describe tools by service name. A profile replaces the built-in one of the same name.
+	Profiles map[string]WrapProfile `json:"profiles,omitempty"`
+}
+
+// WrapProfile describes where a wrapped tool takes its prompt and prints its response
+type WrapProfile struct {
+	// PromptFlags are the options whose value is the prompt (e.g. "--message")
+	PromptFlags []string `json:"prompt_flags,omitempty"`
+	// ModelFlags are the options whose value names the model
+	ModelFlags []string `json:"model_flags,omitempty"`
+	// ValueFlags are the other options that take a value, so it is not taken for the prompt
+	ValueFlags []string `json:"value_flags,omitempty"`
+	// SecretFlags are options whose value is a credential; only their names are logged
+	SecretFlags []string `json:"secret_flags,omitempty"`
+	// SkipArgs is the number of leading positional arguments that are not part of
+	// the prompt, such as a subcommand and a model name
+	SkipArgs int `json:"skip_args,omitempty"`
+	// ModelArg is the position, counting from 1, of a positional argument naming the model
+	ModelArg int `json:"model_arg,omitempty"`
+	// FileArgs means the positional arguments are files, not the prompt
+	FileArgs bool `json:"file_args,omitempty"`
+	// PipedPrompt means input piped to the tool is part of the prompt
+	PipedPrompt bool `json:"piped_prompt,omitempty"`
+	// ResponseFormat is "text" (default), "json" or "jsonl"
+	ResponseFormat string `json:"response_format,omitempty"`
+	// ResponseField is the dotted path of the response in JSON output (e.g. "choices.0.text")
+	ResponseField string `json:"response_field,omitempty"`
+	// IgnoreLines are regular expressions matching output lines that are not part of the response
+	IgnoreLines []string `json:"ignore_lines,omitempty"`
+}
+
+// OutputConfig controls where log records are written
+type OutputConfig struct {
+	// File is the path records are appended to. Empty writes them to stdout, or to
+	// stderr for wrappers whose program owns stdout.
+	File string `json:"file,omitempty"`
+}
+
+// IsEnabled reports whether redaction should be applied
+func (r RedactionConfig) IsEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+// Path returns the location of the configuration file
+func Path() (string, error) {
+	if path := os.Getenv("TAPLINE_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+
+	return filepath.Join(homeDir, configDirName, configFileName), nil
+}
+
+// Load reads the configuration file and applies environment overrides.
+// A missing file is not an error. On error the returned Config is still usable.
+func Load() (*Config, error) {
+	cfg := &Config{}
+
+	err := cfg.readFile()
+	applyEnv(cfg)
+	if validateErr := cfg.validate(); validateErr != nil && err == nil {
+		err = validateErr
+	}
+
+	return cfg, err
+}
+
+func (c *Config) readFile() error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path) //nolint:gosec // Path is user-controlled by design
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, c); err != nil {
+		*c = Config{}
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// applyEnv overrides file settings with TAPLINE_* environment variables
+func applyEnv(cfg *Config) {
+	if mode := os.Getenv("TAPLINE_PRIVACY_MODE"); mode != "" {
+		cfg.Privacy.Mode = mode
+	}
+	if recipient := os.Getenv("TAPLINE_ENCRYPTION_RECIPIENT"); recipient != "" {
+		cfg.Encryption.Recipient = recipient
+	}
+	if maxBytes := os.Getenv("TAPLINE_MAX_CONTENT_BYTES"); maxBytes != "" {
+		if n, err := strconv.Atoi(maxBytes); err == nil {
+			cfg.Limits.MaxContentBytes = n
+		}
+	}
+	if chain := os.Getenv("TAPLINE_AUDIT_CHAIN"); chain != "" {
+		cfg.Audit.Enabled = true
+		cfg.Audit.Chain = chain
+	}
+	if file := os.Getenv("TAPLINE_LOG_FILE"); file != "" {
+		cfg.Output.File = file
+	}
+}
+
+func (c *Config) validate() error {
+	switch c.Privacy.Mode {
+	case "", PrivacyModeFull, PrivacyModeMetadata:
+	default:
+		mode := c.Privacy.Mode
+		// Fail closed: an unknown mode must never leak conversation text
+		c.Privacy.Mode = PrivacyModeMetadata
+		return fmt.Errorf("unknown privacy mode %q, falling back to %q", mode, PrivacyModeMetadata)
+	}
+
+	switch c.Encryption.Scope {
+	case "", EncryptionScopeContent, EncryptionScopeRecord:
+	default:
+		scope := c.Encryption.Scope
+		c.Encryption.Scope = EncryptionScopeRecord
+		return fmt.Errorf("unknown encryption scope %q, falling back to %q", scope, EncryptionScopeRecord)
+	}
+
+	switch c.Limits.Oversize {
+	case "", OversizeTruncate, OversizeChunk:
+	default:
+		oversize := c.Limits.Oversize
+		c.Limits.Oversize = OversizeTruncate
+		return fmt.Errorf("unknown oversize mode %q, falling back to %q", oversize, OversizeTruncate)
+	}
+
+	if strings.ContainsAny(c.Audit.Chain, `/\`) || strings.Contains(c.Audit.Chain, "..") {
+		chain := c.Audit.Chain
+		c.Audit.Chain = ""
+		return fmt.Errorf("invalid audit chain name %q, using the default chain", chain)
+	}
+
+	for i, command := range c.Codex.Notify {
+		if len(command) == 0 || command[0] == "" {
+			c.Codex.Notify = slices.DeleteFunc(c.Codex.Notify, func(argv []string) bool { return len(argv) == 0 || argv[0] == "" })
+			return fmt.Errorf("empty command at codex.notify[%d], skipping empty commands", i)
+		}
+	}
+
+	for name, profile := range c.Wrap.Profiles {
+		switch profile.ResponseFormat {
+		case "", ResponseFormatText, ResponseFormatJSON, ResponseFormatJSONL:
+		default:
+			format := profile.ResponseFormat
+			profile.ResponseFormat = ResponseFormatText
+			c.Wrap.Profiles[name] = profile
+			return fmt.Errorf("unknown response format %q in wrap profile %q, falling back to %q", format, name, ResponseFormatText)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad_MissingFile(t *testing.T) {
+	t.Setenv("TAPLINE_CONFIG", filepath.Join(t.TempDir(), "missing.json"))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error for missing file, got %v", err)
+	}
+	if !cfg.Redaction.IsEnabled() {
+		t.Error("Expected redaction to be enabled by default")
+	}
+}
+
+func TestLoad_FromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"redaction":{"enabled":false,"rules":[{"name":"ticket","pattern":"TICKET-\\d+"}]}}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TAPLINE_CONFIG", path)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Redaction.IsEnabled() {
+		t.Error("Expected redaction to be disabled")
+	}
+	if len(cfg.Redaction.Rules) != 1 || cfg.Redaction.Rules[0].Name != "ticket" {
+		t.Errorf("Unexpected rules: %+v", cfg.Redaction.Rules)
+	}
+}
+
+func TestLoad_InvalidJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte("{invalid"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TAPLINE_CONFIG", path)
+
+	cfg, err := Load()
+	if err == nil {
+		t.Error("Expected error for invalid JSON")
+	}
+	if cfg == nil {
+		t.Fatal("Expected default config on error")
+	}
+}
+
+func TestPath_Default(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TAPLINE_CONFIG", "")
+
+	path, err := Path()
+	if err != nil {
+		t.Fatalf("Failed to get path: %v", err)
+	}
+	if path != filepath.Join(home, ".tapline", "config.json") {
+		t.Errorf("Unexpected config path: %s", path)
+	}
+}
+
+func TestLoad_PrivacyModeFromEnv(t *testing.T) {
+	t.Setenv("TAPLINE_CONFIG", filepath.Join(t.TempDir(), "missing.json"))
+	t.Setenv("TAPLINE_PRIVACY_MODE", "metadata")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if !cfg.Privacy.MetadataOnly() {
+		t.Error("Expected metadata-only privacy mode from environment")
+	}
+}
+
+func TestLoad_UnknownPrivacyModeFailsClosed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"privacy":{"mode":"strict"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TAPLINE_CONFIG", path)
+	t.Setenv("TAPLINE_PRIVACY_MODE", "")
+
+	cfg, err := Load()
+	if err == nil {
+		t.Error("Expected error for unknown privacy mode")
+	}
+	if !cfg.Privacy.MetadataOnly() {
+		t.Error("Expected unknown privacy mode to fall back to metadata-only")
+	}
+}
+
+func TestLoad_Encryption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"encryption":{"recipient":"tapline-pk1-abc","scope":"record"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TAPLINE_CONFIG", path)
+	t.Setenv("TAPLINE_ENCRYPTION_RECIPIENT", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if !cfg.Encryption.Enabled() || !cfg.Encryption.WholeRecord() {
+		t.Errorf("Expected whole-record encryption, got %+v", cfg.Encryption)
+	}
+
+	t.Setenv("TAPLINE_ENCRYPTION_RECIPIENT", "tapline-pk1-env")
+	cfg, _ = Load()
+	if cfg.Encryption.Recipient != "tapline-pk1-env" {
+		t.Errorf("Expected recipient from environment, got %s", cfg.Encryption.Recipient)
+	}
+}
+
+func TestLoad_AuditChain(t *testing.T) {
+	t.Setenv("TAPLINE_CONFIG", filepath.Join(t.TempDir(), "missing.json"))
+	t.Setenv("TAPLINE_AUDIT_CHAIN", "")
+
+	cfg, _ := Load()
+	if cfg.Audit.Enabled || cfg.Audit.ChainName() != "default" {
+		t.Errorf("Expected audit disabled with default chain, got %+v", cfg.Audit)
+	}
+
+	t.Setenv("TAPLINE_AUDIT_CHAIN", "gemini")
+	cfg, _ = Load()
+	if !cfg.Audit.Enabled || cfg.Audit.ChainName() != "gemini" {
+		t.Errorf("Expected audit chain from environment, got %+v", cfg.Audit)
+	}
+
+	t.Setenv("TAPLINE_AUDIT_CHAIN", "../escape")
+	cfg, err := Load()
+	if err == nil || cfg.Audit.ChainName() != "default" {
+		t.Errorf("Expected invalid chain name to be rejected, got %+v (%v)", cfg.Audit, err)
+	}
+}
+
+func TestLoad_Limits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"limits":{"max_content_bytes":65536,"oversize":"chunk"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TAPLINE_CONFIG", path)
+	t.Setenv("TAPLINE_MAX_CONTENT_BYTES", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Limits.MaxContentBytes != 65536 || !cfg.Limits.Chunked() {
+		t.Errorf("Unexpected limits: %+v", cfg.Limits)
+	}
+
+	t.Setenv("TAPLINE_MAX_CONTENT_BYTES", "1024")
+	cfg, _ = Load()
+	if cfg.Limits.MaxContentBytes != 1024 {
+		t.Errorf("Expected limit from environment, got %d", cfg.Limits.MaxContentBytes)
+	}
+}
+
+func TestLoad_OutputFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"output":{"file":"/var/log/tapline.log"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TAPLINE_CONFIG", path)
+	t.Setenv("TAPLINE_LOG_FILE", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Output.File != "/var/log/tapline.log" {
+		t.Errorf("Expected log file from config, got %q", cfg.Output.File)
+	}
+
+	t.Setenv("TAPLINE_LOG_FILE", "/tmp/tapline.log")
+	cfg, _ = Load()
+	if cfg.Output.File != "/tmp/tapline.log" {
+		t.Errorf("Expected log file from environment, got %q", cfg.Output.File)
+	}
+}
+
+func TestLoad_CodexNotify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"codex":{"notify":[["notify-send","Codex"],[],["terminal-notifier"]]}}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TAPLINE_CONFIG", path)
+
+	cfg, err := Load()
+	if err == nil {
+		t.Error("Expected the empty command to be reported")
+	}
+	if len(cfg.Codex.Notify) != 2 || cfg.Codex.Notify[0][1] != "Codex" || cfg.Codex.Notify[1][0] != "terminal-notifier" {
+		t.Errorf("Expected the two non-empty commands, got %v", cfg.Codex.Notify)
+	}
+}
+
+func TestLoad_WrapProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"wrap":{"profiles":{"mytool":{"prompt_flags":["--ask"],"response_format":"json","response_field":"answer"},"other":{"response_format":"yaml"}}}}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TAPLINE_CONFIG", path)
+
+	cfg, err := Load()
+	if err == nil {
+		t.Error("Expected the unknown response format to be reported")
+	}
+	profile := cfg.Wrap.Profiles["mytool"]
+	if profile.PromptFlags[0] != "--ask" || profile.ResponseFormat != ResponseFormatJSON || profile.ResponseField != "answer" {
+		t.Errorf("Unexpected profile: %+v", profile)
+	}
+	if format := cfg.Wrap.Profiles["other"].ResponseFormat; format != ResponseFormatText {
+		t.Errorf("Expected the unknown format to fall back to text, got %q", format)
+	}
+}
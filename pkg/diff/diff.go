@@ -0,0 +1,283 @@
+// Package diff renders file changes made by agents as unified diffs.
+// It diffs file contents line by line (Myers' algorithm) and converts
+// Codex apply_patch envelopes into the same form.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// DevNull labels the missing side of a created or deleted file
+	DevNull = "/dev/null"
+
+	contextLines = 3
+	// maxDiffLines bounds the line-level diff; larger inputs are shown as a full replacement
+	maxDiffLines = 100000
+	// maxEditDistance bounds the edits Myers' algorithm searches for. Its memory grows
+	// with the square of the distance, so larger rewrites are shown as a full replacement.
+	maxEditDistance = 2000
+	noNewline       = "\\ No newline at end of file\n"
+)
+
+// FileDiff is the change to one file
+type FileDiff struct {
+	Path string
+	// Text is the unified diff; empty when nothing changed
+	Text    string
+	Added   int
+	Removed int
+}
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	line string
+}
+
+// Unified returns the unified diff between two versions of path.
+// oldName and newName label the diff headers, e.g. "a/main.go" or DevNull.
+func Unified(path, oldName, newName, oldText, newText string) FileDiff {
+	result := FileDiff{Path: path}
+	if oldText == newText {
+		return result
+	}
+
+	ops := diffLines(splitLines(oldText), splitLines(newText))
+
+	var b strings.Builder
+	b.WriteString("--- " + oldName + "\n")
+	b.WriteString("+++ " + newName + "\n")
+
+	for _, h := range hunks(ops) {
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(h.oldStart, h.oldLines), hunkRange(h.newStart, h.newLines))
+		for _, o := range h.ops {
+			switch o.kind {
+			case opEqual:
+				writeLine(&b, ' ', o.line)
+			case opDelete:
+				writeLine(&b, '-', o.line)
+				result.Removed++
+			case opInsert:
+				writeLine(&b, '+', o.line)
+				result.Added++
+			}
+		}
+	}
+
+	result.Text = b.String()
+	return result
+}
+
+// splitLines splits text into lines that keep their terminating newline,
+// so a missing newline at end of file shows up as a change
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func writeLine(b *strings.Builder, prefix byte, line string) {
+	b.WriteByte(prefix)
+	b.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		b.WriteString("\n" + noNewline)
+	}
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// diffLines returns the shortest edit script turning a into b
+func diffLines(a, b []string) []op {
+	if len(a)+len(b) <= maxDiffLines {
+		if ops, ok := myers(a, b, maxEditDistance); ok {
+			return ops
+		}
+	}
+	return replacement(a, b)
+}
+
+// replacement returns the edit script deleting all of a and inserting all of b
+func replacement(a, b []string) []op {
+	ops := make([]op, 0, len(a)+len(b))
+	for _, line := range a {
+		ops = append(ops, op{opDelete, line})
+	}
+	for _, line := range b {
+		ops = append(ops, op{opInsert, line})
+	}
+	return ops
+}
+
+// myers implements Myers' O(ND) diff, giving up once the edit distance exceeds
+// maxD. trace[d] holds the furthest x reached on diagonals -d-1..d+1 before
+// round d, which is all backtracking needs.
+func myers(a, b []string, maxD int) ([]op, bool) {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxD)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(trace, a, b), true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+func backtrack(trace [][]int, a, b []string) []op {
+	var ops []op
+	x, y := len(a), len(b)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, op{opEqual, a[x-1]})
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, op{opInsert, b[y-1]})
+				y--
+			} else {
+				ops = append(ops, op{opDelete, a[x-1]})
+				x--
+			}
+		}
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+type hunk struct {
+	oldStart, oldLines int
+	newStart, newLines int
+	ops                []op
+}
+
+// hunks groups an edit script into hunks with surrounding context.
+// Changes separated by at most twice the context share a hunk.
+func hunks(ops []op) []hunk {
+	var result []hunk
+
+	i := 0
+	oldLine, newLine := 1, 1
+	for i < len(ops) {
+		if ops[i].kind == opEqual {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+
+		// Back up to include leading context
+		start := i
+		for start > 0 && i-start < contextLines && ops[start-1].kind == opEqual {
+			start--
+		}
+		h := hunk{oldStart: oldLine - (i - start), newStart: newLine - (i - start)}
+
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == opEqual {
+				run++
+			}
+			if run == len(ops) || run-end > 2*contextLines {
+				end = min(end+contextLines, run)
+				break
+			}
+			end = run
+		}
+
+		h.ops = ops[start:end]
+		for _, o := range h.ops {
+			if o.kind != opInsert {
+				h.oldLines++
+			}
+			if o.kind != opDelete {
+				h.newLines++
+			}
+		}
+
+		// Line numbers of an empty side refer to the line before it
+		if h.oldLines == 0 {
+			h.oldStart--
+		}
+		if h.newLines == 0 {
+			h.newStart--
+		}
+
+		for _, o := range ops[i:end] {
+			if o.kind != opInsert {
+				oldLine++
+			}
+			if o.kind != opDelete {
+				newLine++
+			}
+		}
+		result = append(result, h)
+		i = end
+	}
+
+	return result
+}
@@ -0,0 +1,167 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnified_Modify(t *testing.T) {
+	oldText := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	newText := "a\nb\nc\nd\nE\nf\ng\nh\ni\nj\n"
+
+	got := Unified("x.txt", "a/x.txt", "b/x.txt", oldText, newText)
+
+	want := `--- a/x.txt
++++ b/x.txt
+@@ -2,7 +2,7 @@
+ b
+ c
+ d
+-e
++E
+ f
+ g
+ h
+`
+	if got.Text != want {
+		t.Errorf("Unexpected diff:\n%s\nwant:\n%s", got.Text, want)
+	}
+	if got.Added != 1 || got.Removed != 1 || got.Path != "x.txt" {
+		t.Errorf("Unexpected stats: %+v", got)
+	}
+}
+
+func TestUnified_SeparateHunks(t *testing.T) {
+	var oldLines, newLines []string
+	for i := 1; i <= 20; i++ {
+		line := fmt.Sprintf("line %d", i)
+		oldLines = append(oldLines, line)
+		switch i {
+		case 2:
+			newLines = append(newLines, "changed 2")
+		case 18:
+			// deleted
+		default:
+			newLines = append(newLines, line)
+		}
+	}
+	newLines = append(newLines, "line 21")
+
+	got := Unified("f", "a/f", "b/f", strings.Join(oldLines, "\n")+"\n", strings.Join(newLines, "\n")+"\n")
+
+	headers := []string{}
+	for _, line := range strings.Split(got.Text, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			headers = append(headers, line)
+		}
+	}
+	want := []string{"@@ -1,5 +1,5 @@", "@@ -15,6 +15,6 @@"}
+	if strings.Join(headers, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected hunks %v, got %v\n%s", want, headers, got.Text)
+	}
+	if got.Added != 2 || got.Removed != 2 {
+		t.Errorf("Expected 2 added and 2 removed, got %+v", got)
+	}
+}
+
+func TestUnified_NewFile(t *testing.T) {
+	got := Unified("new.go", DevNull, "b/new.go", "", "package main\n")
+
+	want := "--- /dev/null\n+++ b/new.go\n@@ -0,0 +1 @@\n+package main\n"
+	if got.Text != want {
+		t.Errorf("Unexpected diff:\n%q\nwant:\n%q", got.Text, want)
+	}
+}
+
+func TestUnified_NoNewlineAtEOF(t *testing.T) {
+	got := Unified("f", "a/f", "b/f", "x\ny", "x\ny\n")
+
+	if !strings.Contains(got.Text, "-y\n\\ No newline at end of file\n+y\n") {
+		t.Errorf("Expected no-newline marker, got:\n%s", got.Text)
+	}
+}
+
+func TestUnified_Unchanged(t *testing.T) {
+	if got := Unified("f", "a/f", "b/f", "same\n", "same\n"); got.Text != "" || got.Added != 0 {
+		t.Errorf("Expected empty diff, got %+v", got)
+	}
+}
+
+func TestUnified_LargeRewrite(t *testing.T) {
+	var oldText, newText strings.Builder
+	for i := range 10000 {
+		fmt.Fprintf(&oldText, "old line %d\n", i)
+		fmt.Fprintf(&newText, "new line %d\n", i)
+	}
+
+	got := Unified("f", "a/f", "b/f", oldText.String(), newText.String())
+	if got.Removed != 10000 || got.Added != 10000 {
+		t.Errorf("Expected a full replacement, got -%d +%d", got.Removed, got.Added)
+	}
+	if !strings.HasPrefix(got.Text, "--- a/f\n+++ b/f\n@@ -1,10000 +1,10000 @@\n-old line 0\n") {
+		t.Errorf("Unexpected diff start:\n%.200s", got.Text)
+	}
+
+	// A small edit to a large file is still diffed line by line
+	edited := strings.Replace(oldText.String(), "old line 5000\n", "changed\n", 1)
+	if got := Unified("f", "a/f", "b/f", oldText.String(), edited); got.Removed != 1 || got.Added != 1 {
+		t.Errorf("Expected a one-line change, got -%d +%d", got.Removed, got.Added)
+	}
+}
+
+func TestParseApplyPatch(t *testing.T) {
+	patch := `*** Begin Patch
+*** Add File: docs/new.md
++# Title
++Body
+*** Update File: main.go
+@@ func main() {
+-	fmt.Println("hi")
++	fmt.Println("hello")
+ }
+*** Delete File: old.txt
+*** End Patch`
+
+	diffs, err := ParseApplyPatch(patch)
+	if err != nil {
+		t.Fatalf("Failed to parse patch: %v", err)
+	}
+	if len(diffs) != 3 {
+		t.Fatalf("Expected 3 file diffs, got %d", len(diffs))
+	}
+
+	if diffs[0].Path != "docs/new.md" || diffs[0].Added != 2 ||
+		diffs[0].Text != "--- /dev/null\n+++ b/docs/new.md\n@@ -0,0 +1,2 @@\n+# Title\n+Body\n" {
+		t.Errorf("Unexpected added file diff: %+v", diffs[0])
+	}
+
+	want := "--- a/main.go\n+++ b/main.go\n@@ func main() {\n-\tfmt.Println(\"hi\")\n+\tfmt.Println(\"hello\")\n }\n"
+	if diffs[1].Text != want || diffs[1].Added != 1 || diffs[1].Removed != 1 {
+		t.Errorf("Unexpected update diff: %+v", diffs[1])
+	}
+
+	if diffs[2].Path != "old.txt" || !strings.Contains(diffs[2].Text, "+++ /dev/null") {
+		t.Errorf("Unexpected delete diff: %+v", diffs[2])
+	}
+}
+
+func TestParseApplyPatch_Move(t *testing.T) {
+	diffs, err := ParseApplyPatch("*** Begin Patch\n*** Update File: a.go\n*** Move to: b.go\n@@\n-x\n+y\n*** End Patch\n")
+	if err != nil {
+		t.Fatalf("Failed to parse patch: %v", err)
+	}
+	if len(diffs) != 1 || diffs[0].Path != "b.go" || !strings.HasPrefix(diffs[0].Text, "--- a/a.go\n+++ b/b.go\n") {
+		t.Errorf("Unexpected move diff: %+v", diffs)
+	}
+}
+
+func TestParseApplyPatch_Invalid(t *testing.T) {
+	if _, err := ParseApplyPatch("just text"); !errors.Is(err, ErrNotPatch) {
+		t.Errorf("Expected ErrNotPatch, got %v", err)
+	}
+	if _, err := ParseApplyPatch("*** Begin Patch\n*** Update File: a.go\n-x\n"); err == nil {
+		t.Error("Expected error for missing end marker")
+	}
+}
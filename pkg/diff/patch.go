@@ -0,0 +1,107 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// apply_patch envelope markers used by Codex
+const (
+	patchBegin     = "*** Begin Patch"
+	patchEnd       = "*** End Patch"
+	patchAdd       = "*** Add File: "
+	patchUpdate    = "*** Update File: "
+	patchDelete    = "*** Delete File: "
+	patchMove      = "*** Move to: "
+	patchEndOfFile = "*** End of File"
+)
+
+// ErrNotPatch is returned for input that is not an apply_patch envelope
+var ErrNotPatch = errors.New("not an apply_patch envelope")
+
+// ParseApplyPatch converts a Codex apply_patch envelope into one diff per file.
+// Update hunks carry context lines but no line numbers, so their headers are kept as written.
+func ParseApplyPatch(patch string) ([]FileDiff, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+
+	start := -1
+	for i, line := range lines {
+		if strings.TrimSpace(line) == patchBegin {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, ErrNotPatch
+	}
+
+	var diffs []FileDiff
+	var current *FileDiff
+	var body strings.Builder
+	adding := false
+
+	flush := func() {
+		if current != nil {
+			if adding && current.Added > 0 {
+				// Added files have no hunk header in the envelope
+				current.Text += fmt.Sprintf("@@ -0,0 +%s @@\n", hunkRange(1, current.Added))
+			}
+			current.Text += body.String()
+			diffs = append(diffs, *current)
+		}
+		current = nil
+		body.Reset()
+		adding = false
+	}
+
+	for _, line := range lines[start+1:] {
+		switch {
+		case strings.TrimSpace(line) == patchEnd:
+			flush()
+			return diffs, nil
+		case strings.HasPrefix(line, patchAdd):
+			flush()
+			path := strings.TrimPrefix(line, patchAdd)
+			current = &FileDiff{Path: path, Text: "--- " + DevNull + "\n+++ b/" + path + "\n"}
+			adding = true
+		case strings.HasPrefix(line, patchUpdate):
+			flush()
+			path := strings.TrimPrefix(line, patchUpdate)
+			current = &FileDiff{Path: path, Text: "--- a/" + path + "\n+++ b/" + path + "\n"}
+		case strings.HasPrefix(line, patchDelete):
+			flush()
+			path := strings.TrimPrefix(line, patchDelete)
+			current = &FileDiff{Path: path, Text: "--- a/" + path + "\n+++ " + DevNull + "\n"}
+		case strings.HasPrefix(line, patchMove):
+			if current == nil {
+				return nil, fmt.Errorf("%q outside a file section", line)
+			}
+			newPath := strings.TrimPrefix(line, patchMove)
+			current.Text = "--- a/" + current.Path + "\n+++ b/" + newPath + "\n"
+			current.Path = newPath
+		case line == patchEndOfFile:
+			// Anchors the hunk at end of file; nothing to render
+		case current == nil:
+			if strings.TrimSpace(line) != "" {
+				return nil, fmt.Errorf("unexpected line outside a file section: %q", line)
+			}
+		case strings.HasPrefix(line, "@@"):
+			body.WriteString(line + "\n")
+		case strings.HasPrefix(line, "+"):
+			current.Added++
+			body.WriteString(line + "\n")
+		case strings.HasPrefix(line, "-"):
+			current.Removed++
+			body.WriteString(line + "\n")
+		default:
+			// Context lines start with a space; tolerate ones whose space was stripped
+			if !strings.HasPrefix(line, " ") {
+				line = " " + line
+			}
+			body.WriteString(line + "\n")
+		}
+	}
+
+	return nil, errors.New("apply_patch envelope has no end marker")
+}
@@ -0,0 +1,221 @@
+// Package encrypt provides public-key encryption of logged content.
+// Each message is sealed to a recipient X25519 public key using an ephemeral
+// key pair, HKDF-SHA256 and AES-256-GCM, so only the holder of the matching
+// secret key can read it.
+package encrypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// PublicKeyPrefix marks an encoded recipient public key
+	PublicKeyPrefix = "tapline-pk1-"
+	// SecretKeyPrefix marks an encoded secret key
+	SecretKeyPrefix = "TAPLINE-SK1-"
+	// CiphertextPrefix marks an encrypted value
+	CiphertextPrefix = "tapline-enc1:"
+
+	hkdfInfo = "tapline-enc1"
+	keySize  = 32
+	// sealOverhead is the ephemeral public key, GCM nonce and GCM tag added to each message
+	sealOverhead = keySize + 12 + 16
+)
+
+var encoding = base64.RawURLEncoding
+
+// ErrNotEncrypted is returned when decrypting a value without the ciphertext prefix
+var ErrNotEncrypted = errors.New("value is not tapline-encrypted")
+
+// Encryptor seals messages to a single recipient
+type Encryptor struct {
+	recipient *ecdh.PublicKey
+}
+
+// Decryptor opens messages sealed to its key pair
+type Decryptor struct {
+	secret *ecdh.PrivateKey
+}
+
+// GenerateKey creates a new key pair and returns the encoded secret and public keys
+func GenerateKey() (secretKey, publicKey string, err error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return SecretKeyPrefix + encoding.EncodeToString(key.Bytes()),
+		PublicKeyPrefix + encoding.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+// NewEncryptor creates an Encryptor for an encoded recipient public key
+func NewEncryptor(publicKey string) (*Encryptor, error) {
+	raw, err := decodeKey(publicKey, PublicKeyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient public key: %w", err)
+	}
+	recipient, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient public key: %w", err)
+	}
+	return &Encryptor{recipient: recipient}, nil
+}
+
+// NewDecryptor creates a Decryptor for an encoded secret key
+func NewDecryptor(secretKey string) (*Decryptor, error) {
+	raw, err := decodeKey(secretKey, SecretKeyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret key: %w", err)
+	}
+	secret, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret key: %w", err)
+	}
+	return &Decryptor{secret: secret}, nil
+}
+
+// LoadDecryptor reads a secret key file. Blank lines and lines starting with '#' are ignored.
+func LoadDecryptor(path string) (*Decryptor, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // Key path is chosen by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret key file: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return NewDecryptor(line)
+	}
+
+	return nil, fmt.Errorf("no secret key found in %s", path)
+}
+
+// Encrypt seals plaintext and returns a prefixed, base64-encoded ciphertext
+func (e *Encryptor) Encrypt(plaintext []byte) (string, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	shared, err := ephemeral.ECDH(e.recipient)
+	if err != nil {
+		return "", fmt.Errorf("key agreement failed: %w", err)
+	}
+
+	ephemeralPub := ephemeral.PublicKey().Bytes()
+	aead, err := newAEAD(shared, ephemeralPub, e.recipient.Bytes())
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	out := make([]byte, 0, len(ephemeralPub)+len(nonce)+len(plaintext)+aead.Overhead())
+	out = append(out, ephemeralPub...)
+	out = append(out, nonce...)
+	out = aead.Seal(out, nonce, plaintext, ephemeralPub)
+
+	return CiphertextPrefix + encoding.EncodeToString(out), nil
+}
+
+// PlaintextLimit returns the largest plaintext size, in bytes, whose encrypted
+// value is at most n bytes long, or 0 when even an empty message does not fit
+func PlaintextLimit(n int) int {
+	return max(encoding.DecodedLen(n-len(CiphertextPrefix))-sealOverhead, 0)
+}
+
+// Decrypt opens a value produced by Encrypt
+func (d *Decryptor) Decrypt(value string) ([]byte, error) {
+	if !IsEncrypted(value) {
+		return nil, ErrNotEncrypted
+	}
+
+	data, err := encoding.DecodeString(strings.TrimPrefix(value, CiphertextPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext encoding: %w", err)
+	}
+	if len(data) < keySize {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	ephemeralPub := data[:keySize]
+	ephemeral, err := ecdh.X25519().NewPublicKey(ephemeralPub)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral key: %w", err)
+	}
+
+	shared, err := d.secret.ECDH(ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("key agreement failed: %w", err)
+	}
+
+	aead, err := newAEAD(shared, ephemeralPub, d.secret.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	rest := data[keySize:]
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], ephemeralPub)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed (wrong key or corrupted data): %w", err)
+	}
+	return plaintext, nil
+}
+
+// IsEncrypted reports whether value carries the ciphertext prefix
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, CiphertextPrefix)
+}
+
+func newAEAD(shared, ephemeralPub, recipientPub []byte) (cipher.AEAD, error) {
+	salt := make([]byte, 0, len(ephemeralPub)+len(recipientPub))
+	salt = append(salt, ephemeralPub...)
+	salt = append(salt, recipientPub...)
+
+	key, err := hkdf.Key(sha256.New, shared, salt, hkdfInfo, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("key derivation failed: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func decodeKey(encoded, prefix string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	if !strings.HasPrefix(encoded, prefix) {
+		return nil, fmt.Errorf("missing %q prefix", prefix)
+	}
+	raw, err := encoding.DecodeString(strings.TrimPrefix(encoded, prefix))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != keySize {
+		return nil, fmt.Errorf("expected %d bytes, got %d", keySize, len(raw))
+	}
+	return raw, nil
+}
@@ -0,0 +1,184 @@
+package encrypt
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestKeys(t *testing.T) (*Encryptor, *Decryptor) {
+	t.Helper()
+	secretKey, publicKey, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	enc, err := NewEncryptor(publicKey)
+	if err != nil {
+		t.Fatalf("Failed to create encryptor: %v", err)
+	}
+	dec, err := NewDecryptor(secretKey)
+	if err != nil {
+		t.Fatalf("Failed to create decryptor: %v", err)
+	}
+	return enc, dec
+}
+
+func TestEncryptDecrypt_RoundTrip(t *testing.T) {
+	enc, dec := newTestKeys(t)
+
+	plaintext := "my database password is hunter2 🔑"
+	ciphertext, err := enc.Encrypt([]byte(plaintext))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	if !IsEncrypted(ciphertext) {
+		t.Errorf("Expected ciphertext prefix, got %s", ciphertext)
+	}
+	if strings.Contains(ciphertext, "hunter2") {
+		t.Error("Ciphertext must not contain plaintext")
+	}
+
+	decrypted, err := dec.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+	if string(decrypted) != plaintext {
+		t.Errorf("Expected %q, got %q", plaintext, decrypted)
+	}
+}
+
+func TestEncrypt_NonDeterministic(t *testing.T) {
+	enc, _ := newTestKeys(t)
+
+	a, _ := enc.Encrypt([]byte("same"))
+	b, _ := enc.Encrypt([]byte("same"))
+	if a == b {
+		t.Error("Expected different ciphertexts for the same plaintext")
+	}
+}
+
+func TestPlaintextLimit(t *testing.T) {
+	enc, _ := newTestKeys(t)
+
+	for _, n := range []int{0, 50, 93, 94, 95, 100, 1000, 4096} {
+		limit := PlaintextLimit(n)
+		ciphertext, err := enc.Encrypt(make([]byte, limit))
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		if limit > 0 && len(ciphertext) > n {
+			t.Errorf("PlaintextLimit(%d) = %d encrypts to %d bytes", n, limit, len(ciphertext))
+		}
+		if longer, _ := enc.Encrypt(make([]byte, limit+1)); len(longer) <= n {
+			t.Errorf("PlaintextLimit(%d) = %d, but %d bytes still fit", n, limit, limit+1)
+		}
+	}
+}
+
+func TestDecrypt_WrongKey(t *testing.T) {
+	enc, _ := newTestKeys(t)
+	_, otherDec := newTestKeys(t)
+
+	ciphertext, _ := enc.Encrypt([]byte("secret"))
+	if _, err := otherDec.Decrypt(ciphertext); err == nil {
+		t.Error("Expected error when decrypting with the wrong key")
+	}
+}
+
+func TestDecrypt_NotEncrypted(t *testing.T) {
+	_, dec := newTestKeys(t)
+
+	if _, err := dec.Decrypt("plain text"); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Expected ErrNotEncrypted, got %v", err)
+	}
+}
+
+func TestNewEncryptor_InvalidKey(t *testing.T) {
+	for _, key := range []string{"", "tapline-pk1-short", "not-a-key", "TAPLINE-SK1-AAAA"} {
+		if _, err := NewEncryptor(key); err == nil {
+			t.Errorf("Expected error for key %q", key)
+		}
+	}
+}
+
+func TestLoadDecryptor(t *testing.T) {
+	secretKey, publicKey, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "key.txt")
+	content := "# public key: " + publicKey + "\n\n" + secretKey + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	dec, err := LoadDecryptor(path)
+	if err != nil {
+		t.Fatalf("Failed to load decryptor: %v", err)
+	}
+
+	enc, _ := NewEncryptor(publicKey)
+	ciphertext, _ := enc.Encrypt([]byte("hello"))
+	if plain, err := dec.Decrypt(ciphertext); err != nil || string(plain) != "hello" {
+		t.Errorf("Expected hello, got %q (%v)", plain, err)
+	}
+}
+
+func TestRecordWriter_DecryptLine(t *testing.T) {
+	enc, dec := newTestKeys(t)
+
+	var buf bytes.Buffer
+	rw := NewRecordWriter(&buf, enc)
+
+	record := `{"time":"2025-12-06T16:26:36Z","level":"INFO","msg":"conversation","service":"claude-code","content":"<b>secret</b>"}` + "\n"
+	if _, err := rw.Write([]byte(record)); err != nil {
+		t.Fatalf("Failed to write record: %v", err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "secret") || strings.Contains(out, "claude-code") {
+		t.Errorf("Expected record to be encrypted, got %s", out)
+	}
+	if !strings.HasPrefix(out, `{"time":"2025-12-06T16:26:36Z","level":"INFO","msg":"conversation","encrypted_record":"tapline-enc1:`) {
+		t.Errorf("Unexpected envelope: %s", out)
+	}
+
+	plain, err := dec.DecryptLine(bytes.TrimRight(buf.Bytes(), "\n"))
+	if err != nil {
+		t.Fatalf("Failed to decrypt line: %v", err)
+	}
+	if string(plain)+"\n" != record {
+		t.Errorf("Expected original record, got %s", plain)
+	}
+}
+
+func TestDecryptLine_ContentField(t *testing.T) {
+	enc, dec := newTestKeys(t)
+
+	ciphertext, _ := enc.Encrypt([]byte("line1\n\"quoted\" <tag>"))
+	line := []byte(`{"service":"claude-code","content":"` + ciphertext + `","role":"user"}`)
+
+	plain, err := dec.DecryptLine(line)
+	if err != nil {
+		t.Fatalf("Failed to decrypt line: %v", err)
+	}
+
+	want := `{"service":"claude-code","content":"line1\n\"quoted\" <tag>","role":"user"}`
+	if string(plain) != want {
+		t.Errorf("Expected %s, got %s", want, plain)
+	}
+}
+
+func TestDecryptLine_Plaintext(t *testing.T) {
+	_, dec := newTestKeys(t)
+
+	line := []byte(`{"content":"hello"}`)
+	plain, err := dec.DecryptLine(line)
+	if err != nil || string(plain) != string(line) {
+		t.Errorf("Expected unchanged line, got %s (%v)", plain, err)
+	}
+}
@@ -0,0 +1,104 @@
+package encrypt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// EncryptedRecordKey is the envelope field holding a whole encrypted record
+const EncryptedRecordKey = "encrypted_record"
+
+var ciphertextValue = regexp.MustCompile(`"` + regexp.QuoteMeta(CiphertextPrefix) + `[A-Za-z0-9_\-]+"`)
+
+// envelope is written in place of a record when whole-record encryption is on.
+// It keeps just enough in the clear for log pipelines to route and order records.
+type envelope struct {
+	Time      string `json:"time,omitempty"`
+	Level     string `json:"level,omitempty"`
+	Msg       string `json:"msg,omitempty"`
+	Encrypted string `json:"encrypted_record"`
+}
+
+// RecordWriter encrypts every JSON line written to it as a whole
+type RecordWriter struct {
+	w   io.Writer
+	enc *Encryptor
+}
+
+// NewRecordWriter wraps w so each record is replaced by an encrypted envelope
+func NewRecordWriter(w io.Writer, enc *Encryptor) *RecordWriter {
+	return &RecordWriter{w: w, enc: enc}
+}
+
+// Write encrypts one JSON record (slog writes one record per call)
+func (rw *RecordWriter) Write(p []byte) (int, error) {
+	record := bytes.TrimRight(p, "\n")
+
+	var env envelope
+	// Envelope fields are best-effort; a record that fails to parse is still encrypted
+	_ = json.Unmarshal(record, &env) //nolint:errcheck // See above
+
+	ciphertext, err := rw.enc.Encrypt(record)
+	if err != nil {
+		return 0, err
+	}
+	env.Encrypted = ciphertext
+
+	line, err := json.Marshal(env)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := rw.w.Write(append(line, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// DecryptLine restores a log line: an encrypted envelope is replaced by the
+// original record, and every encrypted string value is replaced by its plaintext.
+// Field order is preserved. Lines without encrypted data are returned unchanged.
+func (d *Decryptor) DecryptLine(line []byte) ([]byte, error) {
+	if bytes.Contains(line, []byte(`"`+EncryptedRecordKey+`"`)) {
+		var env envelope
+		if err := json.Unmarshal(line, &env); err == nil && env.Encrypted != "" {
+			record, err := d.Decrypt(env.Encrypted)
+			if err != nil {
+				return nil, err
+			}
+			line = record
+		}
+	}
+
+	var decryptErr error
+	line = ciphertextValue.ReplaceAllFunc(line, func(match []byte) []byte {
+		plaintext, err := d.Decrypt(string(match[1 : len(match)-1]))
+		if err != nil {
+			decryptErr = err
+			return match
+		}
+		encoded, err := marshalString(string(plaintext))
+		if err != nil {
+			decryptErr = err
+			return match
+		}
+		return encoded
+	})
+	if decryptErr != nil {
+		return nil, fmt.Errorf("failed to decrypt field: %w", decryptErr)
+	}
+
+	return line, nil
+}
+
+func marshalString(s string) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(s); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
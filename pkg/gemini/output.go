@@ -0,0 +1,136 @@
+// Package gemini provides parsing of Gemini CLI output.
+package gemini
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// OutputFormatJSON is the --output-format value for a single JSON result
+const OutputFormatJSON = "json"
+
+// ErrNotJSONOutput is returned for output that is not a Gemini JSON result
+var ErrNotJSONOutput = errors.New("not Gemini JSON output")
+
+// Output is the result Gemini CLI prints with --output-format json
+type Output struct {
+	Response string `json:"response"`
+	Stats    *Stats `json:"stats,omitempty"`
+	Error    *Error `json:"error,omitempty"`
+}
+
+// Error is the error of a failed run
+type Error struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Code    any    `json:"code,omitempty"`
+}
+
+// Stats are the session statistics of a run
+type Stats struct {
+	Models map[string]ModelStats `json:"models"`
+	Tools  ToolStats             `json:"tools"`
+	Files  FileStats             `json:"files"`
+}
+
+// ModelStats are the API calls and tokens of one model
+type ModelStats struct {
+	API struct {
+		TotalRequests  int   `json:"totalRequests"`
+		TotalErrors    int   `json:"totalErrors"`
+		TotalLatencyMs int64 `json:"totalLatencyMs"`
+	} `json:"api"`
+	Tokens TokenStats `json:"tokens"`
+}
+
+// TokenStats counts the tokens of one model
+type TokenStats struct {
+	Prompt     int `json:"prompt"`
+	Candidates int `json:"candidates"`
+	Total      int `json:"total"`
+	Cached     int `json:"cached"`
+	Thoughts   int `json:"thoughts"`
+	Tool       int `json:"tool"`
+}
+
+// ToolStats summarize the tool calls of a run
+type ToolStats struct {
+	TotalCalls      int                      `json:"totalCalls"`
+	TotalSuccess    int                      `json:"totalSuccess"`
+	TotalFail       int                      `json:"totalFail"`
+	TotalDurationMs int64                    `json:"totalDurationMs"`
+	ByName          map[string]ToolNameStats `json:"byName"`
+}
+
+// ToolNameStats summarize the calls of one tool
+type ToolNameStats struct {
+	Count      int   `json:"count"`
+	Success    int   `json:"success"`
+	Fail       int   `json:"fail"`
+	DurationMs int64 `json:"durationMs"`
+}
+
+// FileStats count the lines changed by a run
+type FileStats struct {
+	TotalLinesAdded   int `json:"totalLinesAdded"`
+	TotalLinesRemoved int `json:"totalLinesRemoved"`
+}
+
+// ParseOutput decodes the JSON result of a run
+func ParseOutput(data []byte) (*Output, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, ErrNotJSONOutput
+	}
+	if _, ok := raw["response"]; !ok {
+		if _, ok := raw["error"]; !ok {
+			return nil, ErrNotJSONOutput
+		}
+	}
+
+	var output Output
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
+
+// ModelNames returns the models used, sorted
+func (s *Stats) ModelNames() []string {
+	names := make([]string, 0, len(s.Models))
+	for name := range s.Models {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Model returns the models used, comma separated
+func (s *Stats) Model() string {
+	return strings.Join(s.ModelNames(), ",")
+}
+
+// Tokens returns the token counts summed over all models
+func (s *Stats) Tokens() TokenStats {
+	var total TokenStats
+	for _, model := range s.Models {
+		total.Prompt += model.Tokens.Prompt
+		total.Candidates += model.Tokens.Candidates
+		total.Total += model.Tokens.Total
+		total.Cached += model.Tokens.Cached
+		total.Thoughts += model.Tokens.Thoughts
+		total.Tool += model.Tokens.Tool
+	}
+	return total
+}
+
+// APIRequests returns the number of API requests and their total latency over all models
+func (s *Stats) APIRequests() (requests int, latencyMs int64) {
+	for _, model := range s.Models {
+		requests += model.API.TotalRequests
+		latencyMs += model.API.TotalLatencyMs
+	}
+	return requests, latencyMs
+}
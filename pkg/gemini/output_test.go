@@ -0,0 +1,69 @@
+package gemini
+
+import (
+	"errors"
+	"testing"
+)
+
+const sampleOutput = `{
+  "response": "Quantum computers use qubits.",
+  "stats": {
+    "models": {
+      "gemini-2.5-pro": {
+        "api": {"totalRequests": 2, "totalErrors": 0, "totalLatencyMs": 4200},
+        "tokens": {"prompt": 8000, "candidates": 300, "total": 8500, "cached": 5000, "thoughts": 200, "tool": 0}
+      },
+      "gemini-2.5-flash": {
+        "api": {"totalRequests": 1, "totalErrors": 0, "totalLatencyMs": 800},
+        "tokens": {"prompt": 1000, "candidates": 20, "total": 1020, "cached": 0, "thoughts": 0, "tool": 0}
+      }
+    },
+    "tools": {
+      "totalCalls": 2, "totalSuccess": 1, "totalFail": 1, "totalDurationMs": 350,
+      "byName": {"read_file": {"count": 2, "success": 1, "fail": 1, "durationMs": 350}}
+    },
+    "files": {"totalLinesAdded": 4, "totalLinesRemoved": 1}
+  }
+}`
+
+func TestParseOutput(t *testing.T) {
+	output, err := ParseOutput([]byte(sampleOutput))
+	if err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+	if output.Response != "Quantum computers use qubits." || output.Stats == nil {
+		t.Fatalf("Unexpected output: %+v", output)
+	}
+
+	stats := output.Stats
+	if model := stats.Model(); model != "gemini-2.5-flash,gemini-2.5-pro" {
+		t.Errorf("Unexpected models %q", model)
+	}
+	if tokens := stats.Tokens(); tokens.Prompt != 9000 || tokens.Candidates != 320 || tokens.Cached != 5000 || tokens.Total != 9520 {
+		t.Errorf("Unexpected token totals: %+v", tokens)
+	}
+	if requests, latency := stats.APIRequests(); requests != 3 || latency != 5000 {
+		t.Errorf("Unexpected API totals: %d requests, %dms", requests, latency)
+	}
+	if stats.Tools.TotalCalls != 2 || stats.Tools.ByName["read_file"].Fail != 1 || stats.Files.TotalLinesAdded != 4 {
+		t.Errorf("Unexpected tool and file stats: %+v", stats)
+	}
+}
+
+func TestParseOutput_Error(t *testing.T) {
+	output, err := ParseOutput([]byte(`{"error":{"type":"FatalAuthenticationError","message":"Not logged in","code":41}}`))
+	if err != nil {
+		t.Fatalf("Failed to parse error output: %v", err)
+	}
+	if output.Error == nil || output.Error.Message != "Not logged in" {
+		t.Errorf("Unexpected error output: %+v", output)
+	}
+}
+
+func TestParseOutput_NotJSON(t *testing.T) {
+	for _, data := range []string{"Plain text response", `{"other":"object"}`, `["list"]`} {
+		if _, err := ParseOutput([]byte(data)); !errors.Is(err, ErrNotJSONOutput) {
+			t.Errorf("Expected ErrNotJSONOutput for %q, got %v", data, err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package git
 import (
 	"context"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 )
@@ -122,3 +123,33 @@ func IsGitRepo() bool {
 	err := cmd.Run()
 	return err == nil
 }
+
+// RelativePath returns path relative to the root of the Git repository that contains it,
+// using forward slashes. Paths outside a repository are returned unchanged.
+func RelativePath(path string) string {
+	if !filepath.IsAbs(path) {
+		return filepath.ToSlash(path)
+	}
+
+	dir := filepath.Dir(path)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "--show-toplevel")
+	output, err := cmd.Output()
+	if err != nil {
+		return path
+	}
+	root := strings.TrimSpace(string(output))
+
+	// git reports the root with symlinks resolved (e.g. /private/var on macOS)
+	resolved := path
+	if resolvedDir, err := filepath.EvalSymlinks(dir); err == nil {
+		resolved = filepath.Join(resolvedDir, filepath.Base(path))
+	}
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
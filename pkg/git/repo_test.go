@@ -1,6 +1,10 @@
 package git
 
 import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 )
 
@@ -151,3 +155,27 @@ func TestGetGitOriginURL(t *testing.T) {
 
 	t.Logf("Origin URL: %s", url)
 }
+
+func TestRelativePath(t *testing.T) {
+	root := t.TempDir()
+	ctx := context.Background()
+	if err := exec.CommandContext(ctx, "git", "init", "-q", root).Run(); err != nil {
+		t.Skipf("git init failed: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "pkg", "app"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := RelativePath(filepath.Join(root, "pkg", "app", "main.go")); got != "pkg/app/main.go" {
+		t.Errorf("Expected pkg/app/main.go, got %s", got)
+	}
+
+	outside := filepath.Join(t.TempDir(), "notes.txt")
+	if got := RelativePath(outside); got != outside {
+		t.Errorf("Expected path outside a repository unchanged, got %s", got)
+	}
+
+	if got := RelativePath("already/relative.go"); got != "already/relative.go" {
+		t.Errorf("Expected relative path unchanged, got %s", got)
+	}
+}
@@ -0,0 +1,264 @@
+// Package graph reconstructs the hierarchy of sessions, subagent runs and tool
+// calls from tapline logs and renders it as a Mermaid or Graphviz DOT diagram.
+package graph
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Session is one logged session and what happened in it
+type Session struct {
+	ID              string
+	Service         string
+	ParentSessionID string
+	ParentEventID   string
+	// First and Last are the times of the first and last record
+	First, Last time.Time
+	// Start and End are the times of session_start and session_end; zero when not logged
+	Start, End time.Time
+	Prompts    int
+	Responses  int
+	ToolCalls  []*ToolCall
+	// Children are sessions spawned by this one that are not tied to a tool call
+	Children []*Session
+}
+
+// Turns returns the number of conversation turns: user prompts, or assistant
+// responses for sessions without logged prompts (subagents, notify-only agents)
+func (s *Session) Turns() int {
+	if s.Prompts > 0 {
+		return s.Prompts
+	}
+	return s.Responses
+}
+
+// Duration returns the session length from its start to its end event, falling back to its records
+func (s *Session) Duration() time.Duration {
+	start, end := s.First, s.Last
+	if !s.Start.IsZero() {
+		start = s.Start
+	}
+	if !s.End.IsZero() {
+		end = s.End
+	}
+	if end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// ToolCall is one tool invocation and the subagent runs it launched
+type ToolCall struct {
+	EventID   string
+	ToolName  string
+	ToolUseID string
+	Time      time.Time
+	// Duration is known once the matching tool_result is seen
+	Duration    time.Duration
+	HasDuration bool
+	// Success is nil until the matching tool_result is seen
+	Success   *bool
+	Subagents []*Session
+}
+
+// Graph is the reconstructed hierarchy
+type Graph struct {
+	Roots []*Session
+}
+
+// record holds the log fields the graph is built from
+type record struct {
+	Time            time.Time `json:"time"`
+	Msg             string    `json:"msg"`
+	Service         string    `json:"service"`
+	SessionID       string    `json:"session_id"`
+	EventID         string    `json:"event_id"`
+	ParentSessionID string    `json:"parent_session_id"`
+	ParentEventID   string    `json:"parent_event_id"`
+	Role            string    `json:"role"`
+	Event           string    `json:"event"`
+	ToolName        string    `json:"tool_name"`
+	ToolUseID       string    `json:"tool_use_id"`
+	DurationMs      *int64    `json:"duration_ms"`
+	Success         *bool     `json:"success"`
+	// ChunkIndex is set on split records; see pkg/chunk
+	ChunkIndex int `json:"chunk_index"`
+}
+
+// builder accumulates sessions while reading logs
+type builder struct {
+	sessions map[string]*Session
+	order    []*Session
+	calls    map[string]*ToolCall
+	// pendingResults maps session and tool_use_id to the call awaiting its result
+	pendingResults map[string]*ToolCall
+}
+
+// Build reads tapline JSON Lines logs and reconstructs the session hierarchy.
+// With rootSessionID set, the graph holds that session and its descendants;
+// otherwise it holds every session whose parent is not in the logs. Records
+// that are not conversation records (or are encrypted as a whole) are skipped.
+func Build(readers []io.Reader, rootSessionID string) (*Graph, error) {
+	b := &builder{
+		sessions:       make(map[string]*Session),
+		calls:          make(map[string]*ToolCall),
+		pendingResults: make(map[string]*ToolCall),
+	}
+
+	for _, r := range readers {
+		if err := b.read(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.link(rootSessionID)
+}
+
+func (b *builder) read(r io.Reader) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && line[0] == '{' {
+			var rec record
+			if err := json.Unmarshal(line, &rec); err == nil && rec.Msg == "conversation" && rec.SessionID != "" {
+				b.add(&rec)
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+func (b *builder) session(rec *record) *Session {
+	s, ok := b.sessions[rec.SessionID]
+	if !ok {
+		s = &Session{ID: rec.SessionID, First: rec.Time}
+		b.sessions[rec.SessionID] = s
+		b.order = append(b.order, s)
+	}
+	if s.Service == "" {
+		s.Service = rec.Service
+	}
+	if s.ParentSessionID == "" {
+		s.ParentSessionID = rec.ParentSessionID
+	}
+	if s.ParentEventID == "" {
+		s.ParentEventID = rec.ParentEventID
+	}
+	if rec.Time.Before(s.First) {
+		s.First = rec.Time
+	}
+	if rec.Time.After(s.Last) {
+		s.Last = rec.Time
+	}
+	return s
+}
+
+func (b *builder) add(rec *record) {
+	// Chunks after the first repeat the same event
+	if rec.ChunkIndex > 0 {
+		return
+	}
+
+	s := b.session(rec)
+
+	switch rec.Event {
+	case "session_start":
+		s.Start = rec.Time
+	case "session_end":
+		s.End = rec.Time
+	case "tool_call":
+		call := &ToolCall{EventID: rec.EventID, ToolName: rec.ToolName, ToolUseID: rec.ToolUseID, Time: rec.Time}
+		s.ToolCalls = append(s.ToolCalls, call)
+		if rec.EventID != "" {
+			b.calls[rec.EventID] = call
+		}
+		if rec.ToolUseID != "" {
+			b.pendingResults[rec.SessionID+"\x00"+rec.ToolUseID] = call
+		}
+	case "tool_result":
+		key := rec.SessionID + "\x00" + rec.ToolUseID
+		call, ok := b.pendingResults[key]
+		if !ok {
+			return
+		}
+		delete(b.pendingResults, key)
+		call.Success = rec.Success
+		switch {
+		case rec.DurationMs != nil:
+			call.Duration = time.Duration(*rec.DurationMs) * time.Millisecond
+			call.HasDuration = true
+		case !call.Time.IsZero() && !rec.Time.Before(call.Time):
+			call.Duration = rec.Time.Sub(call.Time)
+			call.HasDuration = true
+		}
+	case "":
+		switch rec.Role {
+		case "user":
+			s.Prompts++
+		case "assistant":
+			s.Responses++
+		}
+	}
+}
+
+// link attaches sessions to their parents and selects the roots
+func (b *builder) link(rootSessionID string) (*Graph, error) {
+	var roots []*Session
+	for _, s := range b.order {
+		if call, ok := b.calls[s.ParentEventID]; ok && s.ParentEventID != "" {
+			call.Subagents = append(call.Subagents, s)
+			continue
+		}
+		if parent, ok := b.sessions[s.ParentSessionID]; ok && parent != s {
+			parent.Children = append(parent.Children, s)
+			continue
+		}
+		roots = append(roots, s)
+	}
+
+	if rootSessionID == "" {
+		return &Graph{Roots: roots}, nil
+	}
+
+	root, ok := b.sessions[rootSessionID]
+	if !ok {
+		return nil, fmt.Errorf("session %s not found in logs", rootSessionID)
+	}
+	return &Graph{Roots: []*Session{root}}, nil
+}
+
+// Walk calls fn for every session reachable from the roots, parents before children.
+// Sessions are visited once even if the logs link them in a cycle.
+func (g *Graph) Walk(fn func(*Session)) {
+	seen := make(map[*Session]bool)
+	var visit func(*Session)
+	visit = func(s *Session) {
+		if seen[s] {
+			return
+		}
+		seen[s] = true
+		fn(s)
+		for _, call := range s.ToolCalls {
+			for _, sub := range call.Subagents {
+				visit(sub)
+			}
+		}
+		for _, child := range s.Children {
+			visit(child)
+		}
+	}
+	for _, root := range g.Roots {
+		visit(root)
+	}
+}
@@ -0,0 +1,162 @@
+package graph
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testLog = `{"time":"2025-01-01T10:00:00Z","msg":"conversation","service":"claude-code","session_id":"main","role":"system","event":"session_start"}
+{"time":"2025-01-01T10:00:01Z","msg":"conversation","service":"claude-code","session_id":"main","role":"user","content":"fix the bug"}
+{"time":"2025-01-01T10:00:02Z","msg":"conversation","service":"claude-code","session_id":"main","event_id":"ev-task","role":"assistant","event":"tool_call","tool_name":"Task","tool_use_id":"toolu_1"}
+{"time":"2025-01-01T10:00:02Z","msg":"conversation","service":"claude-code","session_id":"sub","parent_session_id":"main","parent_event_id":"ev-task","role":"system","event":"session_start"}
+{"time":"2025-01-01T10:00:03Z","msg":"conversation","service":"claude-code","session_id":"sub","parent_session_id":"main","parent_event_id":"ev-task","event_id":"ev-grep","role":"assistant","event":"tool_call","tool_name":"Grep","tool_use_id":"toolu_2"}
+{"time":"2025-01-01T10:00:04Z","msg":"conversation","service":"claude-code","session_id":"sub","parent_session_id":"main","parent_event_id":"ev-task","role":"tool","event":"tool_result","tool_use_id":"toolu_2","success":false}
+{"time":"2025-01-01T10:00:05Z","msg":"conversation","service":"claude-code","session_id":"sub","parent_session_id":"main","parent_event_id":"ev-task","role":"assistant","content":"Found \"it\"","chunk_index":0,"chunk_count":2}
+{"time":"2025-01-01T10:00:05Z","msg":"conversation","service":"claude-code","session_id":"sub","parent_session_id":"main","parent_event_id":"ev-task","role":"assistant","content":" here","chunk_index":1,"chunk_count":2}
+{"time":"2025-01-01T10:00:06Z","msg":"conversation","service":"claude-code","session_id":"sub","parent_session_id":"main","parent_event_id":"ev-task","role":"system","event":"session_end"}
+{"time":"2025-01-01T10:00:06Z","msg":"conversation","service":"claude-code","session_id":"main","role":"tool","event":"tool_result","tool_use_id":"toolu_1","success":true,"duration_ms":4000}
+not json
+{"time":"2025-01-01T10:00:07Z","msg":"conversation","service":"codex-cli","session_id":"codex","parent_session_id":"main","role":"assistant","content":"done"}
+{"time":"2025-01-01T10:00:08Z","msg":"conversation","service":"claude-code","session_id":"main","role":"assistant","content":"fixed"}
+{"time":"2025-01-01T10:01:00Z","msg":"conversation","service":"claude-code","session_id":"main","role":"system","event":"session_end"}
+{"time":"2025-01-01T11:00:00Z","msg":"conversation","service":"gemini-cli","session_id":"other","role":"user","content":"hi"}
+{"time":"2025-01-01T11:00:00Z","level":"INFO","msg":"startup"}
+`
+
+func build(t *testing.T, rootSessionID string) *Graph {
+	t.Helper()
+	g, err := Build([]io.Reader{strings.NewReader(testLog)}, rootSessionID)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	return g
+}
+
+func TestBuild(t *testing.T) {
+	g := build(t, "")
+
+	if len(g.Roots) != 2 || g.Roots[0].ID != "main" || g.Roots[1].ID != "other" {
+		t.Fatalf("Unexpected roots: %+v", g.Roots)
+	}
+
+	main := g.Roots[0]
+	if main.Turns() != 1 || main.Duration() != time.Minute {
+		t.Errorf("Expected 1 turn over 1m, got %d turns over %s", main.Turns(), main.Duration())
+	}
+	if len(main.ToolCalls) != 1 {
+		t.Fatalf("Expected 1 tool call, got %d", len(main.ToolCalls))
+	}
+
+	task := main.ToolCalls[0]
+	if task.ToolName != "Task" || !task.HasDuration || task.Duration != 4*time.Second {
+		t.Errorf("Unexpected Task call: %+v", task)
+	}
+	if len(task.Subagents) != 1 || task.Subagents[0].ID != "sub" {
+		t.Fatalf("Expected subagent under Task call, got %+v", task.Subagents)
+	}
+
+	sub := task.Subagents[0]
+	if sub.Turns() != 1 {
+		t.Errorf("Expected chunked response to count once, got %d turns", sub.Turns())
+	}
+	if sub.Duration() != 4*time.Second {
+		t.Errorf("Expected subagent duration 4s, got %s", sub.Duration())
+	}
+
+	grep := sub.ToolCalls[0]
+	if grep.Duration != time.Second || grep.Success == nil || *grep.Success {
+		t.Errorf("Expected failed Grep call paired by time, got %+v", grep)
+	}
+
+	if len(main.Children) != 1 || main.Children[0].ID != "codex" {
+		t.Errorf("Expected nested codex session, got %+v", main.Children)
+	}
+}
+
+func TestBuild_RootSession(t *testing.T) {
+	g := build(t, "sub")
+	if len(g.Roots) != 1 || g.Roots[0].ID != "sub" {
+		t.Errorf("Unexpected roots: %+v", g.Roots)
+	}
+
+	if _, err := Build([]io.Reader{strings.NewReader(testLog)}, "missing"); err == nil {
+		t.Error("Expected error for unknown session")
+	}
+}
+
+func TestBuild_Cycle(t *testing.T) {
+	input := `{"time":"2025-01-01T10:00:00Z","msg":"conversation","session_id":"a","parent_session_id":"b","role":"user"}
+{"time":"2025-01-01T10:00:00Z","msg":"conversation","session_id":"b","parent_session_id":"a","role":"user"}
+`
+	g, err := Build([]io.Reader{strings.NewReader(input)}, "a")
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	visited := 0
+	g.Walk(func(*Session) { visited++ })
+	if visited != 2 {
+		t.Errorf("Expected each session visited once, got %d visits", visited)
+	}
+}
+
+func TestRender_Mermaid(t *testing.T) {
+	var out bytes.Buffer
+	if err := build(t, "main").Render(&out, FormatMermaid); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	want := `flowchart TD
+    s0(["claude-code<br/>main<br/>1 turn, 1m0s<br/>1 tool call"])
+    t0["Task<br/>4s"]
+    s1(["claude-code<br/>sub<br/>1 turn, 4s<br/>1 tool call"])
+    t1["Grep<br/>1s"]
+    s2(["codex-cli<br/>codex<br/>1 turn, 0s"])
+    s0 --> t0
+    t0 --> s1
+    s0 -.-> s2
+    s1 --> t1
+    classDef failed stroke:#d33,color:#d33
+    class t1 failed
+`
+	if out.String() != want {
+		t.Errorf("Unexpected Mermaid output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestRender_DOT(t *testing.T) {
+	var out bytes.Buffer
+	if err := build(t, "sub").Render(&out, FormatDOT); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	want := `digraph tapline {
+    rankdir=TB;
+    node [fontname="Helvetica"];
+    s0 [label="claude-code\nsub\n1 turn, 4s\n1 tool call", shape=box, style=rounded];
+    t0 [label="Grep\n1s", shape=box, color=red, fontcolor=red];
+    s0 -> t0;
+}
+`
+	if out.String() != want {
+		t.Errorf("Unexpected DOT output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestRender_UnknownFormat(t *testing.T) {
+	if err := build(t, "").Render(io.Discard, "svg"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
+
+func TestEscape(t *testing.T) {
+	if got := mermaidEscape(`say "hi" <b>`); got != "say #quot;hi#quot; #lt;b#gt;" {
+		t.Errorf("mermaidEscape() = %q", got)
+	}
+	if got := dotEscape(`C:\dir "x"`); got != `C:\\dir \"x\"` {
+		t.Errorf("dotEscape() = %q", got)
+	}
+}
@@ -0,0 +1,201 @@
+package graph
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Output formats
+const (
+	FormatMermaid = "mermaid"
+	FormatDOT     = "dot"
+)
+
+// node is a session or tool call in the rendered diagram
+type node struct {
+	id      string
+	label   string
+	session bool
+	failed  bool
+}
+
+// edge links two nodes; spawn edges connect a session to a child session without a tool call
+type edge struct {
+	from, to string
+	spawn    bool
+}
+
+// Render writes the graph in the given format
+func (g *Graph) Render(w io.Writer, format string) error {
+	nodes, edges := g.layout()
+
+	out := bufio.NewWriter(w)
+	switch format {
+	case FormatMermaid:
+		writeMermaid(out, nodes, edges)
+	case FormatDOT:
+		writeDOT(out, nodes, edges)
+	default:
+		return fmt.Errorf("unknown format %q (use %s or %s)", format, FormatMermaid, FormatDOT)
+	}
+	return out.Flush()
+}
+
+// layout assigns node IDs in order of first appearance, parents before children
+func (g *Graph) layout() ([]node, []edge) {
+	var nodes []node
+	var edges []edge
+	ids := make(map[*Session]string)
+
+	sessionID := func(s *Session) string {
+		if id, ok := ids[s]; ok {
+			return id
+		}
+		id := fmt.Sprintf("s%d", len(ids))
+		ids[s] = id
+		return id
+	}
+
+	toolCount := 0
+	g.Walk(func(s *Session) {
+		id := sessionID(s)
+		nodes = append(nodes, node{id: id, label: sessionLabel(s), session: true})
+
+		for _, call := range s.ToolCalls {
+			callID := fmt.Sprintf("t%d", toolCount)
+			toolCount++
+			nodes = append(nodes, node{id: callID, label: toolLabel(call), failed: call.Success != nil && !*call.Success})
+			edges = append(edges, edge{from: id, to: callID})
+			for _, sub := range call.Subagents {
+				edges = append(edges, edge{from: callID, to: sessionID(sub)})
+			}
+		}
+		for _, child := range s.Children {
+			edges = append(edges, edge{from: id, to: sessionID(child), spawn: true})
+		}
+	})
+
+	return nodes, edges
+}
+
+func sessionLabel(s *Session) string {
+	name := s.Service
+	if name == "" {
+		name = "session"
+	}
+	lines := []string{name, s.ID}
+
+	lines = append(lines, plural(s.Turns(), "turn")+", "+formatDuration(s.Duration()))
+	if len(s.ToolCalls) > 0 {
+		lines = append(lines, plural(len(s.ToolCalls), "tool call"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func toolLabel(call *ToolCall) string {
+	name := call.ToolName
+	if name == "" {
+		name = "tool"
+	}
+	if !call.HasDuration {
+		return name
+	}
+	return name + "\n" + formatDuration(call.Duration)
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// formatDuration shortens a duration to a readable precision
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(100 * time.Millisecond).String()
+	default:
+		return d.Round(time.Second).String()
+	}
+}
+
+func writeMermaid(w *bufio.Writer, nodes []node, edges []edge) {
+	fmt.Fprint(w, "flowchart TD\n")
+	for _, n := range nodes {
+		label := mermaidEscape(n.label)
+		if n.session {
+			fmt.Fprintf(w, "    %s([\"%s\"])\n", n.id, label)
+		} else {
+			fmt.Fprintf(w, "    %s[\"%s\"]\n", n.id, label)
+		}
+	}
+	for _, e := range edges {
+		arrow := "-->"
+		if e.spawn {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(w, "    %s %s %s\n", e.from, arrow, e.to)
+	}
+
+	var failed []string
+	for _, n := range nodes {
+		if n.failed {
+			failed = append(failed, n.id)
+		}
+	}
+	if len(failed) > 0 {
+		fmt.Fprint(w, "    classDef failed stroke:#d33,color:#d33\n")
+		fmt.Fprintf(w, "    class %s failed\n", strings.Join(failed, ","))
+	}
+}
+
+// mermaidEscape makes text safe inside a quoted Mermaid label
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(
+		`"`, "#quot;",
+		"\n", "<br/>",
+		"<", "#lt;",
+		">", "#gt;",
+	).Replace(s)
+}
+
+func writeDOT(w *bufio.Writer, nodes []node, edges []edge) {
+	fmt.Fprint(w, "digraph tapline {\n")
+	fmt.Fprint(w, "    rankdir=TB;\n")
+	fmt.Fprint(w, "    node [fontname=\"Helvetica\"];\n")
+	for _, n := range nodes {
+		attrs := fmt.Sprintf("label=\"%s\"", dotEscape(n.label))
+		if n.session {
+			attrs += ", shape=box, style=rounded"
+		} else {
+			attrs += ", shape=box"
+		}
+		if n.failed {
+			attrs += ", color=red, fontcolor=red"
+		}
+		fmt.Fprintf(w, "    %s [%s];\n", n.id, attrs)
+	}
+	for _, e := range edges {
+		if e.spawn {
+			fmt.Fprintf(w, "    %s -> %s [style=dashed];\n", e.from, e.to)
+		} else {
+			fmt.Fprintf(w, "    %s -> %s;\n", e.from, e.to)
+		}
+	}
+	fmt.Fprint(w, "}\n")
+}
+
+// dotEscape makes text safe inside a quoted DOT string
+func dotEscape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+	).Replace(s)
+}
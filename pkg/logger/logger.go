@@ -4,17 +4,32 @@
 package logger
 
 import (
+	"crypto/ed25519"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/hirosassa/tapline/pkg/audit"
+	"github.com/hirosassa/tapline/pkg/chunk"
+	"github.com/hirosassa/tapline/pkg/config"
+	"github.com/hirosassa/tapline/pkg/encrypt"
 	"github.com/hirosassa/tapline/pkg/git"
+	"github.com/hirosassa/tapline/pkg/privacy"
+	"github.com/hirosassa/tapline/pkg/redact"
+	"github.com/hirosassa/tapline/pkg/risk"
 	"github.com/hirosassa/tapline/pkg/session"
 	"github.com/hirosassa/tapline/pkg/user"
 )
 
 // Logger handles conversation logging using slog
 type Logger struct {
-	slogger        *slog.Logger
+	slogger *slog.Logger
+	// output is the file records are written to, synced after each entry
+	output         *os.File
 	SessionManager *session.Manager
 	Service        string
 	UserID         string
@@ -23,12 +38,62 @@ type Logger struct {
 	GitRepoURL     string
 	GitRepoName    string
 	GitBranch      string
+	// Redactor scrubs secrets and PII from content before it is written; nil disables redaction
+	Redactor *redact.Redactor
+	// MetadataOnly replaces content with its digest so no conversation text is written
+	MetadataOnly bool
+	// Encryptor encrypts content before it is written; nil leaves content in the clear
+	Encryptor *encrypt.Encryptor
+	// Encrypted is set when records or their content are encrypted
+	Encrypted bool
+	// MaxContentBytes limits the content written per record; 0 means unlimited
+	MaxContentBytes int
+	// ChunkOversize splits oversized content into chunk records instead of truncating it
+	ChunkOversize bool
+	// RiskClassifier flags risky shell commands in command_exec events; nil disables flagging
+	RiskClassifier *risk.Classifier
+	// Lineage is the session ancestry inherited from a parent tapline-logged process
+	Lineage session.Lineage
 }
 
-// NewLogger creates a new Logger instance with slog JSON handler
+// NewLogger creates a new Logger instance with slog JSON handler. Records go to
+// the configured log file, or to stdout.
 func NewLogger(service string, sessionMgr *session.Manager) *Logger {
-	// Create JSON handler that writes to stdout
-	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+	return newLogger(service, sessionMgr, os.Stdout)
+}
+
+// NewWrapperLogger creates a Logger for a wrapper whose program owns stdout, so
+// that the program's output can be piped or parsed untouched. Records go to the
+// configured log file, or to stderr.
+func NewWrapperLogger(service string, sessionMgr *session.Manager) *Logger {
+	return newLogger(service, sessionMgr, os.Stderr)
+}
+
+func newLogger(service string, sessionMgr *session.Manager, fallback *os.File) *Logger {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	redactor, err := redact.New(cfg.Redaction)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v, using built-in redaction rules only\n", err)
+		cfg.Redaction.Rules = nil
+		redactor, _ = redact.New(cfg.Redaction) //nolint:errcheck // Built-in rules always compile
+	}
+
+	classifier, err := risk.New(cfg.Commands)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v, using built-in risk patterns only\n", err)
+		cfg.Commands.RiskPatterns = nil
+		classifier, _ = risk.New(cfg.Commands) //nolint:errcheck // Built-in patterns always compile
+	}
+
+	output := openOutput(cfg, fallback)
+	out, contentEncryptor := configureEncryption(cfg, configureAudit(cfg, sessionMgr, output))
+
+	// Create JSON handler that writes to the output
+	handler := slog.NewJSONHandler(out, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
 	})
 
@@ -41,23 +106,94 @@ func NewLogger(service string, sessionMgr *session.Manager) *Logger {
 		gitBranch = repoInfo.Branch
 	} else {
 		// Log at debug level; git info is optional, so we don't fail
-		tmpLogger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		tmpLogger := slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{
 			Level: slog.LevelDebug,
 		}))
 		tmpLogger.Debug("Failed to retrieve git repo info", slog.Any("error", err))
 	}
 
 	return &Logger{
-		slogger:        slog.New(handler),
-		Service:        service,
-		SessionManager: sessionMgr,
-		UserID:         userInfo.UserID,
-		UserSource:     userInfo.Source,
-		Hostname:       userInfo.Hostname,
-		GitRepoURL:     gitRepoURL,
-		GitRepoName:    gitRepoName,
-		GitBranch:      gitBranch,
+		slogger:         slog.New(handler),
+		output:          output,
+		Service:         service,
+		SessionManager:  sessionMgr,
+		UserID:          userInfo.UserID,
+		UserSource:      userInfo.Source,
+		Hostname:        userInfo.Hostname,
+		GitRepoURL:      gitRepoURL,
+		GitRepoName:     gitRepoName,
+		GitBranch:       gitBranch,
+		Redactor:        redactor,
+		MetadataOnly:    cfg.Privacy.MetadataOnly(),
+		Encryptor:       contentEncryptor,
+		Encrypted:       cfg.Encryption.Enabled(),
+		MaxContentBytes: cfg.Limits.MaxContentBytes,
+		ChunkOversize:   cfg.Limits.Chunked(),
+		RiskClassifier:  classifier,
+		Lineage:         session.LineageFromEnv(),
+	}
+}
+
+// openOutput opens the configured log file for appending, or returns fallback
+// when none is configured or it cannot be opened
+func openOutput(cfg *config.Config, fallback *os.File) *os.File {
+	if cfg.Output.File == "" {
+		return fallback
+	}
+
+	f, err := os.OpenFile(cfg.Output.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600) //nolint:gosec // Path is user-controlled by design
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to open log file: %v, logging to %s\n", err, fallback.Name())
+		return fallback
 	}
+	return f
+}
+
+// configureAudit returns output, wrapped in the audit hash chain when enabled
+func configureAudit(cfg *config.Config, sessionMgr *session.Manager, output io.Writer) io.Writer {
+	if !cfg.Audit.Enabled || sessionMgr == nil {
+		return output
+	}
+
+	var signer ed25519.PrivateKey
+	if cfg.Audit.SigningKey != "" {
+		key, err := audit.LoadSigningKey(cfg.Audit.SigningKey)
+		if err != nil {
+			// Unsigned records still fail signature verification, so the gap is visible
+			fmt.Fprintf(os.Stderr, "Warning: %v, audit records will not be signed\n", err)
+		}
+		signer = key
+	}
+
+	return audit.NewChainWriter(output, sessionMgr, cfg.Audit.ChainName(), signer)
+}
+
+// configureEncryption returns the log output writer on top of out and, for
+// content-scoped encryption, the encryptor to apply to content fields
+func configureEncryption(cfg *config.Config, out io.Writer) (io.Writer, *encrypt.Encryptor) {
+	if !cfg.Encryption.Enabled() {
+		return out, nil
+	}
+
+	encryptor, err := encrypt.NewEncryptor(cfg.Encryption.Recipient)
+	if err != nil {
+		// Fail closed: never write plaintext when encryption was requested
+		fmt.Fprintf(os.Stderr, "Warning: %v, logging metadata only\n", err)
+		cfg.Privacy.Mode = config.PrivacyModeMetadata
+		return out, nil
+	}
+
+	if cfg.Encryption.WholeRecord() {
+		return encrypt.NewRecordWriter(out, encryptor), nil
+	}
+	return out, encryptor
+}
+
+// StoresContent reports whether conversation content may be kept in session state
+// until it is logged. In metadata-only mode, or when the log is encrypted, state
+// files would be the only place holding it in the clear, so it may not.
+func (l *Logger) StoresContent() bool {
+	return !l.MetadataOnly && !l.Encrypted
 }
 
 // appendGitAttrs appends Git-related attributes to the slice if they are set
@@ -74,103 +210,370 @@ func (l *Logger) appendGitAttrs(attrs []any) []any {
 	return attrs
 }
 
-// LogUserPrompt logs a user prompt
-func (l *Logger) LogUserPrompt(sessionID, content string) {
-	attrs := []any{
-		slog.String("service", l.Service),
-		slog.String("session_id", sessionID),
-		slog.String("user_id", l.UserID),
-		slog.String("user_source", l.UserSource),
-		slog.String("hostname", l.Hostname),
+// Entry is a single conversation log record
+type Entry struct {
+	SessionID string
+	// EventID identifies the event; a new one is generated when empty
+	EventID string
+	// ParentSessionID links the session to the session that spawned it; when empty
+	// it is taken from the inherited Lineage
+	ParentSessionID string
+	// ParentEventID identifies the event in the parent session that spawned it
+	ParentEventID string
+	Role          string
+	Content       string
+	Event         string
+	Metadata      map[string]string
+	// Stderr is a wrapped program's error output. It goes through the content
+	// pipeline like Content, except that it is truncated rather than chunked.
+	Stderr string
+	// Attrs are event-specific structured fields written after the event name
+	Attrs []slog.Attr
+}
+
+// Log writes an entry after applying the content pipeline (redaction, privacy,
+// size limits, encryption) to its content and stderr, and returns its event ID. Content over the size limit
+// is truncated or split into several chunk records sharing the event ID.
+func (l *Logger) Log(entry Entry) string {
+	eventID := entry.EventID
+	if eventID == "" {
+		eventID = uuid.New().String()
+	}
+
+	content, redactions := l.Redactor.Redact(entry.Content)
+	stderr, stderrRedactions := l.Redactor.Redact(entry.Stderr)
+	redactions += stderrRedactions
+
+	var metadataAttrs []any
+	if len(entry.Metadata) > 0 {
+		metadataAttrs = make([]any, 0, len(entry.Metadata)*2)
+		for k, v := range entry.Metadata {
+			redacted, n := l.Redactor.Redact(v)
+			redactions += n
+			metadataAttrs = append(metadataAttrs, k, l.metadataValue(k, redacted))
+		}
+	}
+
+	extraAttrs, attrRedactions := l.redactAttrs(entry.Attrs)
+	redactions += attrRedactions
+
+	chunks, limitAttrs := l.limitContent(content)
+	stderr, stderrLimitAttrs := l.truncateField("stderr", stderr)
+
+	parentSessionID := entry.ParentSessionID
+	if parentSessionID == "" {
+		parentSessionID = l.Lineage.ParentOf(entry.SessionID)
 	}
 
-	attrs = l.appendGitAttrs(attrs)
+	for i, piece := range chunks {
+		attrs := []any{
+			slog.String("service", l.Service),
+			slog.String("session_id", entry.SessionID),
+			slog.String(chunk.EventIDKey, eventID),
+		}
+		if parentSessionID != "" {
+			attrs = append(attrs, slog.String("parent_session_id", parentSessionID))
+		}
+		if entry.ParentEventID != "" {
+			attrs = append(attrs, slog.String("parent_event_id", entry.ParentEventID))
+		}
+		attrs = append(attrs,
+			slog.String("user_id", l.UserID),
+			slog.String("user_source", l.UserSource),
+			slog.String("hostname", l.Hostname),
+		)
 
-	attrs = append(attrs,
-		slog.String("role", "user"),
-		slog.String("content", content),
-	)
+		attrs = l.appendGitAttrs(attrs)
 
-	l.slogger.Info("conversation", attrs...)
-	//nolint:errcheck // Sync errors are not critical for logging
-	os.Stdout.Sync()
+		attrs = append(attrs, slog.String("role", entry.Role))
+		attrs = append(attrs, l.contentAttrs("content", piece)...)
+		attrs = append(attrs, limitAttrs...)
+		if stderr != "" && i == 0 {
+			attrs = append(attrs, l.contentAttrs("stderr", stderr)...)
+			attrs = append(attrs, stderrLimitAttrs...)
+		}
+
+		if len(chunks) > 1 {
+			attrs = append(attrs,
+				slog.Int(chunk.IndexKey, i),
+				slog.Int(chunk.CountKey, len(chunks)),
+			)
+		}
+
+		if entry.Event != "" {
+			attrs = append(attrs, slog.String("event", entry.Event))
+		}
+
+		for _, attr := range extraAttrs {
+			attrs = append(attrs, attr)
+		}
+
+		if len(metadataAttrs) > 0 {
+			attrs = append(attrs, slog.Group("metadata", metadataAttrs...))
+		}
+
+		if l.Redactor != nil {
+			attrs = append(attrs, slog.Int("redactions", redactions))
+		}
+
+		l.slogger.Info("conversation", attrs...)
+	}
+
+	if l.output != nil {
+		//nolint:errcheck // Sync errors are not critical for logging
+		l.output.Sync()
+	}
+
+	return eventID
 }
 
-// LogAssistantResponse logs an assistant response
-func (l *Logger) LogAssistantResponse(sessionID, content string) {
-	attrs := []any{
-		slog.String("service", l.Service),
-		slog.String("session_id", sessionID),
-		slog.String("user_id", l.UserID),
-		slog.String("user_source", l.UserSource),
-		slog.String("hostname", l.Hostname),
+// plainMetadata lists the metadata keys whose values describe the run, such as
+// the model or hook source, rather than hold free-form text like paths or options
+var plainMetadata = map[string]bool{
+	"mode":                true,
+	"model":               true,
+	"source":              true,
+	"hostname":            true,
+	"permission_mode":     true,
+	"claude_code_version": true,
+	"subagent_type":       true,
+	"tool_use_id":         true,
+	"stdin_bytes":         true,
+}
+
+// metadataValue renders a metadata value according to the privacy and encryption
+// settings. Free-form values are replaced by their digest in metadata-only mode
+// and encrypted along with content.
+func (l *Logger) metadataValue(key, value string) string {
+	if plainMetadata[key] || value == "" {
+		return value
 	}
 
-	attrs = l.appendGitAttrs(attrs)
+	switch {
+	case l.MetadataOnly:
+		return "sha256:" + privacy.NewDigest(value).SHA256
+	case l.Encryptor != nil:
+		ciphertext, err := l.Encryptor.Encrypt([]byte(value))
+		if err != nil {
+			// Fail closed: describe the value rather than write it in the clear
+			fmt.Fprintf(os.Stderr, "Warning: failed to encrypt metadata %s: %v\n", key, err)
+			return "sha256:" + privacy.NewDigest(value).SHA256
+		}
+		return ciphertext
+	default:
+		return value
+	}
+}
 
-	attrs = append(attrs,
-		slog.String("role", "assistant"),
-		slog.String("content", content),
-	)
+// redactAttrs redacts the string values of event attributes, including those in
+// groups, and returns the number of replacements. Identifiers (keys ending in
+// "_id") are left alone, since random-looking IDs would trip the entropy detector.
+func (l *Logger) redactAttrs(attrs []slog.Attr) ([]slog.Attr, int) {
+	if l.Redactor == nil || len(attrs) == 0 {
+		return attrs, 0
+	}
 
-	l.slogger.Info("conversation", attrs...)
-	//nolint:errcheck // Sync errors are not critical for logging
-	os.Stdout.Sync()
+	redacted := make([]slog.Attr, len(attrs))
+	count := 0
+	for i, attr := range attrs {
+		switch attr.Value.Kind() {
+		case slog.KindString:
+			if !strings.HasSuffix(attr.Key, "_id") {
+				value, n := l.Redactor.Redact(attr.Value.String())
+				attr = slog.String(attr.Key, value)
+				count += n
+			}
+		case slog.KindGroup:
+			group, n := l.redactAttrs(attr.Value.Group())
+			attr = slog.Attr{Key: attr.Key, Value: slog.GroupValue(group...)}
+			count += n
+		}
+		redacted[i] = attr
+	}
+	return redacted, count
 }
 
-// LogSessionStart logs a session start event
-func (l *Logger) LogSessionStart(sessionID string, metadata map[string]string) {
+// limitContent applies the content size limit. It returns the content pieces to
+// write (one per record) and the attributes describing any truncation or split.
+func (l *Logger) limitContent(content string) ([]string, []any) {
+	limit := l.contentLimit()
+	if l.MetadataOnly || limit <= 0 || len(content) <= limit {
+		return []string{content}, nil
+	}
+
+	digest := privacy.NewDigest(content)
 	attrs := []any{
-		slog.String("service", l.Service),
-		slog.String("session_id", sessionID),
-		slog.String("user_id", l.UserID),
-		slog.String("user_source", l.UserSource),
-		slog.String("hostname", l.Hostname),
-	}
-
-	attrs = l.appendGitAttrs(attrs)
-
-	attrs = append(attrs,
-		slog.String("role", "system"),
-		slog.String("content", ""),
-		slog.String("event", "session_start"),
-	)
-
-	// Add metadata
-	if len(metadata) > 0 {
-		metadataAttrs := make([]any, 0, len(metadata)*2)
-		for k, v := range metadata {
-			metadataAttrs = append(metadataAttrs, k, v)
+		slog.Int("content_original_bytes", len(content)),
+		slog.String("content_sha256", digest.SHA256),
+	}
+
+	if l.ChunkOversize {
+		return chunk.Split(content, limit), attrs
+	}
+
+	return []string{chunk.Truncate(content, limit)}, append(attrs, slog.Bool("content_truncated", true))
+}
+
+// contentLimit returns the number of plaintext bytes a text field may hold. With
+// encryption the limit applies to the written ciphertext, so the encoding and
+// sealing overhead is subtracted; at least one byte is kept so content still
+// makes progress. 0 means unlimited.
+func (l *Logger) contentLimit() int {
+	if l.MaxContentBytes <= 0 || !(l.Encrypted || l.Encryptor != nil) {
+		return l.MaxContentBytes
+	}
+	return max(encrypt.PlaintextLimit(l.MaxContentBytes), 1)
+}
+
+// truncateField applies the content size limit to a text field other than content
+func (l *Logger) truncateField(key, text string) (string, []any) {
+	limit := l.contentLimit()
+	if l.MetadataOnly || limit <= 0 || len(text) <= limit {
+		return text, nil
+	}
+
+	return chunk.Truncate(text, limit), []any{
+		slog.Int(key+"_original_bytes", len(text)),
+		slog.String(key+"_sha256", privacy.NewDigest(text).SHA256),
+		slog.Bool(key+"_truncated", true),
+	}
+}
+
+// contentAttrs renders a conversation text field according to the privacy and encryption settings
+func (l *Logger) contentAttrs(key, text string) []any {
+	switch {
+	case l.MetadataOnly:
+		attrs := []any{slog.String(key, "")}
+		if text != "" {
+			attrs = append(attrs, digestAttrs(key, text)...)
+		}
+		return attrs
+	case l.Encryptor != nil && text != "":
+		ciphertext, err := l.Encryptor.Encrypt([]byte(text))
+		if err != nil {
+			// Fail closed: describe the text rather than write it in the clear
+			fmt.Fprintf(os.Stderr, "Warning: failed to encrypt %s: %v\n", key, err)
+			return append([]any{slog.String(key, "")}, digestAttrs(key, text)...)
 		}
-		attrs = append(attrs, slog.Group("metadata", metadataAttrs...))
+		return []any{slog.String(key, ciphertext)}
+	default:
+		return []any{slog.String(key, text)}
 	}
+}
+
+// digestAttrs describes a text field by its digest instead of its value
+func digestAttrs(key, text string) []any {
+	digest := privacy.NewDigest(text)
+	attrs := []any{
+		slog.Int(key+"_length", digest.Length),
+		slog.Int(key+"_lines", digest.Lines),
+		slog.String(key+"_sha256", digest.SHA256),
+	}
+	if len(digest.Languages) > 0 {
+		attrs = append(attrs, slog.Any(key+"_languages", digest.Languages))
+	}
+	return attrs
+}
+
+// LogUserPrompt logs a user prompt
+func (l *Logger) LogUserPrompt(sessionID, content string) {
+	l.Log(Entry{SessionID: sessionID, Role: "user", Content: content})
+}
 
-	l.slogger.Info("conversation", attrs...)
-	//nolint:errcheck // Sync errors are not critical for logging
-	os.Stdout.Sync()
+// LogAssistantResponse logs an assistant response
+func (l *Logger) LogAssistantResponse(sessionID, content string) {
+	l.Log(Entry{SessionID: sessionID, Role: "assistant", Content: content})
+}
+
+// LogSessionStart logs a session start event
+func (l *Logger) LogSessionStart(sessionID string, metadata map[string]string) {
+	l.Log(Entry{SessionID: sessionID, Role: "system", Event: "session_start", Metadata: metadata})
 }
 
 // LogSessionEnd logs a session end event
 func (l *Logger) LogSessionEnd(sessionID string) {
-	attrs := []any{
-		slog.String("service", l.Service),
-		slog.String("session_id", sessionID),
-		slog.String("user_id", l.UserID),
-		slog.String("user_source", l.UserSource),
-		slog.String("hostname", l.Hostname),
+	l.Log(Entry{SessionID: sessionID, Role: "system", Event: "session_end"})
+}
+
+// CommandExec describes a shell command run by an agent
+type CommandExec struct {
+	SessionID       string
+	ParentSessionID string
+	ParentEventID   string
+	Command         string
+	Cwd             string
+	// ExitCode is nil when the agent does not report it
+	ExitCode *int
+	// Duration is nil when the start of the command is unknown
+	Duration *time.Duration
+	// Attrs are additional fields such as the tool name
+	Attrs []slog.Attr
+}
+
+// LogCommandExec logs a command_exec event. The command is written as content,
+// so redaction, privacy and encryption apply to it; risk patterns are matched
+// against the original command.
+func (l *Logger) LogCommandExec(cmd CommandExec) string {
+	risks := l.RiskClassifier.Classify(cmd.Command)
+
+	attrs := []slog.Attr{slog.String("cwd", cmd.Cwd)}
+	if cmd.ExitCode != nil {
+		attrs = append(attrs, slog.Int("exit_code", *cmd.ExitCode))
+	}
+	if cmd.Duration != nil {
+		attrs = append(attrs, slog.Int64("duration_ms", cmd.Duration.Milliseconds()))
 	}
+	attrs = append(attrs, slog.Bool("risky", len(risks) > 0))
+	if len(risks) > 0 {
+		attrs = append(attrs, slog.Any("risks", risks))
+	}
+	attrs = append(attrs, cmd.Attrs...)
 
-	attrs = l.appendGitAttrs(attrs)
+	return l.Log(Entry{
+		SessionID:       cmd.SessionID,
+		ParentSessionID: cmd.ParentSessionID,
+		ParentEventID:   cmd.ParentEventID,
+		Role:            "tool",
+		Event:           "command_exec",
+		Content:         cmd.Command,
+		Attrs:           attrs,
+	})
+}
 
-	attrs = append(attrs,
-		slog.String("role", "system"),
-		slog.String("content", ""),
-		slog.String("event", "session_end"),
-	)
+// FileEdit describes a change an agent made to one file
+type FileEdit struct {
+	SessionID       string
+	ParentSessionID string
+	ParentEventID   string
+	// Path is relative to the Git root when the file is inside a repository
+	Path string
+	// Diff is the unified diff of the change
+	Diff    string
+	Added   int
+	Removed int
+	// Attrs are additional fields such as the tool name
+	Attrs []slog.Attr
+}
 
-	l.slogger.Info("conversation", attrs...)
-	//nolint:errcheck // Sync errors are not critical for logging
-	os.Stdout.Sync()
+// LogFileEdit logs a file_edit event with the diff as content, so redaction,
+// privacy, size limits and encryption apply to it
+func (l *Logger) LogFileEdit(edit FileEdit) string {
+	attrs := []slog.Attr{
+		slog.String("file_path", edit.Path),
+		slog.Int("lines_added", edit.Added),
+		slog.Int("lines_removed", edit.Removed),
+	}
+	attrs = append(attrs, edit.Attrs...)
+
+	return l.Log(Entry{
+		SessionID:       edit.SessionID,
+		ParentSessionID: edit.ParentSessionID,
+		ParentEventID:   edit.ParentEventID,
+		Role:            "tool",
+		Event:           "file_edit",
+		Content:         edit.Diff,
+		Attrs:           attrs,
+	})
 }
 
 // Adapter interface for future service implementations
@@ -0,0 +1,64 @@
+// Package privacy provides content digests for metadata-only logging.
+// A digest describes conversation text (size, shape, fingerprint) without
+// retaining any of the text itself.
+package privacy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// codeFence matches the opening line of a Markdown fenced code block and captures its language
+var codeFence = regexp.MustCompile("(?m)^[ \t]*(?:```|~~~)[ \t]*([A-Za-z0-9_+#.\\-]*)")
+
+// Digest summarizes content without revealing it
+type Digest struct {
+	Length    int      `json:"length"`
+	Lines     int      `json:"lines"`
+	SHA256    string   `json:"sha256"`
+	Languages []string `json:"languages,omitempty"`
+}
+
+// NewDigest computes the digest of content
+func NewDigest(content string) Digest {
+	hash := sha256.Sum256([]byte(content))
+
+	lines := 0
+	if content != "" {
+		lines = strings.Count(content, "\n") + 1
+		if strings.HasSuffix(content, "\n") {
+			lines--
+		}
+	}
+
+	return Digest{
+		Length:    utf8.RuneCountInString(content),
+		Lines:     lines,
+		SHA256:    hex.EncodeToString(hash[:]),
+		Languages: codeBlockLanguages(content),
+	}
+}
+
+// codeBlockLanguages returns the distinct languages of fenced code blocks in order of appearance.
+// Fences alternate between opening and closing, so only every other fence carries a language.
+func codeBlockLanguages(content string) []string {
+	var languages []string
+	seen := make(map[string]bool)
+
+	opening := true
+	for _, match := range codeFence.FindAllStringSubmatch(content, -1) {
+		if opening {
+			lang := strings.ToLower(match[1])
+			if lang != "" && !seen[lang] {
+				seen[lang] = true
+				languages = append(languages, lang)
+			}
+		}
+		opening = !opening
+	}
+
+	return languages
+}
@@ -0,0 +1,59 @@
+package privacy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewDigest(t *testing.T) {
+	content := "Here is the fix:\n```go\nfunc main() {}\n```\nAnd a script:\n```bash\necho hi\n```\n```go\nx := 1\n```"
+
+	d := NewDigest(content)
+
+	if d.Length != len(content) {
+		t.Errorf("Expected length %d, got %d", len(content), d.Length)
+	}
+	if d.Lines != 11 {
+		t.Errorf("Expected 11 lines, got %d", d.Lines)
+	}
+	if len(d.SHA256) != 64 {
+		t.Errorf("Expected 64-char hex digest, got %q", d.SHA256)
+	}
+	if !reflect.DeepEqual(d.Languages, []string{"go", "bash"}) {
+		t.Errorf("Expected languages [go bash], got %v", d.Languages)
+	}
+}
+
+func TestNewDigest_Empty(t *testing.T) {
+	d := NewDigest("")
+
+	if d.Length != 0 || d.Lines != 0 {
+		t.Errorf("Expected zero length and lines, got %d/%d", d.Length, d.Lines)
+	}
+	if d.SHA256 != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Errorf("Unexpected digest of empty content: %s", d.SHA256)
+	}
+	if d.Languages != nil {
+		t.Errorf("Expected no languages, got %v", d.Languages)
+	}
+}
+
+func TestNewDigest_CountsRunes(t *testing.T) {
+	d := NewDigest("こんにちは\n")
+
+	if d.Length != 6 {
+		t.Errorf("Expected rune length 6, got %d", d.Length)
+	}
+	if d.Lines != 1 {
+		t.Errorf("Expected 1 line, got %d", d.Lines)
+	}
+}
+
+func TestCodeBlockLanguages_UnlabeledFences(t *testing.T) {
+	content := "```\nplain\n```\n~~~Python\nprint(1)\n~~~"
+
+	got := codeBlockLanguages(content)
+	if !reflect.DeepEqual(got, []string{"python"}) {
+		t.Errorf("Expected [python], got %v", got)
+	}
+}
@@ -0,0 +1,175 @@
+// Package redact provides secret and PII redaction for logged content.
+// It combines built-in detectors for common credential formats with
+// user-defined regular expressions and a high-entropy string heuristic.
+package redact
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hirosassa/tapline/pkg/config"
+)
+
+const (
+	entropyDetectorName = "high_entropy"
+	minEntropyLength    = 24
+	entropyThreshold    = 4.3
+)
+
+// Rule is a named redaction pattern
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Group selects the submatch to replace; 0 replaces the whole match.
+	Group int
+}
+
+// builtinRules are applied in order, so more specific patterns come first
+var builtinRules = []Rule{
+	{Name: "private_key", Pattern: regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z0-9 ]*PRIVATE KEY-----`)},
+	{Name: "aws_access_key", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA)[0-9A-Z]{16}\b`)},
+	{Name: "aws_secret_key", Pattern: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`), Group: 1},
+	{Name: "gcp_api_key", Pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}`)},
+	{Name: "github_token", Pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})`)},
+	{Name: "anthropic_api_key", Pattern: regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_\-]{20,}`)},
+	{Name: "openai_api_key", Pattern: regexp.MustCompile(`\bsk-(?:proj-|svcacct-|admin-)?[A-Za-z0-9_\-]{20,}`)},
+	{Name: "jwt", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]{5,}\.eyJ[A-Za-z0-9_\-]{5,}\.[A-Za-z0-9_\-]+`)},
+	{Name: "connection_string", Pattern: regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.\-]*://[^\s:/@]+:([^\s@/]+)@`), Group: 1},
+	{Name: "email", Pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}\b`)},
+}
+
+var entropyCandidate = regexp.MustCompile(`[A-Za-z0-9+/=_\-]{24,}`)
+
+// Redactor replaces sensitive substrings with placeholders
+type Redactor struct {
+	rules   []Rule
+	entropy bool
+}
+
+// New creates a Redactor from configuration. It returns nil when redaction is disabled.
+func New(cfg config.RedactionConfig) (*Redactor, error) {
+	if !cfg.IsEnabled() {
+		return nil, nil
+	}
+
+	r := &Redactor{
+		entropy: !slices.Contains(cfg.DisableBuiltin, entropyDetectorName),
+	}
+
+	for _, rule := range builtinRules {
+		if slices.Contains(cfg.DisableBuiltin, rule.Name) {
+			continue
+		}
+		r.rules = append(r.rules, rule)
+	}
+
+	for _, custom := range cfg.Rules {
+		pattern, err := regexp.Compile(custom.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction rule %q: %w", custom.Name, err)
+		}
+		name := custom.Name
+		if name == "" {
+			name = "custom"
+		}
+		r.rules = append(r.rules, Rule{Name: name, Pattern: pattern})
+	}
+
+	return r, nil
+}
+
+// Redact returns text with all detected secrets replaced and the number of replacements
+func (r *Redactor) Redact(text string) (string, int) {
+	if r == nil || text == "" {
+		return text, 0
+	}
+
+	count := 0
+	for _, rule := range r.rules {
+		var n int
+		text, n = applyRule(text, rule)
+		count += n
+	}
+
+	if r.entropy {
+		text = entropyCandidate.ReplaceAllStringFunc(text, func(match string) string {
+			if !isHighEntropy(match) {
+				return match
+			}
+			count++
+			return placeholder(entropyDetectorName)
+		})
+	}
+
+	return text, count
+}
+
+func applyRule(text string, rule Rule) (string, int) {
+	matches := rule.Pattern.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text, 0
+	}
+
+	var b strings.Builder
+	last := 0
+	count := 0
+	for _, m := range matches {
+		start, end := m[2*rule.Group], m[2*rule.Group+1]
+		if start < 0 {
+			continue
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(placeholder(rule.Name))
+		last = end
+		count++
+	}
+	b.WriteString(text[last:])
+
+	return b.String(), count
+}
+
+func placeholder(name string) string {
+	return "[REDACTED:" + name + "]"
+}
+
+// isHighEntropy reports whether s looks like a random token rather than a word or identifier
+func isHighEntropy(s string) bool {
+	if len(s) < minEntropyLength {
+		return false
+	}
+
+	var hasUpper, hasLower, hasDigit bool
+	for _, c := range s {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			hasUpper = true
+		case c >= 'a' && c <= 'z':
+			hasLower = true
+		case c >= '0' && c <= '9':
+			hasDigit = true
+		}
+	}
+	if !hasUpper || !hasLower || !hasDigit {
+		return false
+	}
+
+	return shannonEntropy(s) >= entropyThreshold
+}
+
+func shannonEntropy(s string) float64 {
+	freq := make(map[rune]int)
+	for _, c := range s {
+		freq[c]++
+	}
+
+	n := float64(len(s))
+	entropy := 0.0
+	for _, count := range freq {
+		p := float64(count) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
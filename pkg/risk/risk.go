@@ -0,0 +1,75 @@
+// Package risk flags shell commands run by agents that deserve a closer look,
+// such as recursive deletes, piping downloads into a shell or reading credential
+// files. It combines built-in patterns with user-defined regular expressions.
+package risk
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/hirosassa/tapline/pkg/config"
+)
+
+// Pattern is a named command risk pattern
+type Pattern struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+var builtinPatterns = []Pattern{
+	{Name: "recursive_delete", Pattern: regexp.MustCompile(`(?i)\brm\s+(?:-\S+\s+)*(?:-[a-z]*(?:rf|fr)[a-z]*|-[a-z]*r[a-z]*\s+(?:-\S+\s+)*-[a-z]*f[a-z]*|-[a-z]*f[a-z]*\s+(?:-\S+\s+)*-[a-z]*r[a-z]*|--recursive\s+--force|--force\s+--recursive)(?:\s|$)`)},
+	{Name: "pipe_to_shell", Pattern: regexp.MustCompile(`\b(?:curl|wget|fetch)\b[^|;&]*\|\s*(?:sudo\s+)?(?:ba|z|da|k|fi)?sh\b|\b(?:ba|z)?sh\s+(?:-c\s+)?["']?\$?<?\(\s*(?:curl|wget)\b`)},
+	{Name: "credential_file", Pattern: regexp.MustCompile(`\.ssh/id_[A-Za-z0-9_]+|\.aws/credentials|\.netrc\b|\.npmrc\b|\.pypirc\b|\.docker/config\.json|\.kube/config|\.git-credentials|\.gnupg/|/etc/shadow\b|(?:^|[\s/'"=])\.env(?:\.[A-Za-z0-9_]+)?(?:$|[\s'";|&])`)},
+	{Name: "privilege_escalation", Pattern: regexp.MustCompile(`(?:^|[;&|(]\s*)(?:sudo|doas|su)\s`)},
+	{Name: "force_push", Pattern: regexp.MustCompile(`\bgit\s+push\b[^;&|]*\s(?:--force(?:-with-lease)?|-f)\b`)},
+	{Name: "disk_overwrite", Pattern: regexp.MustCompile(`\bdd\b[^;&|]*\bof=/dev/|\bmkfs(?:\.[a-z0-9]+)?\s`)},
+	{Name: "world_writable", Pattern: regexp.MustCompile(`\bchmod\s+(?:-\S+\s+)*(?:0?777|a\+rwx|o\+w)\b`)},
+}
+
+// Classifier matches commands against risk patterns
+type Classifier struct {
+	patterns []Pattern
+}
+
+// New creates a Classifier from configuration
+func New(cfg config.CommandsConfig) (*Classifier, error) {
+	c := &Classifier{}
+
+	for _, pattern := range builtinPatterns {
+		if slices.Contains(cfg.DisableBuiltin, pattern.Name) {
+			continue
+		}
+		c.patterns = append(c.patterns, pattern)
+	}
+
+	for _, custom := range cfg.RiskPatterns {
+		pattern, err := regexp.Compile(custom.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid risk pattern %q: %w", custom.Name, err)
+		}
+		name := custom.Name
+		if name == "" {
+			name = "custom"
+		}
+		c.patterns = append(c.patterns, Pattern{Name: name, Pattern: pattern})
+	}
+
+	return c, nil
+}
+
+// Classify returns the names of the patterns command matches, in pattern order.
+// An empty result means the command is not considered risky.
+func (c *Classifier) Classify(command string) []string {
+	if c == nil || command == "" {
+		return nil
+	}
+
+	var matched []string
+	for _, pattern := range c.patterns {
+		if pattern.Pattern.MatchString(command) && !slices.Contains(matched, pattern.Name) {
+			matched = append(matched, pattern.Name)
+		}
+	}
+	return matched
+}
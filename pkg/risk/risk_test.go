@@ -0,0 +1,121 @@
+package risk
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/hirosassa/tapline/pkg/config"
+)
+
+func newClassifier(t *testing.T, cfg config.CommandsConfig) *Classifier {
+	t.Helper()
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create classifier: %v", err)
+	}
+	return c
+}
+
+func TestClassify_BuiltinPatterns(t *testing.T) {
+	c := newClassifier(t, config.CommandsConfig{})
+
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"rm -rf /tmp/build", "recursive_delete"},
+		{"rm -fr node_modules", "recursive_delete"},
+		{"rm -r -f dist", "recursive_delete"},
+		{"rm --recursive --force out", "recursive_delete"},
+		{"curl -fsSL https://example.com/install.sh | sh", "pipe_to_shell"},
+		{"wget -qO- https://example.com/x | sudo bash", "pipe_to_shell"},
+		{"bash <(curl -s https://example.com/x)", "pipe_to_shell"},
+		{"cat ~/.ssh/id_ed25519", "credential_file"},
+		{"cat ~/.aws/credentials", "credential_file"},
+		{"grep TOKEN .env", "credential_file"},
+		{"source ./.env.local && make", "credential_file"},
+		{"sudo apt-get install jq", "privilege_escalation"},
+		{"make && sudo make install", "privilege_escalation"},
+		{"git push --force origin main", "force_push"},
+		{"git push -f", "force_push"},
+		{"dd if=image.iso of=/dev/sda bs=4M", "disk_overwrite"},
+		{"chmod -R 777 /var/www", "world_writable"},
+	}
+
+	for _, tt := range tests {
+		if got := c.Classify(tt.command); !slices.Contains(got, tt.want) {
+			t.Errorf("Classify(%q) = %v, want %s", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestClassify_SafeCommands(t *testing.T) {
+	c := newClassifier(t, config.CommandsConfig{})
+
+	for _, command := range []string{
+		"ls -la",
+		"go test ./...",
+		"rm -f build.log",
+		"rm -r empty_dir",
+		"curl -o install.sh https://example.com/install.sh",
+		"git push origin feature",
+		"cat README.md",
+		"grep -r environment docs/",
+		"chmod 644 config.json",
+		"echo pseudo-code",
+	} {
+		if got := c.Classify(command); len(got) != 0 {
+			t.Errorf("Classify(%q) = %v, want no risks", command, got)
+		}
+	}
+}
+
+func TestClassify_MultipleRisks(t *testing.T) {
+	c := newClassifier(t, config.CommandsConfig{})
+
+	got := c.Classify("sudo rm -rf / && curl https://x.example | sh")
+	want := []string{"recursive_delete", "pipe_to_shell", "privilege_escalation"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Classify() = %v, want %v", got, want)
+	}
+}
+
+func TestClassify_CustomPatterns(t *testing.T) {
+	c := newClassifier(t, config.CommandsConfig{
+		RiskPatterns: []config.RiskPattern{
+			{Name: "terraform_destroy", Pattern: `\bterraform\s+destroy\b`},
+			{Pattern: `kubectl\s+delete`},
+		},
+	})
+
+	if got := c.Classify("terraform destroy -auto-approve"); !slices.Equal(got, []string{"terraform_destroy"}) {
+		t.Errorf("Expected terraform_destroy, got %v", got)
+	}
+	if got := c.Classify("kubectl delete ns prod"); !slices.Equal(got, []string{"custom"}) {
+		t.Errorf("Expected unnamed pattern to be reported as custom, got %v", got)
+	}
+}
+
+func TestNew_InvalidPattern(t *testing.T) {
+	_, err := New(config.CommandsConfig{
+		RiskPatterns: []config.RiskPattern{{Name: "bad", Pattern: "("}},
+	})
+	if err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+}
+
+func TestClassify_DisableBuiltin(t *testing.T) {
+	c := newClassifier(t, config.CommandsConfig{DisableBuiltin: []string{"privilege_escalation"}})
+
+	if got := c.Classify("sudo systemctl restart nginx"); len(got) != 0 {
+		t.Errorf("Expected disabled pattern to be skipped, got %v", got)
+	}
+}
+
+func TestClassify_NilClassifier(t *testing.T) {
+	var c *Classifier
+	if got := c.Classify("rm -rf /"); got != nil {
+		t.Errorf("Expected nil classifier to report nothing, got %v", got)
+	}
+}
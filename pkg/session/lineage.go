@@ -0,0 +1,119 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Environment variables that carry session ancestry to child processes
+const (
+	EnvSessionID       = "TAPLINE_SESSION_ID"
+	EnvParentSessionID = "TAPLINE_PARENT_SESSION_ID"
+)
+
+// Lineage is the session ancestry inherited from the environment. SessionID is
+// the session of the nearest tapline-logged ancestor process and ParentSessionID
+// is that session's own parent.
+type Lineage struct {
+	SessionID       string
+	ParentSessionID string
+}
+
+// LineageFromEnv reads the inherited session ancestry
+func LineageFromEnv() Lineage {
+	return Lineage{
+		SessionID:       os.Getenv(EnvSessionID),
+		ParentSessionID: os.Getenv(EnvParentSessionID),
+	}
+}
+
+// Nested reports whether this process runs inside another tapline-logged session
+func (l Lineage) Nested() bool {
+	return l.SessionID != ""
+}
+
+// ParentOf returns the parent of sessionID: the inherited session when this
+// process logs a different session, otherwise the inherited session's parent
+func (l Lineage) ParentOf(sessionID string) string {
+	if sessionID == "" {
+		return ""
+	}
+	if l.SessionID != "" && l.SessionID != sessionID {
+		return l.SessionID
+	}
+	if l.ParentSessionID != sessionID {
+		return l.ParentSessionID
+	}
+	return ""
+}
+
+// Env returns environ with the ancestry variables set for children of sessionID
+func (l Lineage) Env(environ []string, sessionID string) []string {
+	env := make([]string, 0, len(environ)+2)
+	for _, kv := range environ {
+		if strings.HasPrefix(kv, EnvSessionID+"=") || strings.HasPrefix(kv, EnvParentSessionID+"=") {
+			continue
+		}
+		env = append(env, kv)
+	}
+
+	env = append(env, EnvSessionID+"="+sessionID)
+	if parent := l.ParentOf(sessionID); parent != "" {
+		env = append(env, EnvParentSessionID+"="+parent)
+	}
+	return env
+}
+
+// nestedSession is the state kept for a service's session inside a parent session
+type nestedSession struct {
+	SessionID string `json:"session_id"`
+}
+
+func nestedStateName(service, parent string) string {
+	return "nested-" + service + "-" + parent
+}
+
+// SessionFor returns the session service should log under. Top-level runs use the
+// shared session file. Runs nested in another tapline-logged session keep one
+// session per service and parent, so they neither merge into nor replace the
+// parent's session.
+func (m *Manager) SessionFor(service string, lineage Lineage) (string, error) {
+	if !lineage.Nested() {
+		return m.GetSessionID()
+	}
+
+	var state nestedSession
+	if err := m.LoadState(nestedStateName(service, lineage.SessionID), &state); err != nil {
+		return "", err
+	}
+	if state.SessionID == "" {
+		return "", fmt.Errorf("no active session found")
+	}
+	return state.SessionID, nil
+}
+
+// SetSessionFor stores the session service logs under; see SessionFor
+func (m *Manager) SetSessionFor(service string, lineage Lineage, sessionID string) error {
+	if !lineage.Nested() {
+		return m.SetSessionID(sessionID)
+	}
+	if sessionID == "" {
+		return fmt.Errorf("session ID cannot be empty")
+	}
+	return m.SaveState(nestedStateName(service, lineage.SessionID), nestedSession{SessionID: sessionID})
+}
+
+// ClearSessionFor ends the session service logs under; see SessionFor
+func (m *Manager) ClearSessionFor(service string, lineage Lineage) error {
+	if !lineage.Nested() {
+		return m.ClearSession()
+	}
+	return m.DeleteState(nestedStateName(service, lineage.SessionID))
+}
+
+// ClearNestedSessions removes the nested sessions of every service kept for
+// parent, once parent has ended
+func (m *Manager) ClearNestedSessions(parent string) error {
+	return m.deleteMatching("nested-*-" + safeStateName(parent))
+}
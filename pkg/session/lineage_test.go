@@ -0,0 +1,126 @@
+package session
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLineageFromEnv(t *testing.T) {
+	t.Setenv(EnvSessionID, "claude-1")
+	t.Setenv(EnvParentSessionID, "root")
+
+	lineage := LineageFromEnv()
+	if lineage.SessionID != "claude-1" || lineage.ParentSessionID != "root" || !lineage.Nested() {
+		t.Errorf("Unexpected lineage: %+v", lineage)
+	}
+}
+
+func TestLineage_ParentOf(t *testing.T) {
+	lineage := Lineage{SessionID: "claude-1", ParentSessionID: "root"}
+
+	tests := []struct {
+		sessionID string
+		want      string
+	}{
+		{"codex-1", "claude-1"},
+		{"claude-1", "root"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := lineage.ParentOf(tt.sessionID); got != tt.want {
+			t.Errorf("ParentOf(%q) = %q, want %q", tt.sessionID, got, tt.want)
+		}
+	}
+
+	if got := (Lineage{}).ParentOf("codex-1"); got != "" {
+		t.Errorf("Expected no parent at top level, got %q", got)
+	}
+}
+
+func TestLineage_Env(t *testing.T) {
+	lineage := Lineage{SessionID: "claude-1"}
+	environ := []string{"PATH=/bin", EnvSessionID + "=claude-1"}
+
+	env := lineage.Env(environ, "codex-1")
+
+	want := []string{"PATH=/bin", EnvSessionID + "=codex-1", EnvParentSessionID + "=claude-1"}
+	if !slices.Equal(env, want) {
+		t.Errorf("Env() = %v, want %v", env, want)
+	}
+
+	env = Lineage{}.Env([]string{"PATH=/bin"}, "gemini-1")
+	if !slices.Equal(env, []string{"PATH=/bin", EnvSessionID + "=gemini-1"}) {
+		t.Errorf("Expected only the session variable at top level, got %v", env)
+	}
+}
+
+func TestManager_SessionFor(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	mgr, err := NewManager()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	if err := mgr.SetSessionID("claude-1"); err != nil {
+		t.Fatalf("Failed to set session ID: %v", err)
+	}
+
+	nested := Lineage{SessionID: "claude-1"}
+	if _, err := mgr.SessionFor("codex-cli", nested); err == nil {
+		t.Error("Expected no nested session before one is set")
+	}
+
+	if err := mgr.SetSessionFor("codex-cli", nested, "codex-1"); err != nil {
+		t.Fatalf("Failed to set nested session: %v", err)
+	}
+	if got, err := mgr.SessionFor("codex-cli", nested); err != nil || got != "codex-1" {
+		t.Errorf("Expected nested session codex-1, got %q (%v)", got, err)
+	}
+
+	// The parent's session file is untouched
+	if got, _ := mgr.SessionFor("claude-code", Lineage{}); got != "claude-1" {
+		t.Errorf("Expected top-level session claude-1, got %q", got)
+	}
+
+	if err := mgr.ClearSessionFor("codex-cli", nested); err != nil {
+		t.Fatalf("Failed to clear nested session: %v", err)
+	}
+	if _, err := mgr.SessionFor("codex-cli", nested); err == nil {
+		t.Error("Expected nested session to be cleared")
+	}
+	if !mgr.HasActiveSession() {
+		t.Error("Expected parent session to remain active")
+	}
+}
+
+func TestManager_ClearNestedSessions(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	mgr, err := NewManager()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	for _, nested := range []struct{ service, parent, session string }{
+		{"codex-cli", "claude-1", "codex-1"},
+		{"gemini-cli", "claude-1", "gemini-1"},
+		{"codex-cli", "claude-2", "codex-2"},
+	} {
+		if err := mgr.SetSessionFor(nested.service, Lineage{SessionID: nested.parent}, nested.session); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := mgr.ClearNestedSessions("claude-1"); err != nil {
+		t.Fatalf("Failed to clear nested sessions: %v", err)
+	}
+	for _, service := range []string{"codex-cli", "gemini-cli"} {
+		if _, err := mgr.SessionFor(service, Lineage{SessionID: "claude-1"}); err == nil {
+			t.Errorf("Expected the %s session nested in claude-1 to be cleared", service)
+		}
+	}
+	if got, err := mgr.SessionFor("codex-cli", Lineage{SessionID: "claude-2"}); err != nil || got != "codex-2" {
+		t.Errorf("Expected the session nested in claude-2 to remain, got %q (%v)", got, err)
+	}
+}
@@ -1,18 +1,31 @@
 // Package session provides session ID management and persistence.
-// It stores session IDs in ~/.tapline/session_id for tracking conversation sessions.
+// It stores session IDs in ~/.tapline/session_id for tracking conversation sessions,
+// along with small named state files shared between tapline invocations.
 package session
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 const (
 	sessionDirName  = ".tapline"
 	sessionFileName = "session_id"
+	stateDirName    = "state"
+
+	lockRetryInterval = 10 * time.Millisecond
+	lockTimeout       = 2 * time.Second
+	// Locks older than this are assumed to belong to a killed process
+	lockStaleAfter = 10 * time.Second
 )
 
+// ErrLockTimeout is returned when a state lock cannot be acquired in time
+var ErrLockTimeout = errors.New("timed out waiting for state lock")
+
 // Manager handles session ID persistence
 type Manager struct {
 	sessionDir  string
@@ -88,3 +101,135 @@ func (m *Manager) HasActiveSession() bool {
 	_, err := m.GetSessionID()
 	return err == nil
 }
+
+// statePath returns the path of a named state file. Names often embed IDs taken
+// from hook payloads, so anything but [A-Za-z0-9._-] is replaced to keep the
+// file inside the state directory.
+func (m *Manager) statePath(name string) string {
+	return filepath.Join(m.sessionDir, stateDirName, safeStateName(name)+".json")
+}
+
+func safeStateName(name string) string {
+	safe := []byte(name)
+	for i, c := range safe {
+		isSafe := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '.' || c == '_' || c == '-'
+		if !isSafe {
+			safe[i] = '_'
+		}
+	}
+	return string(safe)
+}
+
+// LoadState reads the named JSON state into v. A missing state leaves v untouched.
+func (m *Manager) LoadState(name string, v any) error {
+	data, err := os.ReadFile(m.statePath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read state %s: %w", name, err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse state %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// SaveState atomically writes v as the named JSON state
+func (m *Manager) SaveState(name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode state %s: %w", name, err)
+	}
+
+	path := m.statePath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write state %s: %w", name, err)
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck // Already renamed on success
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() //nolint:errcheck // The write error is more relevant
+		return fmt.Errorf("failed to write state %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state %s: %w", name, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write state %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// DeleteState removes the named state. A missing state is not an error.
+func (m *Manager) DeleteState(name string) error {
+	if err := os.Remove(m.statePath(name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete state %s: %w", name, err)
+	}
+	return nil
+}
+
+// DeleteStates removes every state whose name starts with prefix
+func (m *Manager) DeleteStates(prefix string) error {
+	return m.deleteMatching(safeStateName(prefix) + "*")
+}
+
+// deleteMatching removes the states whose safe names match the glob pattern.
+// Safe names contain no glob metacharacters, so only * in pattern is special.
+func (m *Manager) deleteMatching(pattern string) error {
+	paths, err := filepath.Glob(filepath.Join(m.sessionDir, stateDirName, pattern+".json"))
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) && firstErr == nil {
+			firstErr = fmt.Errorf("failed to delete state %s: %w", filepath.Base(path), err)
+		}
+	}
+	return firstErr
+}
+
+// WithLock runs fn while holding an exclusive lock on the named state.
+// Hooks may fire concurrently, so read-modify-write of shared state must hold the lock.
+func (m *Manager) WithLock(name string, fn func() error) error {
+	lockPath := m.statePath(name) + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0o750); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close() //nolint:errcheck // The lock is the file's existence, not its content
+			break
+		}
+		if !os.IsExist(err) {
+			return fmt.Errorf("failed to acquire state lock: %w", err)
+		}
+
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > lockStaleAfter {
+			os.Remove(lockPath) //nolint:errcheck // Another process may have removed it first
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return ErrLockTimeout
+		}
+		time.Sleep(lockRetryInterval)
+	}
+	defer os.Remove(lockPath) //nolint:errcheck // A leftover lock expires as stale
+
+	return fn()
+}
@@ -1,6 +1,9 @@
 package session
 
 import (
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/google/uuid"
@@ -106,3 +109,135 @@ func TestManager_ConcurrentSessions(t *testing.T) {
 		t.Error("Second session should have overwritten the first")
 	}
 }
+
+func TestManager_State(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	mgr, err := NewManager()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	type counter struct {
+		Count int `json:"count"`
+	}
+
+	var state counter
+	if err := mgr.LoadState("counter", &state); err != nil {
+		t.Fatalf("Expected missing state to load cleanly, got %v", err)
+	}
+	if state.Count != 0 {
+		t.Errorf("Expected zero state, got %d", state.Count)
+	}
+
+	if err := mgr.SaveState("counter", counter{Count: 3}); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	if err := mgr.LoadState("counter", &state); err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	if state.Count != 3 {
+		t.Errorf("Expected count 3, got %d", state.Count)
+	}
+}
+
+func TestManager_DeleteState(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	mgr, err := NewManager()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	for _, name := range []string{"tools-s1", "transcript-s1", "transcript-s1-agent", "transcript-s2"} {
+		if err := mgr.SaveState(name, map[string]int{"n": 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := mgr.DeleteState("tools-s1"); err != nil {
+		t.Fatalf("Failed to delete state: %v", err)
+	}
+	if err := mgr.DeleteState("tools-s1"); err != nil {
+		t.Errorf("Expected deleting a missing state to succeed, got %v", err)
+	}
+	if err := mgr.DeleteStates("transcript-s1"); err != nil {
+		t.Fatalf("Failed to delete states: %v", err)
+	}
+
+	for name, want := range map[string]bool{"tools-s1": false, "transcript-s1": false, "transcript-s1-agent": false, "transcript-s2": true} {
+		state := map[string]int{}
+		if err := mgr.LoadState(name, &state); err != nil {
+			t.Fatal(err)
+		}
+		if got := state["n"] == 1; got != want {
+			t.Errorf("State %s present = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestManager_WithLock(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	mgr, err := NewManager()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	type counter struct {
+		Count int `json:"count"`
+	}
+
+	const workers = 8
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := mgr.WithLock("counter", func() error {
+				var state counter
+				if err := mgr.LoadState("counter", &state); err != nil {
+					return err
+				}
+				state.Count++
+				return mgr.SaveState("counter", state)
+			})
+			if err != nil {
+				t.Errorf("WithLock failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var state counter
+	if err := mgr.LoadState("counter", &state); err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	if state.Count != workers {
+		t.Errorf("Expected count %d, got %d", workers, state.Count)
+	}
+}
+
+func TestManager_StateNameIsSanitized(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	mgr, err := NewManager()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	if err := mgr.SaveState("../../escape", map[string]int{"n": 1}); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(home, "escape.json")); err == nil {
+		t.Error("State file escaped the state directory")
+	}
+
+	var state map[string]int
+	if err := mgr.LoadState("../../escape", &state); err != nil || state["n"] != 1 {
+		t.Errorf("Expected state to round-trip, got %v (%v)", state, err)
+	}
+}
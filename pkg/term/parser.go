@@ -0,0 +1,89 @@
+package term
+
+import "unicode/utf8"
+
+type parserState int
+
+const (
+	stateGround parserState = iota
+	stateEscape
+	stateEscapeIntermediate
+	stateCSI
+	stateString
+	stateStringEscape
+)
+
+// parser splits terminal output into text and control sequences. Sequences and
+// UTF-8 characters split across writes are carried over to the next one.
+type parser struct {
+	state   parserState
+	params  []byte
+	partial []byte // An incomplete UTF-8 sequence from the previous write
+}
+
+// parse calls text with each rune outside of escape sequences, control
+// characters included, and csi with the final byte and parameters of each
+// control sequence. Other escape sequences and strings are dropped.
+func (p *parser) parse(data []byte, text func(rune), csi func(final rune, params string)) {
+	if len(p.partial) > 0 {
+		data = append(p.partial, data...)
+		p.partial = nil
+	}
+
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && !utf8.FullRune(data) {
+			p.partial = append([]byte(nil), data...)
+			return
+		}
+		data = data[size:]
+		p.feed(r, text, csi)
+	}
+}
+
+func (p *parser) feed(r rune, text func(rune), csi func(final rune, params string)) {
+	switch p.state {
+	case stateEscape:
+		switch {
+		case r == '[':
+			p.state = stateCSI
+			p.params = p.params[:0]
+		case r == ']' || r == 'P' || r == '_' || r == '^' || r == 'X':
+			// OSC, DCS, APC, PM and SOS strings run to BEL or ST
+			p.state = stateString
+		case r >= 0x20 && r <= 0x2f:
+			p.state = stateEscapeIntermediate
+		default:
+			p.state = stateGround
+		}
+	case stateEscapeIntermediate:
+		if r < 0x20 || r > 0x2f {
+			p.state = stateGround
+		}
+	case stateCSI:
+		switch {
+		case r >= 0x40 && r <= 0x7e:
+			p.state = stateGround
+			csi(r, string(p.params))
+		case r < 0x20 || r > 0x3f:
+			p.state = stateGround
+		default:
+			p.params = append(p.params, byte(r))
+		}
+	case stateString:
+		switch r {
+		case 0x07:
+			p.state = stateGround
+		case 0x1b:
+			p.state = stateStringEscape
+		}
+	case stateStringEscape:
+		p.state = stateGround
+	default:
+		if r == 0x1b {
+			p.state = stateEscape
+			return
+		}
+		text(r)
+	}
+}
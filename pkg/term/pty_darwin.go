@@ -0,0 +1,44 @@
+package term
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	ioctlGetTermios = syscall.TIOCGETA
+	ioctlSetTermios = syscall.TIOCSETA
+)
+
+// openPTY opens a pseudo-terminal pair through /dev/ptmx
+func openPTY() (pty, tty *os.File, err error) {
+	pty, err = os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var name [128]byte
+	for _, req := range []uintptr{syscall.TIOCPTYGRANT, syscall.TIOCPTYUNLK} {
+		if err := ioctl(pty, req, nil); err != nil {
+			pty.Close() //nolint:errcheck // The setup error is more relevant
+			return nil, nil, err
+		}
+	}
+	if err := ioctl(pty, syscall.TIOCPTYGNAME, unsafe.Pointer(&name)); err != nil {
+		pty.Close() //nolint:errcheck // The setup error is more relevant
+		return nil, nil, err
+	}
+
+	end := bytes.IndexByte(name[:], 0)
+	if end < 0 {
+		end = len(name)
+	}
+	tty, err = os.OpenFile(string(name[:end]), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		pty.Close() //nolint:errcheck // The setup error is more relevant
+		return nil, nil, err
+	}
+	return pty, tty, nil
+}
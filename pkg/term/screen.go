@@ -0,0 +1,148 @@
+package term
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Screen renders terminal output to the text it leaves behind. It follows the
+// cursor movement and erasing used by line-oriented programs, so text redrawn
+// in place, such as spinners and re-rendered prompts, appears once in its final
+// state. Colors, titles and other control sequences are dropped.
+//
+// Screen has no fixed height: row 0 is where output started, and absolute
+// cursor positions are taken relative to it.
+type Screen struct {
+	lines    [][]rune
+	row, col int
+	parser   parser
+}
+
+// Write feeds terminal output to the screen; it never fails
+func (s *Screen) Write(p []byte) (int, error) {
+	s.parser.parse(p, s.ground, s.csi)
+	return len(p), nil
+}
+
+// Text returns the rendered lines, without trailing spaces and surrounding blank lines
+func (s *Screen) Text() string {
+	lines := make([]string, len(s.lines))
+	for i, line := range s.lines {
+		lines[i] = strings.TrimRight(string(line), " ")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+func (s *Screen) ground(r rune) {
+	switch r {
+	case '\n':
+		s.row++
+		s.col = 0
+	case '\r':
+		s.col = 0
+	case '\b':
+		s.col = max(s.col-1, 0)
+	case '\t':
+		s.col = (s.col/8 + 1) * 8
+	default:
+		if r < 0x20 || (r >= 0x7f && r < 0xa0) {
+			return
+		}
+		line := s.line(s.row)
+		for len(*line) <= s.col {
+			*line = append(*line, ' ')
+		}
+		(*line)[s.col] = r
+		s.col++
+	}
+}
+
+// line returns row, adding blank lines up to it as needed
+func (s *Screen) line(row int) *[]rune {
+	for len(s.lines) <= row {
+		s.lines = append(s.lines, nil)
+	}
+	return &s.lines[row]
+}
+
+// csi applies a control sequence; those that do not move the cursor or erase are ignored
+func (s *Screen) csi(final rune, params string) {
+	if strings.HasPrefix(params, "?") || strings.HasPrefix(params, ">") || strings.HasPrefix(params, "=") {
+		return
+	}
+	fields := strings.Split(params, ";")
+	param := func(i, def int) int {
+		if i >= len(fields) {
+			return def
+		}
+		n, err := strconv.Atoi(fields[i])
+		if err != nil || n == 0 {
+			return def
+		}
+		return n
+	}
+
+	switch final {
+	case 'A':
+		s.row = max(s.row-param(0, 1), 0)
+	case 'B':
+		s.row += param(0, 1)
+	case 'C':
+		s.col += param(0, 1)
+	case 'D':
+		s.col = max(s.col-param(0, 1), 0)
+	case 'E':
+		s.row += param(0, 1)
+		s.col = 0
+	case 'F':
+		s.row = max(s.row-param(0, 1), 0)
+		s.col = 0
+	case 'G':
+		s.col = param(0, 1) - 1
+	case 'd':
+		s.row = param(0, 1) - 1
+	case 'H', 'f':
+		s.row = param(0, 1) - 1
+		s.col = param(1, 1) - 1
+	case 'J':
+		s.eraseDisplay(param(0, 0))
+	case 'K':
+		s.eraseLine(s.row, param(0, 0))
+	}
+}
+
+func (s *Screen) eraseDisplay(mode int) {
+	switch mode {
+	case 0:
+		s.eraseLine(s.row, 0)
+		if s.row+1 < len(s.lines) {
+			s.lines = s.lines[:s.row+1]
+		}
+	case 1:
+		for row := 0; row < s.row && row < len(s.lines); row++ {
+			s.lines[row] = nil
+		}
+		s.eraseLine(s.row, 1)
+	default:
+		s.lines = nil
+	}
+}
+
+func (s *Screen) eraseLine(row, mode int) {
+	if row >= len(s.lines) {
+		return
+	}
+	line := s.lines[row]
+	switch mode {
+	case 0:
+		if s.col < len(line) {
+			s.lines[row] = line[:s.col]
+		}
+	case 1:
+		for col := 0; col <= s.col && col < len(line); col++ {
+			line[col] = ' '
+		}
+	default:
+		s.lines[row] = nil
+	}
+}
@@ -0,0 +1,42 @@
+package term
+
+import "testing"
+
+func TestScreen(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{"plain lines", []string{"hello\r\nworld\r\n"}, "hello\nworld"},
+		{"colors", []string{"\x1b[1;32mgreen\x1b[0m text"}, "green text"},
+		{"title", []string{"\x1b]0;Gemini\x07ready", "\x1b]2;x\x1b\\!"}, "ready!"},
+		{"spinner", []string{"⠋ Thinking\r⠙ Thinking\r\x1b[KDone"}, "Done"},
+		{
+			"redrawn frame",
+			[]string{"> typing\r\nfooter", "\x1b[2K\x1b[1A\x1b[2K\x1b[G", "answer\r\nfooter"},
+			"answer\nfooter",
+		},
+		{"clear screen", []string{"old\r\n\x1b[2J\x1b[Hnew"}, "new"},
+		{"erase below", []string{"a\r\nb\r\nc\x1b[2A\r\x1b[J"}, ""},
+		{"split rune", []string{"caf\xc3", "\xa9"}, "café"},
+		{"cursor forward", []string{"a\x1b[3Cb"}, "a   b"},
+		{"private modes", []string{"\x1b[?25l\x1b[?2004hvisible\x1b[?25h"}, "visible"},
+		{"charset", []string{"\x1b(Bok"}, "ok"},
+		{"blank surround", []string{"\r\n\r\n  text  \r\n\r\n"}, "  text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var screen Screen
+			for _, w := range tt.writes {
+				if n, err := screen.Write([]byte(w)); n != len(w) || err != nil {
+					t.Fatalf("Write returned %d, %v", n, err)
+				}
+			}
+			if got := screen.Text(); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
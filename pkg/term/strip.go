@@ -0,0 +1,30 @@
+package term
+
+import "strings"
+
+// Stripper removes escape sequences and control characters from terminal
+// output, keeping its text, newlines and tabs. Unlike Screen it does not follow
+// the cursor, so it suits output that is written line by line and has to be
+// read as it arrives.
+type Stripper struct {
+	parser parser
+}
+
+// Strip returns the text of the next piece of output. A sequence split across
+// pieces is removed as a whole.
+func (s *Stripper) Strip(p []byte) string {
+	var text strings.Builder
+	s.parser.parse(p, func(r rune) {
+		if r >= 0x20 && (r < 0x7f || r >= 0xa0) || r == '\n' || r == '\t' {
+			text.WriteRune(r)
+		}
+	}, func(rune, string) {})
+	return text.String()
+}
+
+// Strip returns the text of terminal output with escape sequences and control
+// characters other than newlines and tabs removed
+func Strip(s string) string {
+	var stripper Stripper
+	return stripper.Strip([]byte(s))
+}
@@ -0,0 +1,39 @@
+package term
+
+import "testing"
+
+func TestStripper(t *testing.T) {
+	tests := []struct {
+		name   string
+		pieces []string
+		want   string
+	}{
+		{"plain", []string{"hello\nworld\n"}, "hello\nworld\n"},
+		{"colors", []string{"\x1b[1;32mgreen\x1b[0m text"}, "green text"},
+		{"split sequence", []string{"a\x1b[3", "1mb\x1b", "[0mc"}, "abc"},
+		{"title", []string{"\x1b]0;Gemini\x07ready", "\x1b]2;x\x1b\\!"}, "ready!"},
+		{"line endings", []string{"one\r\ntwo\r\n"}, "one\ntwo\n"},
+		{"control characters", []string{"bell\a\tbs\b"}, "bell\tbs"},
+		{"split rune", []string{"caf\xc3", "\xa9"}, "café"},
+		{"cursor movement", []string{"\x1b[2K\x1b[1Gdone"}, "done"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stripper Stripper
+			var got string
+			for _, piece := range tt.pieces {
+				got += stripper.Strip([]byte(piece))
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStrip(t *testing.T) {
+	if got := Strip("\x1b[31merror\x1b[0m\n"); got != "error\n" {
+		t.Errorf("Expected %q, got %q", "error\n", got)
+	}
+}
@@ -0,0 +1,9 @@
+// Package term provides the terminal handling needed to run a program under a
+// pseudo-terminal: opening the PTY, raw mode, window sizes and rendering of the
+// program's output to plain text.
+package term
+
+import "errors"
+
+// ErrUnsupported is returned on platforms without pseudo-terminal support
+var ErrUnsupported = errors.New("pseudo-terminals are not supported on this platform")
@@ -0,0 +1,57 @@
+//go:build !linux && !darwin
+
+package term
+
+import (
+	"os"
+	"os/exec"
+)
+
+// State is the terminal mode to restore after MakeRaw
+type State struct{}
+
+// IsTerminal reports whether f is a terminal; always false on this platform
+func IsTerminal(*os.File) bool {
+	return false
+}
+
+// MakeRaw is not supported on this platform
+func MakeRaw(*os.File) (*State, error) {
+	return nil, ErrUnsupported
+}
+
+// Restore is not supported on this platform
+func Restore(*os.File, *State) error {
+	return ErrUnsupported
+}
+
+// GetSize is not supported on this platform
+func GetSize(*os.File) (rows, cols int, err error) {
+	return 0, 0, ErrUnsupported
+}
+
+// SetSize is not supported on this platform
+func SetSize(*os.File, int, int) error {
+	return ErrUnsupported
+}
+
+// IsForeground reports whether the calling process's group is the foreground
+// group of a terminal; always false on this platform
+func IsForeground(*os.File) bool {
+	return false
+}
+
+// SetForeground is not supported on this platform
+func SetForeground(*os.File) error {
+	return ErrUnsupported
+}
+
+// Start is not supported on this platform
+func Start(*exec.Cmd) (*os.File, error) {
+	return nil, ErrUnsupported
+}
+
+// WatchSize does nothing on this platform
+func WatchSize(_, _ *os.File) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,162 @@
+//go:build linux || darwin
+
+package term
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// State is the terminal mode to restore after MakeRaw
+type State struct {
+	termios syscall.Termios
+}
+
+type winsize struct {
+	Rows, Cols, X, Y uint16
+}
+
+func ioctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// IsTerminal reports whether f is a terminal
+func IsTerminal(f *os.File) bool {
+	var termios syscall.Termios
+	return ioctl(f, ioctlGetTermios, unsafe.Pointer(&termios)) == nil
+}
+
+// MakeRaw puts the terminal f into raw mode, so keystrokes reach the program
+// unprocessed, and returns the previous mode
+func MakeRaw(f *os.File) (*State, error) {
+	var state State
+	if err := ioctl(f, ioctlGetTermios, unsafe.Pointer(&state.termios)); err != nil {
+		return nil, err
+	}
+
+	// As cfmakeraw(3)
+	raw := state.termios
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Oflag &^= syscall.OPOST
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(f, ioctlSetTermios, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Restore returns the terminal f to a mode saved by MakeRaw
+func Restore(f *os.File, state *State) error {
+	return ioctl(f, ioctlSetTermios, unsafe.Pointer(&state.termios))
+}
+
+// GetSize returns the window size of the terminal f
+func GetSize(f *os.File) (rows, cols int, err error) {
+	var ws winsize
+	if err := ioctl(f, syscall.TIOCGWINSZ, unsafe.Pointer(&ws)); err != nil {
+		return 0, 0, err
+	}
+	return int(ws.Rows), int(ws.Cols), nil
+}
+
+// SetSize sets the window size of the terminal f
+func SetSize(f *os.File, rows, cols int) error {
+	ws := winsize{Rows: uint16(rows), Cols: uint16(cols)} //nolint:gosec // Terminal sizes fit in uint16
+	return ioctl(f, syscall.TIOCSWINSZ, unsafe.Pointer(&ws))
+}
+
+// IsForeground reports whether the calling process's group is the foreground
+// group of the terminal f, the one that receives keyboard signals
+func IsForeground(f *os.File) bool {
+	var pgid int32
+	if err := ioctl(f, syscall.TIOCGPGRP, unsafe.Pointer(&pgid)); err != nil {
+		return false
+	}
+	return int(pgid) == syscall.Getpgrp()
+}
+
+// SetForeground makes the calling process's group the foreground group of the
+// terminal f again, after a child group had it
+func SetForeground(f *os.File) error {
+	// A background group changing the foreground group is sent SIGTTOU
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+
+	pgid := int32(syscall.Getpgrp()) //nolint:gosec // pid_t is 32 bits
+	return ioctl(f, syscall.TIOCSPGRP, unsafe.Pointer(&pgid))
+}
+
+// Start starts cmd in a new session with a pseudo-terminal as its controlling
+// terminal and standard streams, and returns the PTY end of it
+func Start(cmd *exec.Cmd) (*os.File, error) {
+	pty, tty, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	defer tty.Close() //nolint:errcheck // The child holds its own copy
+
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, tty, tty
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0 // The child's stdin
+
+	if err := cmd.Start(); err != nil {
+		pty.Close() //nolint:errcheck // The start error is more relevant
+		return nil, err
+	}
+	return pty, nil
+}
+
+// WatchSize copies the window size of from to to now and whenever the window
+// is resized, until stop is called
+func WatchSize(from, to *os.File) (stop func()) {
+	resize := func() {
+		if rows, cols, err := GetSize(from); err == nil {
+			SetSize(to, rows, cols) //nolint:errcheck // A missed resize is corrected by the next one
+		}
+	}
+	resize()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-signals:
+				resize()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
@@ -0,0 +1,80 @@
+//go:build linux || darwin
+
+package term
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	cmd := exec.CommandContext(context.Background(), "sh", "-c", `test -t 0 && test -t 1 && echo "is a tty"`)
+	pty, err := Start(cmd)
+	if err != nil {
+		t.Fatalf("Failed to start under a PTY: %v", err)
+	}
+	defer pty.Close()
+
+	// Reading the PTY fails with EIO once the child has closed the terminal
+	output, _ := io.ReadAll(pty)
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("Expected success, got %v (output %q)", err, output)
+	}
+	if !strings.Contains(string(output), "is a tty") {
+		t.Errorf("Expected the child to run on a terminal, got %q", output)
+	}
+}
+
+func TestRawModeAndSize(t *testing.T) {
+	pty, tty, err := openPTY()
+	if err != nil {
+		t.Fatalf("Failed to open a PTY: %v", err)
+	}
+	defer pty.Close()
+	defer tty.Close()
+
+	if !IsTerminal(tty) {
+		t.Error("Expected the PTY to be a terminal")
+	}
+	file, err := os.CreateTemp(t.TempDir(), "plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if IsTerminal(file) {
+		t.Error("Expected a regular file not to be a terminal")
+	}
+
+	if err := SetSize(pty, 40, 120); err != nil {
+		t.Fatalf("Failed to set size: %v", err)
+	}
+	if rows, cols, err := GetSize(tty); err != nil || rows != 40 || cols != 120 {
+		t.Errorf("Expected 40x120, got %dx%d (%v)", rows, cols, err)
+	}
+
+	state, err := MakeRaw(tty)
+	if err != nil {
+		t.Fatalf("Failed to make raw: %v", err)
+	}
+	if err := Restore(tty, state); err != nil {
+		t.Errorf("Failed to restore: %v", err)
+	}
+}
+
+func TestIsForeground(t *testing.T) {
+	pty, tty, err := openPTY()
+	if err != nil {
+		t.Fatalf("Failed to open a PTY: %v", err)
+	}
+	defer pty.Close()
+	defer tty.Close()
+
+	// The test process does not control this terminal
+	if IsForeground(tty) {
+		t.Error("Expected not to be in the foreground of a terminal that is not ours")
+	}
+}
@@ -0,0 +1,307 @@
+// Package wrap describes how command-line AI tools run through tapline wrap are
+// logged: which arguments hold the prompt and where the response is in the
+// output. It combines built-in profiles with user-defined ones from the
+// configuration.
+package wrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hirosassa/tapline/pkg/config"
+	"github.com/hirosassa/tapline/pkg/term"
+)
+
+var builtinProfiles = map[string]config.WrapProfile{
+	"aider": {
+		PromptFlags: []string{"-m", "--message", "--msg"},
+		ModelFlags:  []string{"--model"},
+		ValueFlags: []string{
+			"--weak-model", "--editor-model", "--edit-format", "--editor-edit-format", "--chat-mode",
+			"--openai-api-base", "--map-tokens", "--reasoning-effort", "--thinking-tokens", "--timeout", "--encoding",
+			"-f", "--message-file", "--file", "--read", "--load", "-c", "--config", "--env-file",
+			"--model-settings-file", "--model-metadata-file", "--alias", "--lint-cmd", "--test-cmd",
+			"--input-history-file", "--chat-history-file", "--llm-history-file",
+		},
+		SecretFlags: []string{"--api-key", "--openai-api-key", "--anthropic-api-key", "--set-env"},
+		FileArgs:    true,
+		IgnoreLines: []string{
+			`^Aider v\d`,
+			`^(Main|Weak|Editor) model: `,
+			`^Model: `,
+			`^Git repo: `,
+			`^Repo-map: `,
+			`^Added .* to the chat\.$`,
+			`^Tokens: .* sent, .* received`,
+			`^Use /help `,
+		},
+	},
+	"gemini": {
+		PromptFlags: []string{"-p", "--prompt", "-i", "--prompt-interactive"},
+		ModelFlags:  []string{"-m", "--model"},
+		// Array options such as --extensions are taken to have a single value
+		ValueFlags: []string{
+			"-o", "--output-format", "--approval-mode", "--sandbox-image", "-e", "--extensions",
+			"--include-directories", "--allowed-mcp-server-names", "--allowed-tools",
+			"--telemetry-target", "--telemetry-otlp-endpoint", "--telemetry-otlp-protocol", "--telemetry-outfile",
+			"--delete-session",
+		},
+		// A proxy URL may carry a user name and password
+		SecretFlags: []string{"--proxy"},
+		PipedPrompt: true,
+	},
+	"ollama": {
+		ValueFlags:  []string{"--format", "--keepalive", "--dimensions"},
+		SkipArgs:    2,
+		ModelArg:    2,
+		PipedPrompt: true,
+	},
+	"llm": {
+		ModelFlags: []string{"-m", "--model"},
+		ValueFlags: []string{
+			"-s", "--system", "-t", "--template", "-a", "--attachment", "--cid", "--conversation",
+			"--schema", "-f", "--fragment", "--sf", "--system-fragment", "-T", "--tool",
+		},
+		SecretFlags: []string{"--key"},
+		PipedPrompt: true,
+	},
+	"sgpt": {
+		ModelFlags:  []string{"--model"},
+		ValueFlags:  []string{"--temperature", "--top-p", "--chat", "--repl", "--role", "--show-chat"},
+		PipedPrompt: true,
+	},
+}
+
+// secretFlagName matches options that are taken to hold a credential in any profile
+var secretFlagName = regexp.MustCompile(`(?i)(key|token|secret|password|passwd)$`)
+
+// Profile describes a tool: where its prompt is and how to read its response
+type Profile struct {
+	config.WrapProfile
+	Name   string
+	ignore []*regexp.Regexp
+}
+
+// Profiles holds the built-in and configured profiles by name
+type Profiles struct {
+	profiles map[string]*Profile
+}
+
+// New creates the profiles from configuration. Configured profiles replace
+// built-in ones of the same name. A profile with an invalid pattern is left
+// out and reported in the error; the others are still usable.
+func New(cfg config.WrapConfig) (*Profiles, error) {
+	p := &Profiles{profiles: make(map[string]*Profile)}
+	all := maps.Clone(builtinProfiles)
+	maps.Copy(all, cfg.Profiles)
+
+	var firstErr error
+	for _, name := range slices.Sorted(maps.Keys(all)) {
+		profile, err := newProfile(name, all[name])
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		p.profiles[name] = profile
+	}
+	return p, firstErr
+}
+
+func newProfile(name string, cfg config.WrapProfile) (*Profile, error) {
+	profile := &Profile{WrapProfile: cfg, Name: name}
+	for _, pattern := range cfg.IgnoreLines {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignore_lines pattern in wrap profile %q: %w", name, err)
+		}
+		profile.ignore = append(profile.ignore, re)
+	}
+	return profile, nil
+}
+
+// Lookup returns the profile named service, or else the one named after the
+// program, or else a default profile that takes the positional arguments as the prompt
+func (p *Profiles) Lookup(service, program string) *Profile {
+	if profile, ok := p.profiles[service]; ok {
+		return profile
+	}
+	if profile, ok := p.profiles[filepath.Base(program)]; ok {
+		return profile
+	}
+	return &Profile{Name: service}
+}
+
+// Invocation is a command line split into the prompt and the options
+type Invocation struct {
+	Prompt string
+	// PromptFlag is the option that gave the prompt; empty for a positional prompt
+	PromptFlag string
+	Model      string
+	// Files are the positional arguments of tools that take files
+	Files []string
+	// Flags are the options with their values, without the prompt. Credentials
+	// given to secret options are left out; only the option names remain.
+	Flags []string
+	// FlagNames are the names of the options in Flags, without any values
+	FlagNames []string
+	// Values are the values of the options in Flags that take one, by option name
+	Values map[string]string
+}
+
+// Value returns the value of the last of the named options given, or "" if none was
+func (inv Invocation) Value(names ...string) string {
+	value := ""
+	for _, name := range inv.FlagNames {
+		if slices.Contains(names, name) {
+			value = inv.Values[name]
+		}
+	}
+	return value
+}
+
+// ParseArgs splits the arguments of the tool, without the program name, into
+// the prompt and the options. Positional words form the prompt unless a prompt
+// option gave one or the tool takes files.
+func (p *Profile) ParseArgs(args []string) Invocation {
+	var inv Invocation
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, inline := strings.Cut(arg, "=")
+		if !strings.HasPrefix(arg, "--") {
+			name, value, inline = arg, "", false
+		}
+
+		isPrompt := slices.Contains(p.PromptFlags, name)
+		isModel := slices.Contains(p.ModelFlags, name)
+		isSecret := slices.Contains(p.SecretFlags, name) || secretFlagName.MatchString(name)
+		takesValue := isPrompt || isModel || isSecret || slices.Contains(p.ValueFlags, name)
+		if takesValue && !inline && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+
+		switch {
+		case arg == "--":
+			positional = append(positional, args[i+1:]...)
+			i = len(args)
+		case isPrompt:
+			inv.Prompt, inv.PromptFlag = value, name
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			positional = append(positional, arg)
+		default:
+			if isModel {
+				inv.Model = value
+			}
+			inv.Flags = append(inv.Flags, name)
+			inv.FlagNames = append(inv.FlagNames, name)
+			if takesValue && !isSecret {
+				inv.Flags = append(inv.Flags, value)
+				if inv.Values == nil {
+					inv.Values = make(map[string]string)
+				}
+				inv.Values[name] = value
+			}
+		}
+	}
+
+	if p.ModelArg > 0 && len(positional) >= p.ModelArg {
+		inv.Model = positional[p.ModelArg-1]
+	}
+	positional = positional[min(p.SkipArgs, len(positional)):]
+
+	switch {
+	case p.FileArgs:
+		inv.Files = positional
+	case inv.Prompt == "":
+		inv.Prompt = strings.Join(positional, " ")
+	}
+	return inv
+}
+
+// Response returns the response in the stdout of the tool. Output that does not
+// have the profile's format is taken as text. Text is logged without escape
+// sequences and ignored lines.
+func (p *Profile) Response(stdout string) string {
+	switch p.ResponseFormat {
+	case config.ResponseFormatJSON:
+		var doc any
+		if json.Unmarshal([]byte(stdout), &doc) == nil {
+			if response, ok := field(doc, p.ResponseField); ok {
+				return response
+			}
+		}
+	case config.ResponseFormatJSONL:
+		var parts []string
+		for line := range strings.Lines(stdout) {
+			var doc any
+			if json.Unmarshal([]byte(line), &doc) != nil {
+				continue
+			}
+			if part, ok := field(doc, p.ResponseField); ok {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) > 0 {
+			return strings.TrimSpace(strings.Join(parts, ""))
+		}
+	}
+	return p.text(stdout)
+}
+
+func (p *Profile) text(stdout string) string {
+	var lines []string
+	for line := range strings.SplitSeq(term.Strip(stdout), "\n") {
+		if !slices.ContainsFunc(p.ignore, func(re *regexp.Regexp) bool { return re.MatchString(line) }) {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// field returns the value at the dotted path in a JSON document, with numbers
+// indexing arrays. A value that is not a string is returned as JSON.
+func field(doc any, path string) (string, bool) {
+	if path != "" {
+		for key := range strings.SplitSeq(path, ".") {
+			switch node := doc.(type) {
+			case map[string]any:
+				value, ok := node[key]
+				if !ok {
+					return "", false
+				}
+				doc = value
+			case []any:
+				index, err := strconv.Atoi(key)
+				if err != nil || index < 0 || index >= len(node) {
+					return "", false
+				}
+				doc = node[index]
+			default:
+				return "", false
+			}
+		}
+	}
+
+	switch value := doc.(type) {
+	case string:
+		return value, true
+	case nil:
+		return "", false
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return "", false
+		}
+		return string(data), true
+	}
+}
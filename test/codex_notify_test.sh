@@ -56,8 +56,18 @@ if [ ! -f ~/.tapline/session_id ]; then
 fi
 echo "PASS: Event processed successfully"
 
-# Test 4: Simulate unknown event (should be ignored)
-echo "Test 4: Handle unknown event type"
+# Test 4: Codex passes the notification as the last argument
+echo "Test 4: agent-turn-complete event as argument"
+TURN_JSON='{"type":"agent-turn-complete","turn-id":"1","input-messages":["Say hi"],"last-assistant-message":"Hi!"}'
+output=$($TAPLINE notify-codex "$TURN_JSON")
+if ! echo "$output" | grep -q '"turn_id":"1"'; then
+    echo "FAIL: Turn not logged: $output"
+    exit 1
+fi
+echo "PASS: Argument event processed"
+
+# Test 5: Simulate unknown event (should be ignored)
+echo "Test 5: Handle unknown event type"
 UNKNOWN_EVENT='{
   "type": "unknown-event",
   "data": {}
@@ -66,8 +76,8 @@ UNKNOWN_EVENT='{
 echo "$UNKNOWN_EVENT" | $TAPLINE notify-codex
 echo "PASS: Unknown event handled gracefully"
 
-# Test 5: End session
-echo "Test 5: End session"
+# Test 6: End session
+echo "Test 6: End session"
 $TAPLINE conversation_end >> "$LOGFILE"
 echo "PASS: Session ended"
 
@@ -81,4 +91,4 @@ echo "  - Error handling: YES"
 echo ""
 echo "Configuration:"
 echo "  Add this to ~/.codex/config.toml:"
-echo "  notify = \"$TAPLINE notify-codex\""
+echo "  notify = [\"$TAPLINE\", \"notify-codex\"]"